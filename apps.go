@@ -1,15 +1,14 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/cli/cf/terminal"
@@ -17,40 +16,47 @@ import (
 	"github.com/cloudfoundry/go-cfclient/v3/client"
 	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/cfapi"
 	"github.com/metskem/panzer-plugin/conf"
 )
 
-var (
-	appData             = make(map[string]*resource.App)
-	processes           = make([]*resource.Process, 0)
-	processStats        = make(map[string]*resource.ProcessStats)
-	processMutex        sync.Mutex
-	concurrencyCounter  int32
-	concurrencyCounterP *int32
-	totalApps           = 0
-	totalAppsStarted    = 0
-	totalInstances      = 0
-	totalMemory         = 0
-	totalDisk           = 0
-	totalLog            = 0
-	totalMemoryUsed     = 0
-	totalDiskUsed       = 0
-	totalLogUsed        = 0
-	totalCpuUsed        float64
-)
-
-type ProcessList []*resource.Process
-
-func (list ProcessList) Len() int {
-	return len(list)
+// appsCommand holds the state of one "cf aa" invocation: the fetched apps, processes and process stats, and the totals.
+type appsCommand struct {
+	*conf.Context
+	appNameRegex      *regexp.Regexp
+	colNames          []string
+	appData           map[string]*resource.App
+	processes         []*resource.Process
+	processStats      map[string]*resource.ProcessStats
+	statsFailures     map[string]int // the number of failed stats calls, keyed by process guid, "cf rightsize" gets the stats more than once
+	processMutex      sync.Mutex
+	totals            appTotals
+	appBindings       map[string][]appBinding // keyed by app guid, only for the Services column
+	bindingsFailed    bool
+	appDroplets       map[string]*resource.Droplet // keyed by app guid, only for the columns that need the droplet
+	dropletsFailed    bool
+	packages          map[string]*resource.Package // keyed by package guid, only for the PackageType column
+	packagesFailed    bool
+	deployments       map[string]*cfapi.Deployment // keyed by app guid, only for the Deployment column
+	deploymentsFailed bool
+	taskCounts        map[string]map[string]int // the number of tasks per state, keyed by app guid, only for the Tasks column
+	tasksFailed       bool
+	sidecars          map[string][]*resource.Sidecar // keyed by app guid, only for the Sidecars column and the sidecar breakdown
+	sidecarsFailed    map[string]bool                // keyed by app guid
 }
 
-func (list ProcessList) Less(i, j int) bool {
-	return strings.ToLower(appData[list[i].Relationships.App.Data.GUID].Name) < strings.ToLower(appData[list[j].Relationships.App.Data.GUID].Name)
-}
-
-func (list ProcessList) Swap(i, j int) {
-	list[i], list[j] = list[j], list[i]
+// appTotals holds the totals for the summary (and the quota usage) of "cf aa".
+type appTotals struct {
+	apps        int
+	appsStarted int
+	instances   int
+	memory      int
+	disk        int
+	log         int
+	memoryUsed  int
+	diskUsed    int
+	logUsed     int
+	cpuUsed     float64
 }
 
 const (
@@ -79,210 +85,246 @@ const (
 	colProcType                     = "ProcType"
 	colUptime                       = "Uptime"
 	colInstancePorts                = "InstancePorts"
+	colServices                     = "Services"
+	colLifecycle                    = "Lifecycle"
+	colBuildpackVersions            = "BuildpackVersions"
+	colStaged                       = "Staged"
+	colDropletAge                   = "DropletAge"
+	colPackageType                  = "PackageType"
+	colDeployment                   = "Deployment"
+	colTasks                        = "Tasks"
+	colSidecars                     = "Sidecars"
 )
 
 var DefaultColumns = []string{colAppName, colState, colMemory, colDisk, colUpdated, colHealthCheck, colInstances, colHost, colProcState, colUptime, colCpu, colMemUsed}
-var ValidColumns = []string{colAppName, colState, colMemory, colLogRate, colDisk, colType, colInstances, colHost, colCpu, colMemUsed, colDiskUsed, colLogRateUsed, colCreated, colUpdated, colBuildpacks, colStack, colHealthCheck, colHealthCheckInvocationTimeout, colHealthCheckTimeout, colGuid, colProcState, colProcType, colUptime, colInstancePorts}
+var ValidColumns = []string{colAppName, colState, colMemory, colLogRate, colDisk, colType, colInstances, colHost, colCpu, colMemUsed, colDiskUsed, colLogRateUsed, colCreated, colUpdated, colBuildpacks, colStack, colHealthCheck, colHealthCheckInvocationTimeout, colHealthCheckTimeout, colGuid, colProcState, colProcType, colUptime, colInstancePorts, colServices, colLifecycle, colBuildpackVersions, colStaged, colDropletAge, colPackageType, colDeployment, colTasks, colSidecars}
 var InstanceLevelColumns = []string{colHost, colCpu, colMemUsed, colDiskUsed, colLogRateUsed, colProcState, colProcType, colUptime, colInstancePorts}
+var DropletColumns = []string{colBuildpackVersions, colStaged, colDropletAge, colPackageType}
 
 /** listApps - The main function to produce the response. */
-func listApps(cliConnection plugin.CliConnection) {
-	flaggy.DefaultParser.ShowHelpOnUnexpected = false
-	flaggy.DefaultParser.ShowVersionWithVersionFlag = false
-	flaggy.String(&conf.FlagAppName, "a", "appname", "Filter the output by the given appname")
-	flaggy.Bool(&conf.FlagHideHeaders, "q", "hide-headers", "Hide the headers (and summary) of the output (handy for automated processing), default is false")
-	flaggy.Bool(&conf.FlagShowQuotaUsage, "u", "show-quota-usage", "Show the space quota usage, default is false")
-	flaggy.Parse()
-	if !conf.FlagHideHeaders {
-		fmt.Printf("Getting apps for org %s / space %s as %s...\n\n", terminal.EntityNameColor(conf.CurrentOrg.Name), terminal.EntityNameColor(conf.CurrentSpace.Name), terminal.EntityNameColor(conf.CurrentUser))
-	}
-	conf.AppNameRegex = *regexp.MustCompile(conf.FlagAppName)
-
-	colNames = getRequestedColNames()
-	if currentSpace, err := cliConnection.GetCurrentSpace(); err != nil {
-		fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get current space: %s", err)))
-		os.Exit(1)
-	} else {
-		conf.CurrentSpace = currentSpace
-		// get the apps
-		if apps, err := conf.CfClient.Applications.ListAll(conf.CfCtx, &client.AppListOptions{ListOptions: &client.ListOptions{}, SpaceGUIDs: client.Filter{Values: []string{currentSpace.Guid}}}); err != nil {
-			fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get apps: %s", err)))
-		} else {
-			// convert the json response to a map of App keyed by appguid
-			for _, app := range apps {
-				if conf.AppNameRegex.MatchString(app.Name) {
-					appData[app.GUID] = app
+func listApps(cmdCtx *conf.Context, cliConnection plugin.CliConnection, args []string) error {
+	a := &appsCommand{Context: cmdCtx, appData: make(map[string]*resource.App), processStats: make(map[string]*resource.ProcessStats)}
+	if err := a.ParseFlags(newAppsFlagParser(&a.Flags), args); err != nil {
+		return err
+	}
+	if a.Flags.ShowSidecars && a.Flags.Format == conf.FormatCsv {
+		// the sidecars are a second table, that would end up in the middle of the csv
+		return conf.UsageError("the -s flag can not be used with --format %s, use the %s column instead", conf.FormatCsv, colSidecars)
+	}
+	if a.ShowInfo() {
+		fmt.Printf("Getting apps for org %s / space %s as %s...\n\n", terminal.EntityNameColor(a.CurrentOrg.Name), terminal.EntityNameColor(a.CurrentSpace.Name), terminal.EntityNameColor(a.CurrentUser))
+	}
+	var err error
+	if a.appNameRegex, err = regexp.Compile(a.Flags.AppName); err != nil {
+		return conf.UsageError("invalid appname filter %s: %s", a.Flags.AppName, err)
+	}
+
+	if a.colNames, err = a.getRequestedColNames(); err != nil {
+		return err
+	}
+	// get the apps
+	apps, err := a.CfClient.Applications.ListAll(a.CfCtx, &client.AppListOptions{ListOptions: &client.ListOptions{}, SpaceGUIDs: client.Filter{Values: []string{a.CurrentSpace.Guid}}})
+	if err != nil {
+		return conf.APIError(err, "failed to get apps")
+	}
+	// convert the json response to a map of App keyed by appguid
+	for _, app := range apps {
+		if a.appNameRegex.MatchString(app.Name) {
+			a.appData[app.GUID] = app
+		}
+	}
+
+	// get the processes
+	unfilteredProcesses, err := a.CfClient.Processes.ListAll(a.CfCtx, &client.ProcessListOptions{ListOptions: &client.ListOptions{}, SpaceGUIDs: client.Filter{Values: []string{a.CurrentSpace.Guid}}})
+	if err != nil {
+		return conf.APIError(err, "failed to get processes")
+	}
+	// filter out those processes that are not in the appData map
+	for _, process := range unfilteredProcesses {
+		if a.appData[process.Relationships.App.Data.GUID] != nil {
+			a.processes = append(a.processes, process)
+		}
+	}
+	sort.Slice(a.processes, func(i, j int) bool {
+		return strings.ToLower(a.appData[a.processes[i].Relationships.App.Data.GUID].Name) < strings.ToLower(a.appData[a.processes[j].Relationships.App.Data.GUID].Name)
+	})
+	//
+	// optionally get the service bindings (a few calls for the whole space)
+	if conf.HasColumn(a.colNames, colServices) {
+		a.getAppBindings()
+	}
+	//
+	// optionally get the droplets and packages (one call each for the whole space)
+	if a.dropletsRequired() {
+		a.getAppDroplets()
+	}
+	if conf.HasColumn(a.colNames, colPackageType) {
+		a.getPackages()
+	}
+	//
+	// optionally get the active deployments (a few calls for the whole space)
+	if conf.HasColumn(a.colNames, colDeployment) {
+		a.getDeployments()
+	}
+	//
+	// optionally get the active and recent tasks (one or two calls for the whole space)
+	if conf.HasColumn(a.colNames, colTasks) {
+		a.getTaskCounts()
+	}
+	//
+	// optionally get the sidecars (one call per app, concurrently)
+	if conf.HasColumn(a.colNames, colSidecars) || a.Flags.ShowSidecars {
+		a.getSidecars()
+		if a.CfCtx.Err() != nil {
+			return nil // cancelled, Run reports why
+		}
+	}
+	//
+	// optionally get the stats (per instance stats)
+	if processStatsRequired(a.colNames) {
+		a.getProcessStats()
+		if a.CfCtx.Err() != nil {
+			return nil // cancelled, Run reports why
+		}
+	}
+
+	table := a.NewTable(a.colNames)
+	if a.Flags.HideHeaders {
+		table.NoHeaders()
+	}
+	for _, process := range a.processes {
+		if !(process.Type == "task" && process.Instances == 0) {
+			if a.appNameRegex.MatchString(a.appData[process.Relationships.App.Data.GUID].Name) {
+				var colValues []string
+				for _, colName := range a.colNames {
+					colValues = append(colValues, a.getColValue(process, colName))
 				}
+				table.Add(colValues[:]...)
 			}
+		}
+	}
+	_ = table.PrintTo(os.Stdout)
 
-			// get the processes
-			if unfilteredProcesses, err := conf.CfClient.Processes.ListAll(conf.CfCtx, &client.ProcessListOptions{ListOptions: &client.ListOptions{}, SpaceGUIDs: client.Filter{Values: []string{currentSpace.Guid}}}); err != nil {
-				fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get processes: %s", err)))
-			} else {
-				// filter out those processes that are not in the appData map
-				for _, process := range unfilteredProcesses {
-					if appData[process.Relationships.App.Data.GUID] != nil {
-						processes = append(processes, process)
-					}
-				}
-				var pList ProcessList
-				pList = processes
-				sort.Sort(pList)
-				//
-				// optionally get the stats (per instance stats)
-				if processStatsRequired(colNames) {
-					processStats = getProcessStats(processes)
-				}
+	a.calculateTotals()
+	if a.ShowInfo() {
+		fmt.Printf("\n  %s\n", terminal.StoppedColor(a.getTotals(a.colNames)))
+	}
 
-				table := terminal.NewTable(colNames)
-				if conf.FlagHideHeaders {
-					table.NoHeaders()
-				}
-				for _, process := range processes {
-					if !(process.Type == "task" && process.Instances == 0) {
-						if conf.AppNameRegex.MatchString(appData[process.Relationships.App.Data.GUID].Name) {
-							var colValues []string
-							for _, colName := range colNames {
-								colValues = append(colValues, getColValue(process, colName))
-							}
-							table.Add(colValues[:]...)
-						}
-					}
-				}
-				_ = table.PrintTo(os.Stdout)
+	if a.Flags.ShowSidecars {
+		a.printSidecars()
+	}
+	if a.Flags.ShowQuotaUsage {
+		return a.printQuotaUsage()
+	}
+	return nil
+}
 
-				if !conf.FlagHideHeaders {
-					fmt.Printf("\n  %s\n", terminal.StoppedColor(getTotals(colNames)))
-				}
+/** newAppsFlagParser - Create the flag parser for "cf aa", also used to generate the shell completion. */
+func newAppsFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("aa", flags)
+	parser.String(&flags.AppName, "a", "appname", "Filter the output by the given appname")
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers (and summary) of the output (handy for automated processing), default is false")
+	parser.Bool(&flags.ShowQuotaUsage, "u", "show-quota-usage", "Show the space quota usage, default is false")
+	parser.Bool(&flags.ShowSidecars, "s", "show-sidecars", "Show the sidecars of the apps, with their command, process types and memory (not with --format csv), default is false")
+	parser.String(&flags.Columns, "c", "columns", "The columns to show (comma separated, case insensitive), instead of CF_COLS, use +Col,-Col to add/remove columns to/from the default columns, or ALL (there is no DropletSize, the v3 API does not report it)")
+	parser.String(&flags.Profile, "", "profile", "Use the columns of the given profile from the config file (panzer.yml), instead of CF_COLS")
+	return parser
+}
 
-				if conf.FlagShowQuotaUsage {
-					if currentSpace, err = cliConnection.GetCurrentSpace(); err != nil {
-						fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get current space: %s", err)))
-					} else {
-						if space, err := conf.CfClient.Spaces.Get(context.Background(), currentSpace.Guid); err != nil {
-							fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get space: %s", err)))
-						} else {
-							if space.Relationships.Quota.Data != nil { // only if the space has a quota
-								if spaceQuota, err := conf.CfClient.SpaceQuotas.Get(context.Background(), space.Relationships.Quota.Data.GUID); err != nil {
-									fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get space_quota: %s", err)))
-								} else {
-									appInstancesQuota := *spaceQuota.Apps.TotalInstances
-									serviceInstancesQuota := *spaceQuota.Services.TotalServiceInstances
-									routesQuota := *spaceQuota.Routes.TotalRoutes
-
-									tableColumns := []string{"Quota", "Usage", "Allocation", "Quota", "Quota %"}
-									table = terminal.NewTable(tableColumns)
-
-									memPerc := 0
-									memQuota := *spaceQuota.Apps.TotalMemoryInMB
-									if totalMemory != 0 {
-										memPerc = 100 * totalMemory / memQuota
-									}
-									memPercColored := terminal.SuccessColor(fmt.Sprintf("%7s", strconv.Itoa(memPerc)))
-									if memPerc > 80 {
-										memPercColored = terminal.FailureColor(fmt.Sprintf("%7s", strconv.Itoa(memPerc)))
-									}
-
-									logPerc := 0
-									logQuota := *spaceQuota.Apps.LogRateLimitInBytesPerSecond
-									if totalLog != 0 {
-										logPerc = 100 * totalLog / logQuota
-									}
-									logPercColored := terminal.SuccessColor(fmt.Sprintf("%7s", strconv.Itoa(logPerc)))
-									if logPerc > 80 {
-										logPercColored = terminal.FailureColor(fmt.Sprintf("%7s", strconv.Itoa(logPerc)))
-									}
-
-									appInstancesPerc := 100 * totalInstances / appInstancesQuota
-									appInstancesPercColored := terminal.SuccessColor(fmt.Sprintf("%7s", strconv.Itoa(appInstancesPerc)))
-									if appInstancesPerc > 80 {
-										appInstancesPercColored = terminal.FailureColor(fmt.Sprintf("%7s", strconv.Itoa(appInstancesPerc)))
-									}
-									table.Add("app instances", fmt.Sprintf("%5d", totalInstances), "        -", fmt.Sprintf("%5d", appInstancesQuota), appInstancesPercColored)
-
-									if serviceInstances, err := conf.CfClient.ServiceInstances.ListAll(context.Background(), &client.ServiceInstanceListOptions{ListOptions: &client.ListOptions{}, SpaceGUIDs: client.Filter{Values: []string{currentSpace.Guid}}}); err != nil {
-										fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get service instances: %s", err)))
-									} else {
-										serviceInstancesPerc := 100 * len(serviceInstances) / serviceInstancesQuota
-										serviceInstancesPercColored := terminal.SuccessColor(fmt.Sprintf("%7s", strconv.Itoa(serviceInstancesPerc)))
-										if serviceInstancesPerc > 80 {
-											serviceInstancesPercColored = terminal.FailureColor(fmt.Sprintf("%7s", strconv.Itoa(serviceInstancesPerc)))
-										}
-										table.Add("service instances", fmt.Sprintf("%5d", len(serviceInstances)), "        -", fmt.Sprintf("%5d", serviceInstancesQuota), serviceInstancesPercColored)
-									}
-
-									if routes, err := conf.CfClient.Routes.ListAll(context.Background(), &client.RouteListOptions{ListOptions: &client.ListOptions{}, SpaceGUIDs: client.Filter{Values: []string{currentSpace.Guid}}}); err != nil {
-										fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get routes: %s", err)))
-									} else {
-										routesPerc := 100 * len(routes) / routesQuota
-										routesPercColored := terminal.SuccessColor(fmt.Sprintf("%7s", strconv.Itoa(routesPerc)))
-										if routesPerc > 80 {
-											routesPercColored = terminal.FailureColor(fmt.Sprintf("%7s", strconv.Itoa(routesPerc)))
-										}
-										table.Add("routes", fmt.Sprintf("%5d", len(routes)), "        -", fmt.Sprintf("%5d", routesQuota), routesPercColored)
-									}
-
-									table.Add("memory", fmt.Sprintf("%5s", getFormattedUnit(totalMemoryUsed*1024*1024)), fmt.Sprintf("%10s", getFormattedUnit(totalMemory*1024*1024)), fmt.Sprintf("%5s", getFormattedUnit(memQuota*1024*1024)), memPercColored)
-									table.Add("log_rate", fmt.Sprintf("%5s", getFormattedUnit(totalLogUsed)), fmt.Sprintf("%10s", getFormattedUnit(totalLog)), fmt.Sprintf("%5s", getFormattedUnit(logQuota)), logPercColored)
-
-								}
-							} else {
-								fmt.Printf("No space quota found for space %s\n", terminal.EntityNameColor(conf.CurrentSpace.Name))
-							}
-						}
-						_ = table.PrintTo(os.Stdout)
-					}
-				}
+/** printQuotaUsage - Print the usage of the space quota of the current space and the headroom that is left, if the space has a quota. */
+func (a *appsCommand) printQuotaUsage() error {
+	space, err := a.Resolver.GetSpace(a.CurrentSpace.Guid)
+	if err != nil {
+		return conf.APIError(err, "failed to get space")
+	}
+	if space.Relationships == nil || space.Relationships.Quota == nil || space.Relationships.Quota.Data == nil { // only if the space has a quota
+		a.Notice("No space quota found for space %s", terminal.EntityNameColor(a.CurrentSpace.Name))
+		return nil
+	}
+	spaceQuota, err := a.CfClient.SpaceQuotas.Get(a.CfCtx, space.Relationships.Quota.Data.GUID)
+	if err != nil {
+		return conf.APIError(err, "failed to get space_quota")
+	}
+	usages, err := getQuotaUsages(a.Context, client.Filter{}, client.Filter{Values: []string{a.CurrentSpace.Guid}})
+	if err != nil {
+		return err
+	}
+	quota := quotaLimits{name: spaceQuota.Name, apps: spaceQuota.Apps, services: spaceQuota.Services, routes: spaceQuota.Routes}
+
+	tableColumns := []string{"Quota", "Usage", "Allocation", "Quota", "Quota %", "Headroom"}
+	table := a.NewTable(tableColumns)
+	for _, dimension := range quota.dimensions(usages[a.CurrentSpace.Guid]) {
+		usage, allocation := dimension.format(dimension.used), "-"
+		if dimension.allocated {
+			// the actual usage (from the stats) is only known for memory and log rate
+			usage, allocation = "-", dimension.format(dimension.used)
+			switch dimension.name {
+			case quotaMemory:
+				usage = getFormattedUnit(a.totals.memoryUsed * 1024 * 1024)
+			case quotaLogRate:
+				usage = getFormattedUnit(a.totals.logUsed)
 			}
 		}
+		percentage := fmt.Sprintf("%7s", "-")
+		if !dimension.unlimited() {
+			percentage = colorQuotaPercentage(a.Context, dimension.percentage(), 7)
+		}
+		table.Add(dimension.name, fmt.Sprintf("%5s", usage), fmt.Sprintf("%10s", allocation), fmt.Sprintf("%5s", dimension.formatLimit()), percentage, dimension.formatHeadroom(8))
 	}
+	_ = table.PrintTo(os.Stdout)
+	return nil
 }
 
-/** getTotals - Get all totals for the apps in the space, like total # of apps and total memory usage. */
-func getTotals(colNames []string) string {
-	for _, process := range processes {
-		if conf.AppNameRegex.MatchString(appData[process.Relationships.App.Data.GUID].Name) {
+/** calculateTotals - Calculate all totals for the apps in the space, like total # of apps and total memory usage. */
+func (a *appsCommand) calculateTotals() {
+	a.totals = appTotals{}
+	for _, process := range a.processes {
+		if a.appNameRegex.MatchString(a.appData[process.Relationships.App.Data.GUID].Name) {
 			if !(process.Type == "task" && process.Instances == 0) {
-				totalApps++
-				if appData[process.Relationships.App.Data.GUID].State == "STARTED" {
-					totalInstances = totalInstances + process.Instances
-					totalAppsStarted++
-					totalMemory = totalMemory + process.MemoryInMB*process.Instances
-					totalDisk = totalDisk + process.DiskInMB*process.Instances
-					totalLog = totalLog + process.LogRateLimitInBytesPerSecond*process.Instances
-					if processStats[process.GUID] != nil {
-						for _, stat := range processStats[process.GUID].Stats {
-							totalDiskUsed = totalDiskUsed + stat.Usage.Disk/1024/1024
-							totalLogUsed = totalLogUsed + stat.Usage.LogRate
-							totalMemoryUsed = totalMemoryUsed + stat.Usage.Memory/1024/1024
-							totalCpuUsed = totalCpuUsed + stat.Usage.CPU*100
+				a.totals.apps++
+				if a.appData[process.Relationships.App.Data.GUID].State == "STARTED" {
+					a.totals.instances = a.totals.instances + process.Instances
+					a.totals.appsStarted++
+					a.totals.memory = a.totals.memory + process.MemoryInMB*process.Instances
+					a.totals.disk = a.totals.disk + process.DiskInMB*process.Instances
+					a.totals.log = a.totals.log + process.LogRateLimitInBytesPerSecond*process.Instances
+					if a.processStats[process.GUID] != nil {
+						for _, stat := range a.processStats[process.GUID].Stats {
+							a.totals.diskUsed = a.totals.diskUsed + stat.Usage.Disk/1024/1024
+							a.totals.logUsed = a.totals.logUsed + stat.Usage.LogRate
+							a.totals.memoryUsed = a.totals.memoryUsed + stat.Usage.Memory/1024/1024
+							a.totals.cpuUsed = a.totals.cpuUsed + stat.Usage.CPU*100
 						}
 					}
 				}
 			}
 		}
 	}
-	if totalApps > 0 {
+}
+
+/** getTotals - Get the summary line with the totals for the apps in the space. */
+func (a *appsCommand) getTotals(colNames []string) string {
+	if a.totals.apps > 0 {
 		memPerc := 0
-		if totalMemory != 0 {
-			memPerc = 100 * totalMemoryUsed / totalMemory
+		if a.totals.memory != 0 {
+			memPerc = 100 * a.totals.memoryUsed / a.totals.memory
 		}
 		diskPerc := 0
-		if totalDisk != 0 {
-			diskPerc = 100 * totalDiskUsed / totalDisk
+		if a.totals.disk != 0 {
+			diskPerc = 100 * a.totals.diskUsed / a.totals.disk
 		}
 		logPerc := 0
-		if totalLog != 0 {
-			logPerc = 100 * totalLogUsed / totalLog
+		if a.totals.log != 0 {
+			logPerc = 100 * a.totals.logUsed / a.totals.log
 			if logPerc < 0 {
 				logPerc = 0
 			}
 		}
 		if processStatsRequired(colNames) {
 			// we only have the "used" statistics if we requested at least one instance level column, if not we provide less statistics
-			return fmt.Sprintf("%d apps (%d started), %d running instances, Memory(MB): requested:%s, used:%s (%2.0d%%), Cpu %4.0f%%, Disk(MB): requested:%s, used:%s (%2.0d%%), LogRate(BPS): requested:%s, used:%s (%2.0d%%)", totalApps, totalAppsStarted, totalInstances, getFormattedUnit(totalMemory*1024*1024), getFormattedUnit(totalMemoryUsed*1024*1024), memPerc, totalCpuUsed, getFormattedUnit(totalDisk*1024*1024), getFormattedUnit(totalDiskUsed*1024*1024), diskPerc, getFormattedUnit(totalLog), getFormattedUnit(totalLogUsed), logPerc)
+			return fmt.Sprintf("%d apps (%d started), %d running instances, Memory(MB): requested:%s, used:%s (%2.0d%%), Cpu %4.0f%%, Disk(MB): requested:%s, used:%s (%2.0d%%), LogRate(BPS): requested:%s, used:%s (%2.0d%%)", a.totals.apps, a.totals.appsStarted, a.totals.instances, getFormattedUnit(a.totals.memory*1024*1024), getFormattedUnit(a.totals.memoryUsed*1024*1024), memPerc, a.totals.cpuUsed, getFormattedUnit(a.totals.disk*1024*1024), getFormattedUnit(a.totals.diskUsed*1024*1024), diskPerc, getFormattedUnit(a.totals.log), getFormattedUnit(a.totals.logUsed), logPerc)
 		} else {
-			return fmt.Sprintf("%d apps (%d started), %d running instances, Memory(MB): requested:%s, Cpu %4.0f%%, Disk(MB): requested:%s, LogRate(BPS):%s", totalApps, totalAppsStarted, totalInstances, getFormattedUnit(totalMemory*1024*1024), totalCpuUsed, getFormattedUnit(totalDisk*1024*1024), getFormattedUnit(totalLogUsed))
+			return fmt.Sprintf("%d apps (%d started), %d running instances, Memory(MB): requested:%s, Cpu %4.0f%%, Disk(MB): requested:%s, LogRate(BPS):%s", a.totals.apps, a.totals.appsStarted, a.totals.instances, getFormattedUnit(a.totals.memory*1024*1024), a.totals.cpuUsed, getFormattedUnit(a.totals.disk*1024*1024), getFormattedUnit(a.totals.logUsed))
 		}
 	} else {
 		return ""
@@ -302,54 +344,65 @@ func processStatsRequired(colNames []string) bool {
 	return isProcessColumn
 }
 
-/** getRequestedColNames - Find out what the desired columns are specified in the envvar CF_COLS, or use the default set of columns */
-func getRequestedColNames() []string {
-	requestedColumns := os.Getenv("CF_COLS")
+/** getRequestedColNames - Find out what the desired columns are, from the --columns flag, the --profile flag, the envvar CF_COLS or the "default" profile in the config file (in that order), or use the default set of columns */
+func (a *appsCommand) getRequestedColNames() ([]string, error) {
+	requestedColumns, source := a.Flags.Columns, "--columns"
+	if requestedColumns == "" && a.Flags.Profile != "" {
+		var err error
+		if requestedColumns, err = a.Settings.GetProfile(a.Flags.Profile); err != nil {
+			return nil, err
+		}
+		source = "profile " + a.Flags.Profile
+	}
 	if requestedColumns == "" {
-		return DefaultColumns
+		requestedColumns, source = os.Getenv("CF_COLS"), "CF_COLS envvar"
 	}
-	if requestedColumns == "ALL" {
-		return ValidColumns
+	if requestedColumns == "" {
+		requestedColumns, source = a.Settings.Profiles["default"], "profile default"
+	}
+	if requestedColumns == "" {
+		return DefaultColumns, nil
+	}
+	// the Ix column is not in ValidColumns (so not in ALL), but it can be requested explicitly, like "+Ix"
+	allColumns := strings.EqualFold(strings.TrimSpace(requestedColumns), "ALL")
+	selectable := append(append([]string{}, ValidColumns...), colIx)
+	if allColumns {
+		selectable = ValidColumns
+	}
+	customColNames, err := conf.SelectColumns(requestedColumns, source, DefaultColumns, selectable)
+	if err != nil {
+		return nil, err
 	}
-	customColNames := strings.Split(requestedColumns, ",")
 	//
-	// validate if invalid column names have been requested (if so, abort), and also check if instance level columns are present (if so, add Ix column)
-	for _, customColName := range customColNames {
-		isCustomColumnValid := false
-		for _, validColumn := range ValidColumns {
-			if customColName == validColumn {
-				isCustomColumnValid = true
-			}
-		}
-		if !isCustomColumnValid {
-			fmt.Println(terminal.FailureColor(fmt.Sprintf("Invalid column in CF_COLS envvar : %s.", customColName)))
-			fmt.Printf("Valid column names are: %s", strings.Join(ValidColumns, ","))
-			os.Exit(1)
-		}
+	// for a list of column names (not ALL, and not +Col/-Col that change the default columns, which have no Ix) with instance level columns,
+	// we add an extra "Ix" column in front to indicate which app index we have, unless Ix was requested already
+	if allColumns || isColumnModifier(requestedColumns) || conf.HasColumn(customColNames, colIx) {
+		return customColNames, nil
 	}
 	for _, customColName := range customColNames {
-		isInstanceLevelColumnRequested := false
-		for _, instanceColumn := range InstanceLevelColumns {
-			if customColName == instanceColumn {
-				isInstanceLevelColumnRequested = true
-			}
-		}
-		//
-		// check if we have instance level columns, if so, we add an extra "ix" column to indicate which app index we have
-		if isInstanceLevelColumnRequested {
-			return strings.Split(fmt.Sprintf("%s,%s", colIx, requestedColumns), ",")
+		if isInstanceColumn(customColName) {
+			return append([]string{colIx}, customColNames...), nil
 		}
 	}
-	return customColNames
+	return customColNames, nil
+}
+
+/** isColumnModifier - Return true if the requested columns are +Col/-Col modifiers of the default columns (conf.SelectColumns does not allow to mix them with column names). */
+func isColumnModifier(requestedColumns string) bool {
+	trimmed := strings.TrimSpace(requestedColumns)
+	return strings.HasPrefix(trimmed, "+") || strings.HasPrefix(trimmed, "-")
 }
 
 /** - getColValue - Get the value of the given column.*/
-func getColValue(process *resource.Process, colName string) string {
+func (a *appsCommand) getColValue(process *resource.Process, colName string) string {
 	var column string
 	// per app instance columns
 	if isInstanceColumn(colName) {
-		for statsIndex, stats := range processStats[process.GUID].Stats {
-			if appData[process.Relationships.App.Data.GUID].State != "STOPPED" {
+		if a.processStats[process.GUID] == nil {
+			return terminal.FailureColor("?") // we failed to get the stats
+		}
+		for statsIndex, stats := range a.processStats[process.GUID].Stats {
+			if a.appData[process.Relationships.App.Data.GUID].State != "STOPPED" {
 				switch colName {
 				case colIx:
 					column = fmt.Sprintf("%s%d\n", column, statsIndex)
@@ -362,10 +415,10 @@ func getColValue(process *resource.Process, colName string) string {
 					usedMem := stats.Usage.Memory / 1024 / 1024
 					memPercent := 100 * usedMem / process.MemoryInMB
 					memPercentColored := terminal.SuccessColor(fmt.Sprintf("%2s", strconv.Itoa(memPercent)))
-					if memPercent < 25 {
+					if memPercent < a.Settings.Thresholds.UsageLow {
 						memPercentColored = terminal.AdvisoryColor(fmt.Sprintf("%2s", strconv.Itoa(memPercent)))
 					}
-					if memPercent > 90 {
+					if memPercent > a.Settings.Thresholds.UsageHigh {
 						memPercentColored = terminal.FailureColor(fmt.Sprintf("%2s", strconv.Itoa(memPercent)))
 					}
 					column = fmt.Sprintf("%s%4s (%s%%)\n", column, getFormattedUnit(usedMem*1024*1024), memPercentColored)
@@ -374,10 +427,10 @@ func getColValue(process *resource.Process, colName string) string {
 					usedDisk := stats.Usage.Disk / 1024 / 1024
 					diskPercent := 100 * usedDisk / process.DiskInMB
 					diskPercentColored := terminal.SuccessColor(fmt.Sprintf("%2s", strconv.Itoa(diskPercent)))
-					if diskPercent < 25 {
+					if diskPercent < a.Settings.Thresholds.UsageLow {
 						diskPercentColored = terminal.AdvisoryColor(fmt.Sprintf("%2s", strconv.Itoa(diskPercent)))
 					}
-					if diskPercent > 90 {
+					if diskPercent > a.Settings.Thresholds.UsageHigh {
 						diskPercentColored = terminal.FailureColor(fmt.Sprintf("%2s", strconv.Itoa(diskPercent)))
 					}
 					column = fmt.Sprintf("%s%4s (%s%%)\n", column, getFormattedUnit(usedDisk*1024*1024), diskPercentColored)
@@ -389,19 +442,19 @@ func getColValue(process *resource.Process, colName string) string {
 					} else {
 						logPercent := 100 * usedLog / process.LogRateLimitInBytesPerSecond
 						logPercentColored := terminal.SuccessColor(fmt.Sprintf("%2s", strconv.Itoa(logPercent)))
-						if logPercent > 80 {
+						if logPercent > a.Settings.Thresholds.LogRateHigh {
 							logPercentColored = terminal.FailureColor(fmt.Sprintf("%2s", strconv.Itoa(logPercent)))
 						}
 						column = fmt.Sprintf("%s%4s (%s%%)\n", column, getFormattedUnit(usedLog), logPercentColored)
 					}
 				case colProcState:
-					if appData[process.Relationships.App.Data.GUID].State == "STARTED" && (stats.State == "CRASHED" || stats.State == "DOWN") {
+					if a.appData[process.Relationships.App.Data.GUID].State == "STARTED" && (stats.State == "CRASHED" || stats.State == "DOWN") {
 						column = fmt.Sprintf("%s%s\n", column, terminal.FailureColor(strings.ToLower(stats.State)))
 					} else {
-						if appData[process.Relationships.App.Data.GUID].State == "STOPPED" && stats.State == "DOWN" {
+						if a.appData[process.Relationships.App.Data.GUID].State == "STOPPED" && stats.State == "DOWN" {
 							column = fmt.Sprintf("%s%s\n", column, terminal.EntityNameColor(strings.ToLower(stats.State)))
 						} else {
-							if appData[process.Relationships.App.Data.GUID].State == "STARTED" && stats.State == "STARTING" {
+							if a.appData[process.Relationships.App.Data.GUID].State == "STARTED" && stats.State == "STARTING" {
 								column = fmt.Sprintf("%s%s\n", column, terminal.EntityNameColor(strings.ToLower(stats.State)))
 							} else {
 								column = fmt.Sprintf("%s%s\n", column, terminal.SuccessColor(strings.ToLower(stats.State)))
@@ -427,14 +480,14 @@ func getColValue(process *resource.Process, colName string) string {
 		// other columns (per app, not per app instance)
 		switch colName {
 		case colAppName:
-			return appData[process.Relationships.App.Data.GUID].Name
+			return a.appData[process.Relationships.App.Data.GUID].Name
 		case colGuid:
-			return appData[process.Relationships.App.Data.GUID].GUID
+			return a.appData[process.Relationships.App.Data.GUID].GUID
 		case colState:
-			if appData[process.Relationships.App.Data.GUID].State == "STOPPED" {
-				return terminal.StoppedColor(strings.ToLower(appData[process.Relationships.App.Data.GUID].State))
+			if a.appData[process.Relationships.App.Data.GUID].State == "STOPPED" {
+				return terminal.StoppedColor(strings.ToLower(a.appData[process.Relationships.App.Data.GUID].State))
 			} else {
-				return terminal.SuccessColor(strings.ToLower(appData[process.Relationships.App.Data.GUID].State))
+				return terminal.SuccessColor(strings.ToLower(a.appData[process.Relationships.App.Data.GUID].State))
 			}
 		case colMemory:
 			return fmt.Sprintf("%6s", getFormattedUnit(process.MemoryInMB*1024*1024))
@@ -447,19 +500,45 @@ func getColValue(process *resource.Process, colName string) string {
 		case colInstances:
 			return fmt.Sprintf("%5d", process.Instances)
 		case colCreated:
-			return appData[process.Relationships.App.Data.GUID].CreatedAt.Format(time.RFC3339)
+			return a.appData[process.Relationships.App.Data.GUID].CreatedAt.Format(time.RFC3339)
 		case colUpdated:
-			return appData[process.Relationships.App.Data.GUID].UpdatedAt.Format(time.RFC3339)
+			return a.appData[process.Relationships.App.Data.GUID].UpdatedAt.Format(time.RFC3339)
 		case colBuildpacks:
-			if actualType, ok := appData[process.Relationships.App.Data.GUID].Lifecycle.Data.(*resource.BuildpackLifecycle); ok {
-				return strings.Join(actualType.Buildpacks, ",")
-			}
-			if _, ok := appData[process.Relationships.App.Data.GUID].Lifecycle.Data.(*resource.DockerLifecycle); ok {
-				return "<DOCKER>"
-			}
-			return strings.Join(appData[process.Relationships.App.Data.GUID].Lifecycle.Data.(*resource.BuildpackLifecycle).Buildpacks, ",")
+			return a.getBuildpacks(a.appData[process.Relationships.App.Data.GUID])
 		case colStack:
-			return appData[process.Relationships.App.Data.GUID].Lifecycle.Data.(*resource.BuildpackLifecycle).Stack
+			return getLifecycleStack(a.appData[process.Relationships.App.Data.GUID].Lifecycle)
+		case colLifecycle:
+			return fmt.Sprintf("%9s", getLifecycleType(a.appData[process.Relationships.App.Data.GUID].Lifecycle))
+		case colBuildpackVersions:
+			return a.getDropletValue(process.Relationships.App.Data.GUID, func(droplet *resource.Droplet) string {
+				var buildpacks []string
+				for _, buildpack := range droplet.Buildpacks {
+					buildpacks = append(buildpacks, formatDetectedBuildpack(buildpack))
+				}
+				if len(buildpacks) == 0 {
+					return "-" // like docker apps
+				}
+				return strings.Join(buildpacks, ",")
+			})
+		case colStaged:
+			return a.getDropletValue(process.Relationships.App.Data.GUID, func(droplet *resource.Droplet) string {
+				return droplet.CreatedAt.Format(time.RFC3339)
+			})
+		case colDropletAge:
+			return a.getDropletValue(process.Relationships.App.Data.GUID, a.getDropletAge)
+		case colPackageType:
+			return a.getDropletValue(process.Relationships.App.Data.GUID, a.getPackageType)
+		case colServices:
+			return a.getServices(process.Relationships.App.Data.GUID)
+		case colDeployment:
+			return a.getDeployment(process)
+		case colTasks:
+			if a.tasksFailed {
+				return terminal.FailureColor("?")
+			}
+			return formatTaskCounts(a.taskCounts[process.Relationships.App.Data.GUID])
+		case colSidecars:
+			return a.getProcessSidecars(process)
 		case colHealthCheck:
 			return fmt.Sprintf("%11s", process.HealthCheck.Type)
 		case colHealthCheckInvocationTimeout:
@@ -479,6 +558,270 @@ func getColValue(process *resource.Process, colName string) string {
 	return strings.TrimRight(column, "\n")
 }
 
+/** getAppBindings - Get the service bindings of all (filtered) apps at once, for the Services column. */
+func (a *appsCommand) getAppBindings() {
+	var appGuids []string
+	for appGuid := range a.appData {
+		appGuids = append(appGuids, appGuid)
+	}
+	var err error
+	if a.appBindings, err = getAppBindings(a.Context, appGuids); err != nil {
+		a.AddFailure(conf.APIError(err, "failed to get service bindings"))
+		a.bindingsFailed = true
+	}
+}
+
+/** getServices - The names of the service instances bound to the app, the ones with a failed binding are red. */
+func (a *appsCommand) getServices(appGuid string) string {
+	if a.bindingsFailed {
+		return terminal.FailureColor("?")
+	}
+	var names []string
+	for _, appBinding := range a.appBindings[appGuid] {
+		if appBinding.binding.LastOperation.State == "failed" {
+			names = append(names, terminal.FailureColor(appBinding.instanceName()))
+		} else {
+			names = append(names, appBinding.instanceName())
+		}
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ",")
+}
+
+/** getDeployments - Get the active deployments of all (filtered) apps at once, for the Deployment column. */
+func (a *appsCommand) getDeployments() {
+	var appGuids []string
+	for appGuid := range a.appData {
+		appGuids = append(appGuids, appGuid)
+	}
+	var err error
+	if a.deployments, err = getActiveDeployments(a.Context, appGuids); err != nil {
+		a.AddFailure(conf.APIError(err, "failed to get deployments"))
+		a.deploymentsFailed = true
+	}
+}
+
+/** getDeployment - Tell if the process is the old or the new one of an active deployment of the app, like "new (rolling, deploying)" or "old (canary 1/3, paused)", "-" if there is no deployment for the process. */
+func (a *appsCommand) getDeployment(process *resource.Process) string {
+	if a.deploymentsFailed {
+		return terminal.FailureColor("?")
+	}
+	deployment := a.deployments[process.Relationships.App.Data.GUID]
+	if deployment == nil {
+		return "-"
+	}
+	role := getDeploymentRole(deployment, process)
+	if role == "" {
+		return "-"
+	}
+	strategy := deployment.Strategy
+	if step := getCanaryStep(deployment); step != "-" {
+		strategy += " " + step
+	}
+	return fmt.Sprintf("%s (%s, %s)", role, strategy, colorDeploymentReason(deployment.Status.Reason))
+}
+
+/** getTaskCounts - Count the active tasks, and the tasks that started in the last 24 hours, per app and state, for the Tasks column. */
+func (a *appsCommand) getTaskCounts() {
+	tasks, err := getTasks(a.Context, client.Filter{}, client.Filter{Values: []string{a.CurrentSpace.Guid}}, taskStates, tasksColumnWindow)
+	if err != nil {
+		a.AddFailure(conf.APIError(err, "failed to get tasks"))
+		a.tasksFailed = true
+		return
+	}
+	a.taskCounts = make(map[string]map[string]int)
+	for _, task := range tasks {
+		appGuid := task.Relationships.App.Data.GUID
+		if a.taskCounts[appGuid] == nil {
+			a.taskCounts[appGuid] = make(map[string]int)
+		}
+		a.taskCounts[appGuid][task.State]++
+	}
+}
+
+/** getSidecars - Get the sidecars of all (filtered) apps concurrently, the CF API only lists them per app (or per process). */
+func (a *appsCommand) getSidecars() {
+	a.sidecars, a.sidecarsFailed = make(map[string][]*resource.Sidecar), make(map[string]bool)
+	var appGuids []string
+	for appGuid := range a.appData {
+		appGuids = append(appGuids, appGuid)
+	}
+	var sidecarsMutex sync.Mutex
+	forEachThrottled(a.CfCtx, len(appGuids), func(ix int) {
+		appGuid := appGuids[ix]
+		sidecars, err := a.CfClient.Sidecars.ListForAppAll(a.CfCtx, appGuid, nil)
+		sidecarsMutex.Lock()
+		defer sidecarsMutex.Unlock()
+		if err != nil {
+			a.AddFailure(conf.APIError(err, "failed to get sidecars for app %s", a.appData[appGuid].Name))
+			a.sidecarsFailed[appGuid] = true
+			return
+		}
+		a.sidecars[appGuid] = sidecars
+	})
+}
+
+/** getProcessSidecars - The sidecars that run in the instances of the process (type), with their memory, like "otel-agent (128M)", "-" if there are none. */
+func (a *appsCommand) getProcessSidecars(process *resource.Process) string {
+	if a.sidecarsFailed[process.Relationships.App.Data.GUID] {
+		return terminal.FailureColor("?")
+	}
+	var sidecars []string
+	for _, sidecar := range a.sidecars[process.Relationships.App.Data.GUID] {
+		if !slices.Contains(sidecar.ProcessTypes, process.Type) {
+			continue
+		}
+		if sidecar.MemoryInMB > 0 {
+			sidecars = append(sidecars, fmt.Sprintf("%s (%s)", sidecar.Name, getFormattedUnit(sidecar.MemoryInMB*1024*1024)))
+		} else {
+			sidecars = append(sidecars, sidecar.Name)
+		}
+	}
+	if len(sidecars) == 0 {
+		return "-"
+	}
+	return strings.Join(sidecars, ",")
+}
+
+/** printSidecars - Print a row per sidecar, with its command, the process types it runs with and its memory, that is part of the memory of those processes. */
+func (a *appsCommand) printSidecars() {
+	var appGuids []string
+	var count, memory int
+	for appGuid, sidecars := range a.sidecars {
+		if len(sidecars) > 0 {
+			appGuids = append(appGuids, appGuid)
+			count += len(sidecars)
+		}
+	}
+	if len(appGuids) == 0 {
+		a.Notice("No sidecars found for space %s", terminal.EntityNameColor(a.CurrentSpace.Name))
+		return
+	}
+	sort.Slice(appGuids, func(i, j int) bool {
+		return strings.ToLower(a.appData[appGuids[i]].Name) < strings.ToLower(a.appData[appGuids[j]].Name)
+	})
+	fmt.Println()
+	table := a.NewTable([]string{colAppName, "Sidecar", "ProcTypes", "Command", colMemory, "Origin"})
+	if a.Flags.HideHeaders {
+		table.NoHeaders()
+	}
+	for _, appGuid := range appGuids {
+		sidecars := a.sidecars[appGuid]
+		sort.Slice(sidecars, func(i, j int) bool { return sidecars[i].Name < sidecars[j].Name })
+		for _, sidecar := range sidecars {
+			sidecarMemory := "-" // no reservation, it shares the memory of the process
+			if sidecar.MemoryInMB > 0 {
+				sidecarMemory = getFormattedUnit(sidecar.MemoryInMB * 1024 * 1024)
+				memory += sidecar.MemoryInMB
+			}
+			table.Add(a.appData[appGuid].Name, sidecar.Name, strings.Join(sidecar.ProcessTypes, ","), sidecar.Command, fmt.Sprintf("%6s", sidecarMemory), sidecar.Origin)
+		}
+	}
+	_ = table.PrintTo(os.Stdout)
+	if a.ShowInfo() {
+		fmt.Printf("\n  %s\n", terminal.StoppedColor(fmt.Sprintf("%d sidecars in %d apps, Memory(MB): %s per instance (part of the Memory of their processes)", count, len(appGuids), getFormattedUnit(memory*1024*1024))))
+	}
+}
+
+/** dropletsRequired - The droplet columns need the droplets, and the Buildpacks column needs them for the image of the docker apps. */
+func (a *appsCommand) dropletsRequired() bool {
+	for _, colName := range DropletColumns {
+		if conf.HasColumn(a.colNames, colName) {
+			return true
+		}
+	}
+	if !conf.HasColumn(a.colNames, colBuildpacks) {
+		return false
+	}
+	for _, app := range a.appData {
+		if getLifecycleType(app.Lifecycle) == lifecycleDocker {
+			return true
+		}
+	}
+	return false
+}
+
+/** getAppDroplets - Get the current droplets of all (filtered) apps, one call per app. */
+func (a *appsCommand) getAppDroplets() {
+	var appGuids []string
+	for appGuid := range a.appData {
+		appGuids = append(appGuids, appGuid)
+	}
+	var err error
+	if a.appDroplets, err = getCurrentDroplets(a.Context, appGuids); err != nil {
+		a.AddFailure(conf.APIError(err, "failed to get droplets"))
+		a.dropletsFailed = true
+	}
+}
+
+/** getPackages - Get the packages of all apps in the space at once, for the PackageType column. */
+func (a *appsCommand) getPackages() {
+	packages, err := a.CfClient.Packages.ListAll(a.CfCtx, &client.PackageListOptions{ListOptions: &client.ListOptions{}, SpaceGUIDs: client.Filter{Values: []string{a.CurrentSpace.Guid}}})
+	if err != nil {
+		a.AddFailure(conf.APIError(err, "failed to get packages"))
+		a.packagesFailed = true
+		return
+	}
+	a.packages = make(map[string]*resource.Package)
+	for _, pkg := range packages {
+		a.packages[pkg.GUID] = pkg
+	}
+}
+
+/** getDropletValue - Get a column value from the current droplet of the app, "-" if the app has no (current) droplet, a red "?" if we failed to get the droplets. */
+func (a *appsCommand) getDropletValue(appGuid string, value func(droplet *resource.Droplet) string) string {
+	if a.dropletsFailed {
+		return terminal.FailureColor("?")
+	}
+	if droplet := a.appDroplets[appGuid]; droplet != nil {
+		return value(droplet)
+	}
+	return "-"
+}
+
+/** getDropletAge - The number of days since the droplet was staged, red if it is older than the droplet_age threshold (and should be restaged to get the buildpack fixes). */
+func (a *appsCommand) getDropletAge(droplet *resource.Droplet) string {
+	days := int(time.Since(droplet.CreatedAt).Hours() / 24)
+	if days > a.Settings.Thresholds.DropletAge {
+		return terminal.FailureColor(fmt.Sprintf("%9dd", days))
+	}
+	return fmt.Sprintf("%9dd", days)
+}
+
+/** getPackageType - The type of the package (bits or docker) the droplet was staged from. */
+func (a *appsCommand) getPackageType(droplet *resource.Droplet) string {
+	if a.packagesFailed {
+		return terminal.FailureColor("?")
+	}
+	// the droplet only links to its package
+	packageHref := droplet.Links["package"].Href
+	if pkg := a.packages[packageHref[strings.LastIndex(packageHref, "/")+1:]]; pkg != nil {
+		return pkg.Type
+	}
+	return "?"
+}
+
+/** getBuildpacks - The buildpacks of the app from its lifecycle (buildpack or cnb), or the image of the droplet for a docker app. */
+func (a *appsCommand) getBuildpacks(app *resource.App) string {
+	switch data := app.Lifecycle.Data.(type) {
+	case *resource.BuildpackLifecycle:
+		return strings.Join(data.Buildpacks, ",")
+	case *resource.CNBLifecycle:
+		return strings.Join(data.Buildpacks, ",")
+	case *resource.DockerLifecycle:
+		if a.dropletsFailed {
+			return terminal.FailureColor("?")
+		}
+		if droplet := a.appDroplets[app.GUID]; droplet != nil && droplet.Image != nil {
+			return *droplet.Image
+		}
+		return dockerImage
+	}
+	return "-"
+}
+
 /** isInstanceColumn - Return true if the given column name is an instance column (and requires us to call the /stats for all processes) */
 func isInstanceColumn(name string) bool {
 	if name == colIx {
@@ -493,39 +836,37 @@ func isInstanceColumn(name string) bool {
 }
 
 /** getProcessStats - Iterate over all processes and get the stats from them (concurrently) */
-func getProcessStats(processes []*resource.Process) map[string]*resource.ProcessStats {
-	concurrencyCounterP = &concurrencyCounter
-	for _, process := range processes {
-		if conf.AppNameRegex.MatchString(appData[process.Relationships.App.Data.GUID].Name) {
+func (a *appsCommand) getProcessStats() {
+	var processes []*resource.Process
+	for _, process := range a.processes {
+		if a.appNameRegex.MatchString(a.appData[process.Relationships.App.Data.GUID].Name) {
 			if !(process.Type == "task" && process.Instances == 0) {
-				atomic.AddInt32(concurrencyCounterP, 1)
-				// throttle a bit:
-				time.Sleep(time.Millisecond * 25 * time.Duration(concurrencyCounter))
-				go getProcessStat(process)
+				processes = append(processes, process)
 			}
 		}
 	}
-
-	// wait for all routines to end:
-	for {
-		time.Sleep(time.Millisecond * 100)
-		if concurrencyCounter == 0 {
-			break
-		}
-	}
-	return processStats
+	forEachThrottled(a.CfCtx, len(processes), func(ix int) {
+		a.getProcessStat(processes[ix])
+	})
 }
 
-/** getProcessStat - Perform a http request to get the stats. This function is called concurrently. */
-func getProcessStat(process *resource.Process) {
-	defer atomic.AddInt32(concurrencyCounterP, -1)
-	if stat, err := conf.CfClient.Processes.GetStats(conf.CfCtx, process.GUID); err != nil {
-		fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get process stats: %s", err)))
-		os.Exit(1)
+/** getProcessStat - Perform a http request to get the stats. This function is called concurrently. A failure is only reported the first time for a process. */
+func (a *appsCommand) getProcessStat(process *resource.Process) {
+	if stat, err := a.CfClient.Processes.GetStats(a.CfCtx, process.GUID); err != nil {
+		a.processMutex.Lock()
+		if a.statsFailures == nil {
+			a.statsFailures = make(map[string]int)
+		}
+		a.statsFailures[process.GUID]++
+		firstFailure := a.statsFailures[process.GUID] == 1
+		a.processMutex.Unlock()
+		if firstFailure {
+			a.AddFailure(conf.APIError(err, "failed to get process stats for app %s", a.appData[process.Relationships.App.Data.GUID].Name))
+		}
 	} else {
-		processMutex.Lock()
-		processStats[process.GUID] = stat
-		processMutex.Unlock()
+		a.processMutex.Lock()
+		a.processStats[process.GUID] = stat
+		a.processMutex.Unlock()
 	}
 }
 
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	pluginmodels "code.cloudfoundry.org/cli/plugin/models"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/metskem/panzer-plugin/cfapi/fake"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+const testSpaceGuid = "space-1"
+
+/** newTestApp - An app in the test space with the given lifecycle (nil is a buildpack app without buildpacks). */
+func newTestApp(guid, name, state string, lifecycle *resource.Lifecycle) *resource.App {
+	app := &resource.App{Name: name, State: state, Resource: resource.Resource{GUID: guid, CreatedAt: time.Now().Add(-48 * time.Hour), UpdatedAt: time.Now().Add(-24 * time.Hour)}}
+	app.Relationships.Space.Data = &resource.Relationship{GUID: testSpaceGuid}
+	app.Lifecycle = resource.Lifecycle{Type: "buildpack", Data: &resource.BuildpackLifecycle{}}
+	if lifecycle != nil {
+		app.Lifecycle = *lifecycle
+	}
+	return app
+}
+
+/** newTestProcess - A process of the app with the given instances and memory (MB), the disk is twice the memory and the log rate is 16K. */
+func newTestProcess(guid, appGuid, processType string, instances, memoryMB int) *resource.Process {
+	process := &resource.Process{Type: processType, Instances: instances, MemoryInMB: memoryMB, DiskInMB: 2 * memoryMB, LogRateLimitInBytesPerSecond: 16 * 1024, Resource: resource.Resource{GUID: guid}}
+	process.Relationships.App.Data = &resource.Relationship{GUID: appGuid}
+	process.HealthCheck.Type = "port"
+	return process
+}
+
+/** newTestStats - The stats of running instances, each using the given memory (MB) and cpu (fraction), and logging 1K per second. */
+func newTestStats(instances, memoryMB int, cpu float64) *resource.ProcessStats {
+	stats := &resource.ProcessStats{}
+	for ix := 0; ix < instances; ix++ {
+		stats.Stats = append(stats.Stats, resource.ProcessStat{Index: ix, State: "RUNNING", Host: "10.0.0." + string(rune('1'+ix)), Uptime: 3600, Usage: resource.Usage{Memory: memoryMB * 1024 * 1024, Disk: memoryMB * 1024 * 1024, CPU: cpu, LogRate: 1024}})
+	}
+	return stats
+}
+
+/** newTestDroplet - A staged droplet of the app, created the given time ago. */
+func newTestDroplet(guid, appGuid string, age time.Duration) *resource.Droplet {
+	droplet := &resource.Droplet{State: resource.DropletState(resource.DropletStateStaged), Resource: resource.Resource{GUID: guid, CreatedAt: time.Now().Add(-age)}}
+	droplet.Relationships.App.Data = &resource.Relationship{GUID: appGuid}
+	return droplet
+}
+
+/** newTestContext - A command context for the test space, backed by the fake. */
+func newTestContext(t *testing.T, f *fake.Fake) *conf.Context {
+	cmdCtx := conf.NewContext(t.TempDir())
+	cmdCtx.SetCfClient(f.Client())
+	cmdCtx.CurrentOrg = pluginmodels.Organization{OrganizationFields: pluginmodels.OrganizationFields{Guid: "org-1", Name: "org1"}}
+	cmdCtx.CurrentSpace = pluginmodels.Space{SpaceFields: pluginmodels.SpaceFields{Guid: testSpaceGuid, Name: "space1"}}
+	cmdCtx.CurrentUser = "tester"
+	return cmdCtx
+}
+
+/** newTestAppsCommand - An appsCommand with the apps, processes and process stats of the fake, like listApps has after getting them. */
+func newTestAppsCommand(t *testing.T, f *fake.Fake, appName string) *appsCommand {
+	a := &appsCommand{Context: newTestContext(t, f), appNameRegex: regexp.MustCompile(appName), appData: make(map[string]*resource.App), processStats: make(map[string]*resource.ProcessStats)}
+	for _, app := range f.Apps {
+		a.appData[app.GUID] = app
+	}
+	a.processes = f.Processes
+	a.getProcessStats()
+	return a
+}
+
+func TestCalculateTotals(t *testing.T) {
+	tests := []struct {
+		name     string
+		fake     *fake.Fake
+		appName  string
+		colNames []string
+		want     appTotals
+		summary  string
+	}{
+		{
+			name: "started app with stats",
+			fake: &fake.Fake{
+				Apps:         []*resource.App{newTestApp("app-1", "app1", "STARTED", nil)},
+				Processes:    []*resource.Process{newTestProcess("proc-1", "app-1", "web", 2, 512)},
+				ProcessStats: map[string]*resource.ProcessStats{"proc-1": newTestStats(2, 256, 0.1)},
+			},
+			colNames: []string{colAppName, colMemUsed},
+			want:     appTotals{apps: 1, appsStarted: 1, instances: 2, memory: 1024, disk: 2048, log: 32768, memoryUsed: 512, diskUsed: 512, logUsed: 2048, cpuUsed: 20},
+			summary:  "1 apps (1 started), 2 running instances, Memory(MB): requested:1024M, used:512M (50%), Cpu   20%, Disk(MB): requested:2048M, used:512M (25%), LogRate(BPS): requested:32K, used:2048 ( 6%)",
+		},
+		{
+			name: "stopped app only counts as app",
+			fake: &fake.Fake{
+				Apps:      []*resource.App{newTestApp("app-1", "app1", "STARTED", nil), newTestApp("app-2", "app2", "STOPPED", nil)},
+				Processes: []*resource.Process{newTestProcess("proc-1", "app-1", "web", 1, 256), newTestProcess("proc-2", "app-2", "web", 3, 1024)},
+			},
+			colNames: []string{colAppName},
+			want:     appTotals{apps: 2, appsStarted: 1, instances: 1, memory: 256, disk: 512, log: 16384},
+			summary:  "2 apps (1 started), 1 running instances, Memory(MB): requested:256M, Cpu    0%, Disk(MB): requested:512M, LogRate(BPS):0",
+		},
+		{
+			name: "task process without instances is skipped",
+			fake: &fake.Fake{
+				Apps:      []*resource.App{newTestApp("app-1", "app1", "STARTED", nil)},
+				Processes: []*resource.Process{newTestProcess("proc-1", "app-1", "web", 1, 256), newTestProcess("proc-2", "app-1", "task", 0, 256)},
+			},
+			colNames: []string{colAppName},
+			want:     appTotals{apps: 1, appsStarted: 1, instances: 1, memory: 256, disk: 512, log: 16384},
+			summary:  "1 apps (1 started), 1 running instances, Memory(MB): requested:256M, Cpu    0%, Disk(MB): requested:512M, LogRate(BPS):0",
+		},
+		{
+			name: "appname filter",
+			fake: &fake.Fake{
+				Apps:      []*resource.App{newTestApp("app-1", "app1", "STARTED", nil), newTestApp("app-2", "other", "STARTED", nil)},
+				Processes: []*resource.Process{newTestProcess("proc-1", "app-1", "web", 1, 256), newTestProcess("proc-2", "app-2", "web", 1, 256)},
+			},
+			appName:  "^app",
+			colNames: []string{colAppName},
+			want:     appTotals{apps: 1, appsStarted: 1, instances: 1, memory: 256, disk: 512, log: 16384},
+			summary:  "1 apps (1 started), 1 running instances, Memory(MB): requested:256M, Cpu    0%, Disk(MB): requested:512M, LogRate(BPS):0",
+		},
+		{
+			name:     "no apps",
+			fake:     &fake.Fake{},
+			colNames: []string{colAppName},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAppsCommand(t, tt.fake, tt.appName)
+			a.calculateTotals()
+			if a.totals != tt.want {
+				t.Errorf("got totals %+v, want %+v", a.totals, tt.want)
+			}
+			if summary := a.getTotals(tt.colNames); summary != tt.summary {
+				t.Errorf("got summary %q, want %q", summary, tt.summary)
+			}
+		})
+	}
+}
+
+func TestGetColValue(t *testing.T) {
+	image := "registry.example.com/team/app:1.2"
+	dockerDroplet := newTestDroplet("droplet-docker", "app-docker", 0)
+	dockerDroplet.Image = &image
+	buildpackDroplet := newTestDroplet("droplet-bp", "app-bp", 10*24*time.Hour)
+	buildpackDroplet.Buildpacks = []resource.DetectedBuildpack{{Name: "java_buildpack", BuildpackName: "java", Version: "4.77.0"}}
+	buildpackDroplet.Links = resource.Links{"package": resource.Link{Href: "https://api.example.com/v3/packages/package-bp"}}
+	buildpackPackage := &resource.Package{Type: "bits", Resource: resource.Resource{GUID: "package-bp"}}
+	buildpackPackage.Relationships.App.Data = &resource.Relationship{GUID: "app-bp"}
+	f := &fake.Fake{
+		Apps: []*resource.App{
+			newTestApp("app-bp", "buildpack-app", "STARTED", &resource.Lifecycle{Type: "buildpack", Data: &resource.BuildpackLifecycle{Buildpacks: []string{"java_buildpack"}, Stack: "cflinuxfs4"}}),
+			newTestApp("app-cnb", "cnb-app", "STARTED", &resource.Lifecycle{Type: "cnb", Data: &resource.CNBLifecycle{Buildpacks: []string{"docker://paketobuildpacks/java", "docker://paketobuildpacks/nodejs"}, Stack: "cflinuxfs4"}}),
+			newTestApp("app-docker", "docker-app", "STARTED", &resource.Lifecycle{Type: "docker", Data: &resource.DockerLifecycle{}}),
+			newTestApp("app-docker-unstaged", "docker-unstaged", "STOPPED", &resource.Lifecycle{Type: "docker", Data: &resource.DockerLifecycle{}}),
+		},
+		Processes: []*resource.Process{
+			newTestProcess("proc-bp", "app-bp", "web", 2, 1024),
+			newTestProcess("proc-cnb", "app-cnb", "web", 1, 512),
+			newTestProcess("proc-docker", "app-docker", "web", 1, 256),
+			newTestProcess("proc-docker-unstaged", "app-docker-unstaged", "web", 1, 256),
+		},
+		ProcessStats:    map[string]*resource.ProcessStats{"proc-bp": newTestStats(2, 512, 0.05)},
+		Droplets:        []*resource.Droplet{newTestDroplet("droplet-bp-old", "app-bp", 20*24*time.Hour), buildpackDroplet, dockerDroplet},
+		Packages:        []*resource.Package{buildpackPackage},
+		CurrentDroplets: map[string]string{"app-bp": "droplet-bp", "app-docker": "droplet-docker"},
+	}
+	tests := []struct {
+		name        string
+		processGuid string
+		colName     string
+		want        string
+	}{
+		{name: "name", processGuid: "proc-bp", colName: colAppName, want: "buildpack-app"},
+		{name: "state stopped", processGuid: "proc-docker-unstaged", colName: colState, want: "stopped"},
+		{name: "memory", processGuid: "proc-bp", colName: colMemory, want: " 1024M"},
+		{name: "instances", processGuid: "proc-bp", colName: colInstances, want: "    2"},
+		{name: "buildpack buildpacks", processGuid: "proc-bp", colName: colBuildpacks, want: "java_buildpack"},
+		{name: "buildpack stack", processGuid: "proc-bp", colName: colStack, want: "cflinuxfs4"},
+		{name: "buildpack lifecycle", processGuid: "proc-bp", colName: colLifecycle, want: "buildpack"},
+		{name: "buildpack versions", processGuid: "proc-bp", colName: colBuildpackVersions, want: "java_buildpack@4.77.0"},
+		{name: "droplet age of the current droplet", processGuid: "proc-bp", colName: colDropletAge, want: "       10d"},
+		{name: "package type", processGuid: "proc-bp", colName: colPackageType, want: "bits"},
+		{name: "cnb buildpacks", processGuid: "proc-cnb", colName: colBuildpacks, want: "docker://paketobuildpacks/java,docker://paketobuildpacks/nodejs"},
+		{name: "cnb stack", processGuid: "proc-cnb", colName: colStack, want: "cflinuxfs4"},
+		{name: "cnb lifecycle", processGuid: "proc-cnb", colName: colLifecycle, want: "      cnb"},
+		{name: "cnb without droplet", processGuid: "proc-cnb", colName: colBuildpackVersions, want: "-"},
+		{name: "docker image of the droplet", processGuid: "proc-docker", colName: colBuildpacks, want: image},
+		{name: "docker without droplet", processGuid: "proc-docker-unstaged", colName: colBuildpacks, want: dockerImage},
+		{name: "docker stack", processGuid: "proc-docker", colName: colStack, want: "-"},
+		{name: "docker lifecycle", processGuid: "proc-docker", colName: colLifecycle, want: "   docker"},
+		{name: "docker buildpack versions", processGuid: "proc-docker", colName: colBuildpackVersions, want: "-"},
+		{name: "instance index", processGuid: "proc-bp", colName: colIx, want: "0\n1"},
+		{name: "instance host", processGuid: "proc-bp", colName: colHost, want: "10.0.0.1\n10.0.0.2"},
+		{name: "instance memory used", processGuid: "proc-bp", colName: colMemUsed, want: "512M (50%)\n512M (50%)"},
+		{name: "instance cpu", processGuid: "proc-bp", colName: colCpu, want: "  5.0\n  5.0"},
+		{name: "instance state", processGuid: "proc-bp", colName: colProcState, want: "running\nrunning"},
+		{name: "stopped app has no instances", processGuid: "proc-docker-unstaged", colName: colHost, want: ""},
+	}
+	a := newTestAppsCommand(t, f, "")
+	a.getAppDroplets()
+	a.getPackages()
+	processes := make(map[string]*resource.Process)
+	for _, process := range f.Processes {
+		processes[process.GUID] = process
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := terminal.Decolorize(a.getColValue(processes[tt.processGuid], tt.colName)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetColValueDropletsFailed(t *testing.T) {
+	f := &fake.Fake{
+		Apps:      []*resource.App{newTestApp("app-docker", "docker-app", "STARTED", &resource.Lifecycle{Type: "docker", Data: &resource.DockerLifecycle{}})},
+		Processes: []*resource.Process{newTestProcess("proc-docker", "app-docker", "web", 1, 256)},
+		Errors:    map[string]error{"Droplets.GetCurrentForApp": errors.New("droplets unavailable")},
+	}
+	a := newTestAppsCommand(t, f, "")
+	a.getAppDroplets()
+	if !a.dropletsFailed || a.PartialError() == nil {
+		t.Fatalf("expected the droplets to fail")
+	}
+	for _, colName := range []string{colBuildpacks, colBuildpackVersions, colStaged} {
+		if got := terminal.Decolorize(a.getColValue(f.Processes[0], colName)); got != "?" {
+			t.Errorf("%s: got %q, want ?", colName, got)
+		}
+	}
+	if got := a.getColValue(f.Processes[0], colStack); got != "-" {
+		t.Errorf("%s: got %q, want -", colStack, got)
+	}
+}
+
+func TestGetRequestedColNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns string
+		cfCols  string
+		want    string
+		wantErr string
+	}{
+		{name: "default", want: strings.Join(DefaultColumns, ",")},
+		{name: "plain list with an instance column gets Ix", columns: "Name,Host", want: "Ix,Name,Host"},
+		{name: "plain list without instance columns", columns: "name,memory", want: "Name,Memory"},
+		{name: "explicit Ix is kept where it is", columns: "Name,Ix,Host", want: "Name,Ix,Host"},
+		{name: "modifiers have no Ix", columns: "+Stack,-Disk", want: "Name,State,Memory,Updated,HealthCheck,#Inst,Host,ProcState,Uptime,Cpu%,MemUsed,Stack"},
+		{name: "modifier adds Ix", columns: "+Ix", want: strings.Join(DefaultColumns, ",") + ",Ix"},
+		{name: "all has no Ix", columns: "ALL", want: strings.Join(ValidColumns, ",")},
+		{name: "CF_COLS envvar", cfCols: "Name,State", want: "Name,State"},
+		{name: "flag before CF_COLS envvar", columns: "Name", cfCols: "Name,State", want: "Name"},
+		{name: "typo", columns: "Name,Stak", wantErr: "unknown column Stak in --columns, did you mean Stack or State ?"},
+		{name: "typo in CF_COLS envvar", cfCols: "Nme", wantErr: "unknown column Nme in CF_COLS envvar, did you mean Name ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CF_COLS", tt.cfCols)
+			a := newTestAppsCommand(t, &fake.Fake{}, "")
+			a.Flags.Columns = tt.columns
+			colNames, err := a.getRequestedColNames()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr || conf.ExitCode(err) != conf.ExitFailure {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := strings.Join(colNames, ","); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRequestedColNamesProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns string
+		profile string
+		cfCols  string
+		want    string
+		wantErr string
+	}{
+		{name: "default profile", want: "Name,State"},
+		{name: "CF_COLS before the default profile", cfCols: "Name,Memory", want: "Name,Memory"},
+		{name: "profile before CF_COLS", profile: "perf", cfCols: "Name,Memory", want: "Ix,Name,Cpu%"},
+		{name: "--columns before the profile", columns: "Name,Disk", profile: "perf", want: "Name,Disk"},
+		{name: "unknown profile", profile: "pref", wantErr: "unknown profile pref, known profiles are: default,perf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CF_COLS", tt.cfCols)
+			a := newTestAppsCommand(t, &fake.Fake{}, "")
+			a.Settings.Profiles = map[string]string{"default": "Name,State", "perf": "Name,Cpu%"}
+			a.Flags.Columns, a.Flags.Profile = tt.columns, tt.profile
+			colNames, err := a.getRequestedColNames()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr || conf.ExitCode(err) != conf.ExitFailure {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := strings.Join(colNames, ","); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
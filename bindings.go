@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+var bindingColNames = []string{"app", "service instance", "offering", "plan", "binding", "last operation"}
+
+// appBinding is one service binding of an app, with the bound service instance (nil if we can't see it, like when it is shared from a space we have no access to).
+type appBinding struct {
+	binding  *resource.ServiceCredentialBinding
+	instance *resource.ServiceInstance
+}
+
+/** newBindingsFlagParser - Create the flag parser for "cf bindings", also used to generate the shell completion. */
+func newBindingsFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("bindings", flags)
+	parser.String(&flags.AppName, "a", "appname", "Filter the output by the given appname (regular expression)")
+	parser.String(&flags.Scope, "", "scope", "Which apps to show: space (the targeted space, default), org (all spaces of the targeted org) or all")
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers (and summary) of the output (handy for automated processing), default is false")
+	return parser
+}
+
+/** listBindings - The main function to produce the response to list the service bindings of the apps. */
+func listBindings(cmdCtx *conf.Context, args []string) error {
+	cmdCtx.Flags.Scope = conf.ScopeSpace
+	if err := cmdCtx.ParseFlags(newBindingsFlagParser(&cmdCtx.Flags), args); err != nil {
+		return err
+	}
+	orgGuids, spaceGuids, err := cmdCtx.ScopeFilters()
+	if err != nil {
+		return err
+	}
+	appNameRegex, err := regexp.Compile(cmdCtx.Flags.AppName)
+	if err != nil {
+		return conf.UsageError("invalid appname filter %s: %s", cmdCtx.Flags.AppName, err)
+	}
+	if cmdCtx.ShowInfo() {
+		fmt.Printf("Getting service bindings of the apps for %s as %s...\n\n", cmdCtx.ScopeDescription(), terminal.EntityNameColor(cmdCtx.CurrentUser))
+	}
+	unfilteredApps, err := cmdCtx.CfClient.Applications.ListAll(cmdCtx.CfCtx, &client.AppListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return conf.APIError(err, "failed to get apps")
+	}
+	var apps []*resource.App
+	var appGuids []string
+	for _, app := range unfilteredApps {
+		if appNameRegex.MatchString(app.Name) {
+			apps = append(apps, app)
+			appGuids = append(appGuids, app.GUID)
+		}
+	}
+	if len(apps) == 0 {
+		cmdCtx.Notice("no apps found")
+		return nil
+	}
+	appBindings, err := getAppBindings(cmdCtx, appGuids)
+	if err != nil {
+		return conf.APIError(err, "failed to get service bindings")
+	}
+
+	colNames := bindingColNames
+	spaceNames, orgNames := make(map[string]string), make(map[string]string) // keyed by app guid
+	if cmdCtx.Flags.Scope != conf.ScopeSpace {
+		colNames = append([]string{"space"}, colNames...)
+		if cmdCtx.Flags.Scope == conf.ScopeAll {
+			colNames = append([]string{"org"}, colNames...)
+		}
+		for _, app := range apps {
+			spaceNames[app.GUID], orgNames[app.GUID] = getAppLocation(cmdCtx, app, cmdCtx.Flags.Scope == conf.ScopeAll)
+		}
+	}
+	sort.Slice(apps, func(i, j int) bool {
+		iGuid, jGuid := apps[i].GUID, apps[j].GUID
+		if orgNames[iGuid] != orgNames[jGuid] {
+			return orgNames[iGuid] < orgNames[jGuid]
+		}
+		if spaceNames[iGuid] != spaceNames[jGuid] {
+			return spaceNames[iGuid] < spaceNames[jGuid]
+		}
+		return strings.ToLower(apps[i].Name) < strings.ToLower(apps[j].Name)
+	})
+
+	table := cmdCtx.NewTable(colNames)
+	if cmdCtx.Flags.HideHeaders {
+		table.NoHeaders()
+	}
+	var boundApps, bindings, failed int
+	for _, app := range apps {
+		if cmdCtx.CfCtx.Err() != nil {
+			return nil // cancelled, Run reports why
+		}
+		if len(appBindings[app.GUID]) > 0 {
+			boundApps++
+		}
+		for _, appBinding := range appBindings[app.GUID] {
+			details := serviceDetails{plan: "?", offering: "?"}
+			if appBinding.instance != nil {
+				details = getServiceDetails(cmdCtx, appBinding.instance)
+			}
+			bindingName := "-"
+			if appBinding.binding.Name != nil && *appBinding.binding.Name != "" {
+				bindingName = *appBinding.binding.Name
+			}
+			var colValues []string
+			if cmdCtx.Flags.Scope == conf.ScopeAll {
+				colValues = append(colValues, orgNames[app.GUID])
+			}
+			if cmdCtx.Flags.Scope != conf.ScopeSpace {
+				colValues = append(colValues, spaceNames[app.GUID])
+			}
+			colValues = append(colValues, app.Name, appBinding.instanceName(), details.offering, details.plan, bindingName, getLastOperation(appBinding.binding.LastOperation))
+			table.Add(colValues...)
+			bindings++
+			if appBinding.binding.LastOperation.State == "failed" {
+				failed++
+			}
+		}
+	}
+	if bindings == 0 {
+		cmdCtx.Notice("no service bindings found")
+		return nil
+	}
+	_ = table.PrintTo(os.Stdout)
+	if cmdCtx.ShowInfo() {
+		summary := fmt.Sprintf("%d bindings, %d of %d apps have bindings", bindings, boundApps, len(apps))
+		if failed > 0 {
+			fmt.Printf("\n  %s, %s\n", terminal.StoppedColor(summary), terminal.FailureColor(fmt.Sprintf("%d failed", failed)))
+		} else {
+			fmt.Printf("\n  %s\n", terminal.StoppedColor(summary))
+		}
+	}
+	return nil
+}
+
+/** getAppBindings - Get the service bindings of the given apps with their service instances, keyed by app guid, and sorted by service instance name. */
+func getAppBindings(cmdCtx *conf.Context, appGuids []string) (map[string][]appBinding, error) {
+	bindings, err := listCredentialBindings(cmdCtx, appGuids, func(opts *client.ServiceCredentialBindingListOptions, guids []string) {
+		opts.AppGUIDs = client.Filter{Values: guids}
+		opts.Type = client.Filter{Values: []string{"app"}}
+	})
+	if err != nil {
+		return nil, err
+	}
+	var instanceGuids []string
+	seen := make(map[string]bool)
+	for _, binding := range bindings {
+		if binding.Relationships.ServiceInstance != nil && binding.Relationships.ServiceInstance.Data != nil && !seen[binding.Relationships.ServiceInstance.Data.GUID] {
+			seen[binding.Relationships.ServiceInstance.Data.GUID] = true
+			instanceGuids = append(instanceGuids, binding.Relationships.ServiceInstance.Data.GUID)
+		}
+	}
+	instances, err := listServiceInstancesByGuid(cmdCtx, instanceGuids)
+	if err != nil {
+		return nil, err
+	}
+	appBindings := make(map[string][]appBinding)
+	for _, binding := range bindings {
+		if binding.Relationships.App == nil || binding.Relationships.App.Data == nil {
+			continue
+		}
+		appBinding := appBinding{binding: binding}
+		if binding.Relationships.ServiceInstance != nil && binding.Relationships.ServiceInstance.Data != nil {
+			appBinding.instance = instances[binding.Relationships.ServiceInstance.Data.GUID]
+		}
+		appBindings[binding.Relationships.App.Data.GUID] = append(appBindings[binding.Relationships.App.Data.GUID], appBinding)
+	}
+	for _, bindings := range appBindings {
+		sort.Slice(bindings, func(i, j int) bool {
+			return strings.ToLower(bindings[i].instanceName()) < strings.ToLower(bindings[j].instanceName())
+		})
+	}
+	return appBindings, nil
+}
+
+/** instanceName - The name of the bound service instance, or its guid if we can't see the service instance. */
+func (b appBinding) instanceName() string {
+	if b.instance != nil {
+		return b.instance.Name
+	}
+	if b.binding.Relationships.ServiceInstance != nil && b.binding.Relationships.ServiceInstance.Data != nil {
+		return b.binding.Relationships.ServiceInstance.Data.GUID
+	}
+	return "?"
+}
+
+/** getAppLocation - Look up the space name, and if withOrg also the org name, of the app ("?" if the lookup failed). */
+func getAppLocation(cmdCtx *conf.Context, app *resource.App, withOrg bool) (spaceName, orgName string) {
+	spaceName, orgName = "?", "?"
+	space, err := cmdCtx.Resolver.GetSpace(app.Relationships.Space.Data.GUID)
+	if err != nil {
+		cmdCtx.AddFailure(conf.APIError(err, "failed to get space for app %s", app.Name))
+		return spaceName, orgName
+	}
+	spaceName = space.Name
+	if !withOrg {
+		return spaceName, orgName
+	}
+	org, err := cmdCtx.Resolver.GetOrg(space.Relationships.Organization.Data.GUID)
+	if err != nil {
+		cmdCtx.AddFailure(conf.APIError(err, "failed to get org for app %s", app.Name))
+		return spaceName, orgName
+	}
+	return spaceName, org.Name
+}
@@ -0,0 +1,290 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/metskem/panzer-plugin/cfapi"
+)
+
+const (
+	// TTLEnvVar is the envvar that enables the on-disk cache, it holds a duration like "10m" or "24h"
+	TTLEnvVar = "CF_PANZER_CACHE_TTL"
+	// cacheFile is the name of the cache file, with a hash of the API endpoint and the user, see cachePath
+	cacheFile = "panzer-cache-%s.json"
+)
+
+type entry[T any] struct {
+	CachedAt time.Time `json:"cached_at"`
+	Value    T         `json:"value"`
+}
+
+// store holds everything we cache, both in-process and (optionally) on disk.
+type store struct {
+	Domains    map[string]entry[*resource.Domain]          `json:"domains"`
+	Spaces     map[string]entry[*resource.Space]           `json:"spaces"`
+	Orgs       map[string]entry[*resource.Organization]    `json:"orgs"`
+	OrgGuids   map[string]entry[string]                    `json:"org_guids"`
+	SpaceGuids map[string]entry[string]                    `json:"space_guids"`
+	Names      map[string]entry[[]string]                  `json:"names"` // the org, space and app names for the shell completion
+	Plans      map[string]entry[*resource.ServicePlan]     `json:"service_plans"`
+	Offerings  map[string]entry[*resource.ServiceOffering] `json:"service_offerings"`
+	Brokers    map[string]entry[*resource.ServiceBroker]   `json:"service_brokers"`
+}
+
+// Resolver resolves guids (and org/space names) to resources, it does one API call per unique guid or name.
+// With Load and Save, the resolved resources are also kept on disk (only if CF_PANZER_CACHE_TTL has been set).
+// There is a cache file per API endpoint and user, so after a "cf api" or "cf login" we never return the names and guids of another foundation or user.
+type Resolver struct {
+	cfClient  *cfapi.Client
+	ctx       context.Context
+	cfHomeDir string
+	scope     string
+	mutex     sync.Mutex
+	keyLocks  map[string]*sync.Mutex // one per key being looked up, so concurrent lookups of a key do one API call
+	ttl       time.Duration
+	dirty     bool
+	data      *store
+}
+
+// New - Create a resolver that uses the given client for lookups that are not cached yet.
+func New(ctx context.Context, cfClient *cfapi.Client, cfHomeDir string) *Resolver {
+	return &Resolver{cfClient: cfClient, ctx: ctx, cfHomeDir: cfHomeDir, keyLocks: make(map[string]*sync.Mutex), data: newStore()}
+}
+
+func newStore() *store {
+	return &store{
+		Domains:    make(map[string]entry[*resource.Domain]),
+		Spaces:     make(map[string]entry[*resource.Space]),
+		Orgs:       make(map[string]entry[*resource.Organization]),
+		OrgGuids:   make(map[string]entry[string]),
+		SpaceGuids: make(map[string]entry[string]),
+		Names:      make(map[string]entry[[]string]),
+		Plans:      make(map[string]entry[*resource.ServicePlan]),
+		Offerings:  make(map[string]entry[*resource.ServiceOffering]),
+		Brokers:    make(map[string]entry[*resource.ServiceBroker]),
+	}
+}
+
+// Load - Read the on-disk cache of the given API endpoint and user, only if the envvar CF_PANZER_CACHE_TTL has been set, or else defaultTTL is not zero.
+// Entries older than the TTL are dropped. Without an API endpoint or user guid the on-disk cache is not used.
+func (r *Resolver) Load(defaultTTL time.Duration, apiEndpoint, userGuid string) {
+	if apiEndpoint == "" || userGuid == "" {
+		r.ttl = 0
+		return
+	}
+	r.scope = apiEndpoint + "\n" + userGuid
+	r.ttl = defaultTTL
+	if ttlStr := os.Getenv(TTLEnvVar); ttlStr != "" {
+		var err error
+		if r.ttl, err = time.ParseDuration(ttlStr); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring invalid %s envvar (%s): %s\n", TTLEnvVar, ttlStr, err)
+			r.ttl = defaultTTL
+		}
+	}
+	if r.ttl <= 0 {
+		r.ttl = 0
+		return
+	}
+	fileContents, err := os.ReadFile(r.cachePath())
+	if err != nil {
+		return // no cache yet
+	}
+	loaded := newStore()
+	if err = json.Unmarshal(fileContents, loaded); err != nil {
+		return // corrupt cache, it will be overwritten on the next Save
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.data.Domains = expire(loaded.Domains, r.ttl)
+	r.data.Spaces = expire(loaded.Spaces, r.ttl)
+	r.data.Orgs = expire(loaded.Orgs, r.ttl)
+	r.data.OrgGuids = expire(loaded.OrgGuids, r.ttl)
+	r.data.SpaceGuids = expire(loaded.SpaceGuids, r.ttl)
+	r.data.Names = expire(loaded.Names, r.ttl)
+	r.data.Plans = expire(loaded.Plans, r.ttl)
+	r.data.Offerings = expire(loaded.Offerings, r.ttl)
+	r.data.Brokers = expire(loaded.Brokers, r.ttl)
+}
+
+// Save - Write the cache to disk, only if the on-disk cache is enabled and something was added to it.
+func (r *Resolver) Save() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.ttl == 0 || !r.dirty {
+		return
+	}
+	if fileContents, err := json.Marshal(r.data); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to marshal cache: %s\n", err)
+	} else {
+		if err = os.WriteFile(r.cachePath(), fileContents, 0600); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write cache file %s: %s\n", r.cachePath(), err)
+		}
+	}
+}
+
+// GetDomain - Get the domain with the given guid, from the cache if possible.
+func (r *Resolver) GetDomain(guid string) (*resource.Domain, error) {
+	return lookup(r, r.data.Domains, guid, func() (*resource.Domain, error) {
+		return r.cfClient.Domains.Get(r.ctx, guid)
+	})
+}
+
+// GetSpace - Get the space with the given guid, from the cache if possible.
+func (r *Resolver) GetSpace(guid string) (*resource.Space, error) {
+	return lookup(r, r.data.Spaces, guid, func() (*resource.Space, error) {
+		return r.cfClient.Spaces.Get(r.ctx, guid)
+	})
+}
+
+// GetOrg - Get the organization with the given guid, from the cache if possible.
+func (r *Resolver) GetOrg(guid string) (*resource.Organization, error) {
+	return lookup(r, r.data.Orgs, guid, func() (*resource.Organization, error) {
+		return r.cfClient.Organizations.Get(r.ctx, guid)
+	})
+}
+
+// GetOrgGuid - Get the organization guid, given the organization name, from the cache if possible.
+func (r *Resolver) GetOrgGuid(orgName string) (string, error) {
+	return lookup(r, r.data.OrgGuids, orgName, func() (string, error) {
+		org, err := r.cfClient.Organizations.Single(r.ctx, &client.OrganizationListOptions{ListOptions: &client.ListOptions{}, Names: client.Filter{Values: []string{orgName}}})
+		if err != nil {
+			return "", err
+		}
+		return org.GUID, nil
+	})
+}
+
+// GetSpaceGuid - Get the space guid, given the organization guid and space name, from the cache if possible.
+func (r *Resolver) GetSpaceGuid(orgGuid, spaceName string) (string, error) {
+	return lookup(r, r.data.SpaceGuids, orgGuid+"/"+spaceName, func() (string, error) {
+		spaceListOptions := client.SpaceListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: client.Filter{Values: []string{orgGuid}}, Names: client.Filter{Values: []string{spaceName}}}
+		space, err := r.cfClient.Spaces.Single(r.ctx, &spaceListOptions)
+		if err != nil {
+			return "", err
+		}
+		return space.GUID, nil
+	})
+}
+
+// GetServicePlan - Get the service plan with the given guid, from the cache if possible.
+func (r *Resolver) GetServicePlan(guid string) (*resource.ServicePlan, error) {
+	return lookup(r, r.data.Plans, guid, func() (*resource.ServicePlan, error) {
+		return r.cfClient.ServicePlans.Get(r.ctx, guid)
+	})
+}
+
+// GetServiceOffering - Get the service offering with the given guid, from the cache if possible.
+func (r *Resolver) GetServiceOffering(guid string) (*resource.ServiceOffering, error) {
+	return lookup(r, r.data.Offerings, guid, func() (*resource.ServiceOffering, error) {
+		return r.cfClient.ServiceOfferings.Get(r.ctx, guid)
+	})
+}
+
+// GetServiceBroker - Get the service broker with the given guid, from the cache if possible.
+func (r *Resolver) GetServiceBroker(guid string) (*resource.ServiceBroker, error) {
+	return lookup(r, r.data.Brokers, guid, func() (*resource.ServiceBroker, error) {
+		return r.cfClient.ServiceBrokers.Get(r.ctx, guid)
+	})
+}
+
+// GetOrgNames - Get the names of all organizations visible to the user, from the cache if possible.
+func (r *Resolver) GetOrgNames() ([]string, error) {
+	return lookup(r, r.data.Names, "orgs", func() ([]string, error) {
+		orgs, err := r.cfClient.Organizations.ListAll(r.ctx, &client.OrganizationListOptions{ListOptions: &client.ListOptions{}})
+		return names(orgs, func(org *resource.Organization) string { return org.Name }), err
+	})
+}
+
+// GetSpaceNames - Get the names of the spaces in the given organization, from the cache if possible.
+func (r *Resolver) GetSpaceNames(orgGuid string) ([]string, error) {
+	return lookup(r, r.data.Names, "spaces/"+orgGuid, func() ([]string, error) {
+		spaces, err := r.cfClient.Spaces.ListAll(r.ctx, &client.SpaceListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: client.Filter{Values: []string{orgGuid}}})
+		return names(spaces, func(space *resource.Space) string { return space.Name }), err
+	})
+}
+
+// GetAppNames - Get the names of the apps in the given space, from the cache if possible.
+func (r *Resolver) GetAppNames(spaceGuid string) ([]string, error) {
+	return lookup(r, r.data.Names, "apps/"+spaceGuid, func() ([]string, error) {
+		apps, err := r.cfClient.Applications.ListAll(r.ctx, &client.AppListOptions{ListOptions: &client.ListOptions{}, SpaceGUIDs: client.Filter{Values: []string{spaceGuid}}})
+		return names(apps, func(app *resource.App) string { return app.Name }), err
+	})
+}
+
+/** names - Return the sorted names of the given resources. */
+func names[T any](resources []T, nameOf func(T) string) []string {
+	var result []string
+	for _, res := range resources {
+		result = append(result, nameOf(res))
+	}
+	sort.Strings(result)
+	return result
+}
+
+/** lookup - Return the cached value for the key, or call fetch and cache its result. Failed fetches are not cached, concurrent lookups of the same key wait for the first one. */
+func lookup[T any](r *Resolver, entries map[string]entry[T], key string, fetch func() (T, error)) (T, error) {
+	if cached, found := cachedEntry(r, entries, key); found {
+		return cached.Value, nil
+	}
+	keyLock := r.keyLock(fmt.Sprintf("%p/%s", entries, key))
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	if cached, found := cachedEntry(r, entries, key); found {
+		return cached.Value, nil
+	}
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+	r.mutex.Lock()
+	entries[key] = entry[T]{CachedAt: time.Now(), Value: value}
+	r.dirty = true
+	r.mutex.Unlock()
+	return value, nil
+}
+
+/** cachedEntry - Return the cached entry for the key, if any. */
+func cachedEntry[T any](r *Resolver, entries map[string]entry[T], key string) (entry[T], bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	cached, found := entries[key]
+	return cached, found
+}
+
+/** keyLock - Return the lock for the given key, the entries map is part of the key because the same key can be in more than one map. */
+func (r *Resolver) keyLock(key string) *sync.Mutex {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, found := r.keyLocks[key]; !found {
+		r.keyLocks[key] = &sync.Mutex{}
+	}
+	return r.keyLocks[key]
+}
+
+/** expire - Return only the entries that are younger than the TTL. */
+func expire[T any](entries map[string]entry[T], ttl time.Duration) map[string]entry[T] {
+	valid := make(map[string]entry[T])
+	for key, cached := range entries {
+		if time.Since(cached.CachedAt) < ttl {
+			valid[key] = cached
+		}
+	}
+	return valid
+}
+
+/** cachePath - The cache file for the API endpoint and user, the hash keeps the file name short and does not reveal them. */
+func (r *Resolver) cachePath() string {
+	hash := sha256.Sum256([]byte(r.scope))
+	return filepath.Join(r.cfHomeDir, ".cf", fmt.Sprintf(cacheFile, hex.EncodeToString(hash[:8])))
+}
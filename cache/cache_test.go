@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/metskem/panzer-plugin/cfapi"
+	"github.com/metskem/panzer-plugin/cfapi/fake"
+)
+
+// countingDomains counts the Get calls of the wrapped DomainsAPI.
+type countingDomains struct {
+	cfapi.DomainsAPI
+	calls atomic.Int32
+}
+
+func (d *countingDomains) Get(ctx context.Context, guid string) (*resource.Domain, error) {
+	d.calls.Add(1)
+	time.Sleep(10 * time.Millisecond) // give concurrent lookups of the same guid the chance to overlap
+	return d.DomainsAPI.Get(ctx, guid)
+}
+
+/** newTestResolver - A resolver backed by a fake with two domains, and the counter of its Domains.Get calls. */
+func newTestResolver(t *testing.T, cfHomeDir string) (*Resolver, *countingDomains) {
+	t.Helper()
+	f := &fake.Fake{Domains: []*resource.Domain{
+		{Name: "example.com", Resource: resource.Resource{GUID: "domain-1"}},
+		{Name: "example.org", Resource: resource.Resource{GUID: "domain-2"}},
+	}}
+	cfClient := f.Client()
+	counter := &countingDomains{DomainsAPI: cfClient.Domains}
+	cfClient.Domains = counter
+	return New(context.Background(), cfClient, cfHomeDir), counter
+}
+
+/** newTestCfHomeDir - A CF_HOME with a .cf directory for the cache files. */
+func newTestCfHomeDir(t *testing.T) string {
+	t.Helper()
+	cfHomeDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(cfHomeDir, ".cf"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return cfHomeDir
+}
+
+func TestLookupOneCallPerGuid(t *testing.T) {
+	resolver, counter := newTestResolver(t, t.TempDir())
+	var wg sync.WaitGroup
+	for ix := 0; ix < 10; ix++ {
+		for _, guid := range []string{"domain-1", "domain-2"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := resolver.GetDomain(guid); err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	domain, err := resolver.GetDomain("domain-2")
+	if err != nil || domain.Name != "example.org" {
+		t.Fatalf("got %v (%v), want example.org", domain, err)
+	}
+	if calls := counter.calls.Load(); calls != 2 {
+		t.Errorf("got %d Domains.Get calls, want 2", calls)
+	}
+}
+
+func TestLookupFailureIsNotCached(t *testing.T) {
+	resolver, counter := newTestResolver(t, t.TempDir())
+	for ix := 0; ix < 2; ix++ {
+		if _, err := resolver.GetDomain("unknown"); err == nil {
+			t.Fatal("expected an error for an unknown domain")
+		}
+	}
+	if calls := counter.calls.Load(); calls != 2 {
+		t.Errorf("got %d Domains.Get calls, want 2", calls)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		ttl         string
+		apiEndpoint string
+		userGuid    string
+		wantCalls   int32 // the Domains.Get calls of a second resolver
+	}{
+		{name: "cached on disk", ttl: "1h", apiEndpoint: "https://api.example.com", userGuid: "user-1", wantCalls: 0},
+		{name: "no ttl, no disk cache", ttl: "", apiEndpoint: "https://api.example.com", userGuid: "user-1", wantCalls: 1},
+		{name: "invalid ttl", ttl: "forever", apiEndpoint: "https://api.example.com", userGuid: "user-1", wantCalls: 1},
+		{name: "other API endpoint", ttl: "1h", apiEndpoint: "https://api.other.com", userGuid: "user-1", wantCalls: 1},
+		{name: "other user", ttl: "1h", apiEndpoint: "https://api.example.com", userGuid: "user-2", wantCalls: 1},
+		{name: "without user", ttl: "1h", apiEndpoint: "https://api.example.com", userGuid: "", wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(TTLEnvVar, tt.ttl)
+			cfHomeDir := newTestCfHomeDir(t)
+			first, _ := newTestResolver(t, cfHomeDir)
+			first.Load(0, "https://api.example.com", "user-1")
+			if _, err := first.GetDomain("domain-1"); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			first.Save()
+
+			second, counter := newTestResolver(t, cfHomeDir)
+			second.Load(0, tt.apiEndpoint, tt.userGuid)
+			if domain, err := second.GetDomain("domain-1"); err != nil || domain.Name != "example.com" {
+				t.Fatalf("got %v (%v), want example.com", domain, err)
+			}
+			if calls := counter.calls.Load(); calls != tt.wantCalls {
+				t.Errorf("got %d Domains.Get calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLoadExpired(t *testing.T) {
+	t.Setenv(TTLEnvVar, "1h")
+	cfHomeDir := newTestCfHomeDir(t)
+	resolver, counter := newTestResolver(t, cfHomeDir)
+	resolver.Load(0, "https://api.example.com", "user-1")
+	data := newStore()
+	data.Domains["domain-1"] = entry[*resource.Domain]{CachedAt: time.Now().Add(-2 * time.Hour), Value: &resource.Domain{Name: "expired.com"}}
+	data.Domains["domain-2"] = entry[*resource.Domain]{CachedAt: time.Now().Add(-time.Minute), Value: &resource.Domain{Name: "cached.org"}}
+	fileContents, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(resolver.cachePath(), fileContents, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver.Load(0, "https://api.example.com", "user-1")
+	if domain, _ := resolver.GetDomain("domain-1"); domain == nil || domain.Name != "example.com" {
+		t.Errorf("got %v for the expired entry, want example.com from the API", domain)
+	}
+	if domain, _ := resolver.GetDomain("domain-2"); domain == nil || domain.Name != "cached.org" {
+		t.Errorf("got %v for the valid entry, want cached.org from the cache", domain)
+	}
+	if calls := counter.calls.Load(); calls != 1 {
+		t.Errorf("got %d Domains.Get calls, want 1", calls)
+	}
+}
+
+func TestLoadCorruptFile(t *testing.T) {
+	t.Setenv(TTLEnvVar, "1h")
+	cfHomeDir := newTestCfHomeDir(t)
+	resolver, counter := newTestResolver(t, cfHomeDir)
+	resolver.Load(0, "https://api.example.com", "user-1")
+	if err := os.WriteFile(resolver.cachePath(), []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver.Load(0, "https://api.example.com", "user-1")
+	if domain, err := resolver.GetDomain("domain-1"); err != nil || domain.Name != "example.com" {
+		t.Fatalf("got %v (%v), want example.com", domain, err)
+	}
+	if calls := counter.calls.Load(); calls != 1 {
+		t.Errorf("got %d Domains.Get calls, want 1", calls)
+	}
+	resolver.Save()
+	fileContents, err := os.ReadFile(resolver.cachePath())
+	if err != nil || !json.Valid(fileContents) {
+		t.Errorf("the corrupt cache file was not overwritten: %s (%v)", fileContents, err)
+	}
+}
@@ -0,0 +1,148 @@
+package cfapi
+
+import (
+	"context"
+
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+)
+
+// Client holds the (narrow) interfaces for the CF API operations used by the plugin.
+// Use New to wrap a real go-cfclient, or the fake package to get an in-memory implementation for offline testing.
+type Client struct {
+	Applications              AppsAPI
+	AuditEvents               AuditEventsAPI
+	Deployments               DeploymentsAPI
+	Domains                   DomainsAPI
+	Droplets                  DropletsAPI
+	OrganizationQuotas        OrganizationQuotasAPI
+	Organizations             OrganizationsAPI
+	Packages                  PackagesAPI
+	Processes                 ProcessesAPI
+	RouterGroups              RouterGroupsAPI
+	Routes                    RoutesAPI
+	ServiceBrokers            ServiceBrokersAPI
+	ServiceCredentialBindings ServiceCredentialBindingsAPI
+	ServiceInstances          ServiceInstancesAPI
+	ServiceOfferings          ServiceOfferingsAPI
+	ServicePlans              ServicePlansAPI
+	Sidecars                  SidecarsAPI
+	SpaceQuotas               SpaceQuotasAPI
+	Spaces                    SpacesAPI
+	Tasks                     TasksAPI
+}
+
+type AppsAPI interface {
+	Get(ctx context.Context, guid string) (*resource.App, error)
+	ListAll(ctx context.Context, opts *client.AppListOptions) ([]*resource.App, error)
+}
+
+type AuditEventsAPI interface {
+	List(ctx context.Context, opts *client.AuditEventListOptions) ([]*resource.AuditEvent, *client.Pager, error)
+}
+
+type DeploymentsAPI interface {
+	ListAll(ctx context.Context, opts *client.DeploymentListOptions) ([]*Deployment, error)
+}
+
+type DomainsAPI interface {
+	Get(ctx context.Context, guid string) (*resource.Domain, error)
+	ListAll(ctx context.Context, opts *client.DomainListOptions) ([]*resource.Domain, error)
+}
+
+type DropletsAPI interface {
+	GetCurrentForApp(ctx context.Context, appGUID string) (*resource.Droplet, error)
+}
+
+type OrganizationQuotasAPI interface {
+	Get(ctx context.Context, guid string) (*resource.OrganizationQuota, error)
+}
+
+type OrganizationsAPI interface {
+	Get(ctx context.Context, guid string) (*resource.Organization, error)
+	ListAll(ctx context.Context, opts *client.OrganizationListOptions) ([]*resource.Organization, error)
+	Single(ctx context.Context, opts *client.OrganizationListOptions) (*resource.Organization, error)
+}
+
+type PackagesAPI interface {
+	ListAll(ctx context.Context, opts *client.PackageListOptions) ([]*resource.Package, error)
+}
+
+type ProcessesAPI interface {
+	GetStats(ctx context.Context, guid string) (*resource.ProcessStats, error)
+	ListAll(ctx context.Context, opts *client.ProcessListOptions) ([]*resource.Process, error)
+}
+
+type RoutesAPI interface {
+	List(ctx context.Context, opts *client.RouteListOptions) ([]*resource.Route, *client.Pager, error)
+	ListAll(ctx context.Context, opts *client.RouteListOptions) ([]*resource.Route, error)
+}
+
+type RouterGroupsAPI interface {
+	ListAll(ctx context.Context) ([]*RouterGroup, error)
+}
+
+type ServiceBrokersAPI interface {
+	Get(ctx context.Context, guid string) (*resource.ServiceBroker, error)
+}
+
+type ServiceCredentialBindingsAPI interface {
+	ListAll(ctx context.Context, opts *client.ServiceCredentialBindingListOptions) ([]*resource.ServiceCredentialBinding, error)
+}
+
+type ServiceInstancesAPI interface {
+	ListAll(ctx context.Context, opts *client.ServiceInstanceListOptions) ([]*resource.ServiceInstance, error)
+}
+
+type ServiceOfferingsAPI interface {
+	Get(ctx context.Context, guid string) (*resource.ServiceOffering, error)
+}
+
+type ServicePlansAPI interface {
+	Get(ctx context.Context, guid string) (*resource.ServicePlan, error)
+}
+
+type SidecarsAPI interface {
+	ListForAppAll(ctx context.Context, appGUID string, opts *client.SidecarListOptions) ([]*resource.Sidecar, error)
+}
+
+type SpaceQuotasAPI interface {
+	Get(ctx context.Context, guid string) (*resource.SpaceQuota, error)
+	ListAll(ctx context.Context, opts *client.SpaceQuotaListOptions) ([]*resource.SpaceQuota, error)
+}
+
+type SpacesAPI interface {
+	Get(ctx context.Context, guid string) (*resource.Space, error)
+	ListAll(ctx context.Context, opts *client.SpaceListOptions) ([]*resource.Space, error)
+	Single(ctx context.Context, opts *client.SpaceListOptions) (*resource.Space, error)
+}
+
+type TasksAPI interface {
+	ListAll(ctx context.Context, opts *client.TaskListOptions) ([]*resource.Task, error)
+}
+
+// New - Wrap the given go-cfclient client, its sub clients satisfy the interfaces, except for the deployments (see Deployment).
+func New(cfClient *client.Client) *Client {
+	return &Client{
+		Applications:              cfClient.Applications,
+		AuditEvents:               cfClient.AuditEvents,
+		Deployments:               deploymentsClient{cfClient},
+		Domains:                   cfClient.Domains,
+		Droplets:                  cfClient.Droplets,
+		OrganizationQuotas:        cfClient.OrganizationQuotas,
+		Organizations:             cfClient.Organizations,
+		Packages:                  cfClient.Packages,
+		Processes:                 cfClient.Processes,
+		RouterGroups:              routerGroupsClient{cfClient},
+		Routes:                    cfClient.Routes,
+		ServiceBrokers:            cfClient.ServiceBrokers,
+		ServiceCredentialBindings: cfClient.ServiceCredentialBindings,
+		ServiceInstances:          cfClient.ServiceInstances,
+		ServiceOfferings:          cfClient.ServiceOfferings,
+		ServicePlans:              cfClient.ServicePlans,
+		Sidecars:                  cfClient.Sidecars,
+		SpaceQuotas:               cfClient.SpaceQuotas,
+		Spaces:                    cfClient.Spaces,
+		Tasks:                     cfClient.Tasks,
+	}
+}
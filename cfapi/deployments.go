@@ -0,0 +1,117 @@
+package cfapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+)
+
+// Deployment is a go-cfclient deployment with the canary status, which resource.Deployment does not have (yet).
+type Deployment struct {
+	*resource.Deployment
+	Canary *CanaryStatus
+}
+
+// CanaryStatus is the progress of a canary deployment with steps (status.canary in the CF API), nil for other deployments.
+type CanaryStatus struct {
+	Steps CanarySteps `json:"steps"`
+}
+
+type CanarySteps struct {
+	Current int `json:"current"`
+	Total   int `json:"total"`
+}
+
+// deploymentStatus is the part of a deployment that holds the canary status.
+type deploymentStatus struct {
+	Status struct {
+		Canary *CanaryStatus `json:"canary,omitempty"`
+	} `json:"status"`
+}
+
+// UnmarshalJSON - Decode the deployment like go-cfclient does, plus its canary status.
+func (d *Deployment) UnmarshalJSON(data []byte) error {
+	d.Deployment = &resource.Deployment{}
+	if err := json.Unmarshal(data, d.Deployment); err != nil {
+		return err
+	}
+	var status deploymentStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return err
+	}
+	d.Canary = status.Status.Canary
+	return nil
+}
+
+// MarshalJSON - Encode the deployment like the CF API does, with the canary status (if any) in its status.
+func (d Deployment) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(d.Deployment)
+	if err != nil || d.Canary == nil {
+		return data, err
+	}
+	var fields map[string]any
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	status, _ := fields["status"].(map[string]any)
+	if status == nil {
+		status = make(map[string]any)
+		fields["status"] = status
+	}
+	status["canary"] = d.Canary
+	return json.Marshal(fields)
+}
+
+// deploymentsClient lists the deployments with plain requests (authenticated by the go-cfclient), to keep the canary status.
+type deploymentsClient struct {
+	cfClient *client.Client
+}
+
+// ListAll - List all pages of the deployments matching the options.
+func (c deploymentsClient) ListAll(ctx context.Context, opts *client.DeploymentListOptions) ([]*Deployment, error) {
+	if opts == nil {
+		opts = client.NewDeploymentListOptions()
+	}
+	query, err := opts.ToQueryString()
+	if err != nil {
+		return nil, fmt.Errorf("error while generate query params: %w", err)
+	}
+	requestUrl := c.cfClient.ApiURL("/v3/deployments")
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+	var deployments []*Deployment
+	for requestUrl != "" {
+		var list struct {
+			Pagination resource.Pagination `json:"pagination"`
+			Resources  []*Deployment       `json:"resources"`
+		}
+		if err = get(ctx, c.cfClient, requestUrl, &list); err != nil {
+			return nil, err
+		}
+		deployments = append(deployments, list.Resources...)
+		requestUrl = list.Pagination.Next.Href
+	}
+	return deployments, nil
+}
+
+/** get - GET the url with the authentication of the go-cfclient and decode the (json) response into result, a CF API error response is returned as error. */
+func get(ctx context.Context, cfClient *client.Client, requestUrl string, result any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return fmt.Errorf("creating GET request for %s failed: %w", requestUrl, err)
+	}
+	response, err := cfClient.ExecuteAuthRequest(request)
+	if err != nil {
+		return fmt.Errorf("executing GET request for %s failed: %w", requestUrl, err)
+	}
+	defer func() { _ = response.Body.Close() }()
+	if err = json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("decoding the response of %s failed: %w", requestUrl, err)
+	}
+	return nil
+}
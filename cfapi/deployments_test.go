@@ -0,0 +1,64 @@
+package cfapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+)
+
+func TestDeploymentJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       string
+		wantCanary *CanaryStatus
+	}{
+		{
+			name:       "canary deployment",
+			json:       `{"guid":"deployment-1","strategy":"canary","status":{"value":"ACTIVE","reason":"PAUSED","details":{},"canary":{"steps":{"current":2,"total":3}}},"new_processes":[{"guid":"proc-new","type":"web"}]}`,
+			wantCanary: &CanaryStatus{Steps: CanarySteps{Current: 2, Total: 3}},
+		},
+		{
+			name: "rolling deployment",
+			json: `{"guid":"deployment-2","strategy":"rolling","status":{"value":"ACTIVE","reason":"DEPLOYING","details":{}},"new_processes":[{"guid":"proc-new","type":"web"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deployment Deployment
+			if err := json.Unmarshal([]byte(tt.json), &deployment); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			assertDeployment(t, &deployment, tt.wantCanary)
+
+			// the encoded deployment decodes to the same deployment, like the fake server relies on
+			data, err := json.Marshal(deployment)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if hasCanary := strings.Contains(string(data), `"canary"`); hasCanary != (tt.wantCanary != nil) {
+				t.Errorf("got canary in the json %t, want %t: %s", hasCanary, tt.wantCanary != nil, data)
+			}
+			var decoded Deployment
+			if err = json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			assertDeployment(t, &decoded, tt.wantCanary)
+			if decoded.GUID != deployment.GUID || decoded.Strategy != deployment.Strategy || decoded.Status.Reason != deployment.Status.Reason {
+				t.Errorf("got %+v after the round trip, want %+v", decoded.Deployment, deployment.Deployment)
+			}
+		})
+	}
+}
+
+/** assertDeployment - Check the new process and the canary status of the decoded deployment. */
+func assertDeployment(t *testing.T, deployment *Deployment, wantCanary *CanaryStatus) {
+	t.Helper()
+	if deployment.Deployment == nil || deployment.Status.Value != "ACTIVE" || len(deployment.NewProcesses) != 1 || deployment.NewProcesses[0] != (resource.ProcessReference{GUID: "proc-new", Type: "web"}) {
+		t.Errorf("got deployment %+v", deployment.Deployment)
+	}
+	if (deployment.Canary == nil) != (wantCanary == nil) || (wantCanary != nil && *deployment.Canary != *wantCanary) {
+		t.Errorf("got canary status %+v, want %+v", deployment.Canary, wantCanary)
+	}
+}
@@ -0,0 +1,507 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/metskem/panzer-plugin/cfapi"
+)
+
+// Fake is an in-memory implementation of the cfapi interfaces, fill it with resources and use Client() to get a *cfapi.Client.
+// Only the filters used by the plugin are implemented.
+type Fake struct {
+	Apps                      []*resource.App
+	AuditEvents               []*resource.AuditEvent
+	Deployments               []*cfapi.Deployment
+	Domains                   []*resource.Domain
+	Droplets                  []*resource.Droplet
+	CurrentDroplets           map[string]string // the guid of the current droplet, keyed by app guid
+	OrganizationQuotas        []*resource.OrganizationQuota
+	Organizations             []*resource.Organization
+	Packages                  []*resource.Package
+	Processes                 []*resource.Process
+	ProcessStats              map[string]*resource.ProcessStats // keyed by process guid
+	RouterGroups              []*cfapi.RouterGroup
+	Routes                    []*resource.Route
+	ServiceBrokers            []*resource.ServiceBroker
+	ServiceCredentialBindings []*resource.ServiceCredentialBinding
+	ServiceInstances          []*resource.ServiceInstance
+	ServiceOfferings          []*resource.ServiceOffering
+	ServicePlans              []*resource.ServicePlan
+	Sidecars                  []*resource.Sidecar
+	SpaceQuotas               []*resource.SpaceQuota
+	Spaces                    []*resource.Space
+	Tasks                     []*resource.Task
+	// Errors makes an operation fail with the given error, keyed by "<API>.<Method>", like "Processes.GetStats"
+	Errors map[string]error
+}
+
+type (
+	apps                      struct{ *Fake }
+	auditEvents               struct{ *Fake }
+	deployments               struct{ *Fake }
+	domains                   struct{ *Fake }
+	droplets                  struct{ *Fake }
+	organizationQuotas        struct{ *Fake }
+	organizations             struct{ *Fake }
+	packages                  struct{ *Fake }
+	processes                 struct{ *Fake }
+	routerGroups              struct{ *Fake }
+	routes                    struct{ *Fake }
+	serviceBrokers            struct{ *Fake }
+	serviceCredentialBindings struct{ *Fake }
+	serviceInstances          struct{ *Fake }
+	serviceOfferings          struct{ *Fake }
+	servicePlans              struct{ *Fake }
+	sidecars                  struct{ *Fake }
+	spaceQuotas               struct{ *Fake }
+	spaces                    struct{ *Fake }
+	tasks                     struct{ *Fake }
+)
+
+// Client - Return a *cfapi.Client backed by this fake.
+func (f *Fake) Client() *cfapi.Client {
+	return &cfapi.Client{
+		Applications:              apps{f},
+		AuditEvents:               auditEvents{f},
+		Deployments:               deployments{f},
+		Domains:                   domains{f},
+		Droplets:                  droplets{f},
+		OrganizationQuotas:        organizationQuotas{f},
+		Organizations:             organizations{f},
+		Packages:                  packages{f},
+		Processes:                 processes{f},
+		RouterGroups:              routerGroups{f},
+		Routes:                    routes{f},
+		ServiceBrokers:            serviceBrokers{f},
+		ServiceCredentialBindings: serviceCredentialBindings{f},
+		ServiceInstances:          serviceInstances{f},
+		ServiceOfferings:          serviceOfferings{f},
+		ServicePlans:              servicePlans{f},
+		Sidecars:                  sidecars{f},
+		SpaceQuotas:               spaceQuotas{f},
+		Spaces:                    spaces{f},
+		Tasks:                     tasks{f},
+	}
+}
+
+func (f apps) Get(_ context.Context, guid string) (*resource.App, error) {
+	if err := f.fail("Applications.Get"); err != nil {
+		return nil, err
+	}
+	return get(f.Apps, guid, func(app *resource.App) string { return app.GUID })
+}
+
+func (f apps) ListAll(_ context.Context, opts *client.AppListOptions) ([]*resource.App, error) {
+	if err := f.fail("Applications.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.Apps, func(app *resource.App) bool {
+		return opts == nil || (matches(opts.SpaceGUIDs, app.Relationships.Space.Data.GUID) && matches(opts.OrganizationGUIDs, f.orgOfSpace(app.Relationships.Space.Data.GUID)) && matches(opts.Names, app.Name) && matches(opts.GUIDs, app.GUID))
+	}), nil
+}
+
+func (f auditEvents) List(_ context.Context, opts *client.AuditEventListOptions) ([]*resource.AuditEvent, *client.Pager, error) {
+	if err := f.fail("AuditEvents.List"); err != nil {
+		return nil, nil, err
+	}
+	events := filter(f.AuditEvents, func(event *resource.AuditEvent) bool {
+		if opts == nil {
+			return true
+		}
+		if opts.ListOptions != nil && !matchesTimestamps(opts.CreatedAts, event.CreatedAt) {
+			return false
+		}
+		return matches(opts.Types, event.Type) && matchesExclusion(opts.TargetGUIDs, event.Target.GUID) && matches(opts.OrganizationGUIDs, event.Organization.GUID) && matches(opts.SpaceGUIDs, event.Space.GUID)
+	})
+	if opts != nil && opts.ListOptions != nil {
+		if opts.OrderBy == "-created_at" {
+			sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.After(events[j].CreatedAt) })
+		}
+		return page(events, opts.ListOptions)
+	}
+	return events, &client.Pager{TotalResults: len(events), TotalPages: 1}, nil
+}
+
+func (f deployments) ListAll(_ context.Context, opts *client.DeploymentListOptions) ([]*cfapi.Deployment, error) {
+	if err := f.fail("Deployments.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.Deployments, func(deployment *cfapi.Deployment) bool {
+		appGuid := ""
+		if deployment.Relationships.App.Data != nil {
+			appGuid = deployment.Relationships.App.Data.GUID
+		}
+		return opts == nil || (matches(opts.AppGUIDs, appGuid) && matches(opts.StatusValues, deployment.Status.Value) && matches(opts.StatusReasons, deployment.Status.Reason))
+	}), nil
+}
+
+func (f domains) Get(_ context.Context, guid string) (*resource.Domain, error) {
+	if err := f.fail("Domains.Get"); err != nil {
+		return nil, err
+	}
+	return get(f.Domains, guid, func(domain *resource.Domain) string { return domain.GUID })
+}
+
+func (f domains) ListAll(_ context.Context, opts *client.DomainListOptions) ([]*resource.Domain, error) {
+	if err := f.fail("Domains.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.Domains, func(domain *resource.Domain) bool {
+		return opts == nil || matches(opts.Names, domain.Name)
+	}), nil
+}
+
+func (f droplets) GetCurrentForApp(_ context.Context, appGuid string) (*resource.Droplet, error) {
+	if err := f.fail("Droplets.GetCurrentForApp"); err != nil {
+		return nil, err
+	}
+	return get(f.Droplets, f.CurrentDroplets[appGuid], func(droplet *resource.Droplet) string { return droplet.GUID })
+}
+
+func (f organizationQuotas) Get(_ context.Context, guid string) (*resource.OrganizationQuota, error) {
+	if err := f.fail("OrganizationQuotas.Get"); err != nil {
+		return nil, err
+	}
+	return get(f.OrganizationQuotas, guid, func(orgQuota *resource.OrganizationQuota) string { return orgQuota.GUID })
+}
+
+func (f organizations) Get(_ context.Context, guid string) (*resource.Organization, error) {
+	if err := f.fail("Organizations.Get"); err != nil {
+		return nil, err
+	}
+	return get(f.Organizations, guid, func(org *resource.Organization) string { return org.GUID })
+}
+
+func (f organizations) ListAll(_ context.Context, opts *client.OrganizationListOptions) ([]*resource.Organization, error) {
+	if err := f.fail("Organizations.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.Organizations, func(org *resource.Organization) bool {
+		return opts == nil || matches(opts.Names, org.Name)
+	}), nil
+}
+
+func (f organizations) Single(_ context.Context, opts *client.OrganizationListOptions) (*resource.Organization, error) {
+	if err := f.fail("Organizations.Single"); err != nil {
+		return nil, err
+	}
+	return single(filter(f.Organizations, func(org *resource.Organization) bool {
+		return opts == nil || matches(opts.Names, org.Name)
+	}))
+}
+
+func (f packages) ListAll(_ context.Context, opts *client.PackageListOptions) ([]*resource.Package, error) {
+	if err := f.fail("Packages.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.Packages, func(pkg *resource.Package) bool {
+		if opts == nil {
+			return true
+		}
+		appGuid, spaceGuid := "", ""
+		if pkg.Relationships.App.Data != nil {
+			appGuid = pkg.Relationships.App.Data.GUID
+		}
+		if app, err := get(f.Apps, appGuid, func(app *resource.App) string { return app.GUID }); err == nil {
+			spaceGuid = app.Relationships.Space.Data.GUID
+		}
+		return matches(opts.GUIDs, pkg.GUID) && matches(opts.States, string(pkg.State)) && matches(opts.Types, pkg.Type) && matches(opts.AppGUIDs, appGuid) && matches(opts.SpaceGUIDs, spaceGuid) && matches(opts.OrganizationGUIDs, f.orgOfSpace(spaceGuid))
+	}), nil
+}
+
+func (f processes) GetStats(_ context.Context, guid string) (*resource.ProcessStats, error) {
+	if err := f.fail("Processes.GetStats"); err != nil {
+		return nil, err
+	}
+	if stats, found := f.ProcessStats[guid]; found {
+		return stats, nil
+	}
+	return &resource.ProcessStats{}, nil
+}
+
+func (f processes) ListAll(_ context.Context, opts *client.ProcessListOptions) ([]*resource.Process, error) {
+	if err := f.fail("Processes.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.Processes, func(process *resource.Process) bool {
+		if opts == nil {
+			return true
+		}
+		appGuid := process.Relationships.App.Data.GUID
+		spaceGuid := ""
+		if app, err := get(f.Apps, appGuid, func(app *resource.App) string { return app.GUID }); err == nil {
+			spaceGuid = app.Relationships.Space.Data.GUID
+		}
+		return matches(opts.AppGUIDs, appGuid) && matches(opts.SpaceGUIDs, spaceGuid) && matches(opts.OrganizationGUIDs, f.orgOfSpace(spaceGuid)) && matches(opts.Types, process.Type)
+	}), nil
+}
+
+func (f routerGroups) ListAll(_ context.Context) ([]*cfapi.RouterGroup, error) {
+	if err := f.fail("RouterGroups.ListAll"); err != nil {
+		return nil, err
+	}
+	return f.RouterGroups, nil
+}
+
+func (f routes) List(_ context.Context, opts *client.RouteListOptions) ([]*resource.Route, *client.Pager, error) {
+	if err := f.fail("Routes.List"); err != nil {
+		return nil, nil, err
+	}
+	if opts == nil || opts.ListOptions == nil {
+		allRoutes := f.filterRoutes(opts)
+		return allRoutes, &client.Pager{TotalResults: len(allRoutes), TotalPages: 1}, nil
+	}
+	return page(f.filterRoutes(opts), opts.ListOptions)
+}
+
+func (f routes) ListAll(_ context.Context, opts *client.RouteListOptions) ([]*resource.Route, error) {
+	if err := f.fail("Routes.ListAll"); err != nil {
+		return nil, err
+	}
+	return f.filterRoutes(opts), nil
+}
+
+func (f routes) filterRoutes(opts *client.RouteListOptions) []*resource.Route {
+	return filter(f.Routes, func(route *resource.Route) bool {
+		if opts == nil {
+			return true
+		}
+		port := ""
+		if route.Port != nil {
+			port = fmt.Sprintf("%d", *route.Port)
+		}
+		return matches(opts.Hosts, route.Host) && matches(opts.Ports, port) && matches(opts.SpaceGUIDs, route.Relationships.Space.Data.GUID) && matches(opts.OrganizationGUIDs, f.orgOfSpace(route.Relationships.Space.Data.GUID)) && matches(opts.DomainGUIDs, route.Relationships.Domain.Data.GUID)
+	})
+}
+
+func (f serviceBrokers) Get(_ context.Context, guid string) (*resource.ServiceBroker, error) {
+	if err := f.fail("ServiceBrokers.Get"); err != nil {
+		return nil, err
+	}
+	return get(f.ServiceBrokers, guid, func(broker *resource.ServiceBroker) string { return broker.GUID })
+}
+
+func (f serviceCredentialBindings) ListAll(_ context.Context, opts *client.ServiceCredentialBindingListOptions) ([]*resource.ServiceCredentialBinding, error) {
+	if err := f.fail("ServiceCredentialBindings.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.ServiceCredentialBindings, func(binding *resource.ServiceCredentialBinding) bool {
+		appGuid, serviceInstanceGuid := "", ""
+		if binding.Relationships.App != nil && binding.Relationships.App.Data != nil {
+			appGuid = binding.Relationships.App.Data.GUID
+		}
+		if binding.Relationships.ServiceInstance != nil && binding.Relationships.ServiceInstance.Data != nil {
+			serviceInstanceGuid = binding.Relationships.ServiceInstance.Data.GUID
+		}
+		return opts == nil || (matches(opts.AppGUIDs, appGuid) && matches(opts.ServiceInstanceGUIDs, serviceInstanceGuid) && matches(opts.Type, binding.Type))
+	}), nil
+}
+
+func (f serviceInstances) ListAll(_ context.Context, opts *client.ServiceInstanceListOptions) ([]*resource.ServiceInstance, error) {
+	if err := f.fail("ServiceInstances.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.ServiceInstances, func(serviceInstance *resource.ServiceInstance) bool {
+		spaceGuid := ""
+		if serviceInstance.Relationships.Space != nil && serviceInstance.Relationships.Space.Data != nil {
+			spaceGuid = serviceInstance.Relationships.Space.Data.GUID
+		}
+		return opts == nil || (matches(opts.SpaceGUIDs, spaceGuid) && matches(opts.OrganizationGUIDs, f.orgOfSpace(spaceGuid)) && matches(opts.Names, serviceInstance.Name) && matches(opts.GUIDs, serviceInstance.GUID))
+	}), nil
+}
+
+func (f serviceOfferings) Get(_ context.Context, guid string) (*resource.ServiceOffering, error) {
+	if err := f.fail("ServiceOfferings.Get"); err != nil {
+		return nil, err
+	}
+	return get(f.ServiceOfferings, guid, func(offering *resource.ServiceOffering) string { return offering.GUID })
+}
+
+func (f servicePlans) Get(_ context.Context, guid string) (*resource.ServicePlan, error) {
+	if err := f.fail("ServicePlans.Get"); err != nil {
+		return nil, err
+	}
+	return get(f.ServicePlans, guid, func(plan *resource.ServicePlan) string { return plan.GUID })
+}
+
+func (f sidecars) ListForAppAll(_ context.Context, appGuid string, _ *client.SidecarListOptions) ([]*resource.Sidecar, error) {
+	if err := f.fail("Sidecars.ListForAppAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.Sidecars, func(sidecar *resource.Sidecar) bool {
+		return sidecar.Relationships.App.Data != nil && sidecar.Relationships.App.Data.GUID == appGuid
+	}), nil
+}
+
+func (f spaceQuotas) Get(_ context.Context, guid string) (*resource.SpaceQuota, error) {
+	if err := f.fail("SpaceQuotas.Get"); err != nil {
+		return nil, err
+	}
+	return get(f.SpaceQuotas, guid, func(spaceQuota *resource.SpaceQuota) string { return spaceQuota.GUID })
+}
+
+func (f spaceQuotas) ListAll(_ context.Context, opts *client.SpaceQuotaListOptions) ([]*resource.SpaceQuota, error) {
+	if err := f.fail("SpaceQuotas.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.SpaceQuotas, func(spaceQuota *resource.SpaceQuota) bool {
+		orgGuid := ""
+		if spaceQuota.Relationships.Organization != nil && spaceQuota.Relationships.Organization.Data != nil {
+			orgGuid = spaceQuota.Relationships.Organization.Data.GUID
+		}
+		return opts == nil || (matches(opts.OrganizationGUIDs, orgGuid) && matches(opts.Names, spaceQuota.Name))
+	}), nil
+}
+
+func (f spaces) Get(_ context.Context, guid string) (*resource.Space, error) {
+	if err := f.fail("Spaces.Get"); err != nil {
+		return nil, err
+	}
+	return get(f.Spaces, guid, func(space *resource.Space) string { return space.GUID })
+}
+
+func (f spaces) ListAll(_ context.Context, opts *client.SpaceListOptions) ([]*resource.Space, error) {
+	if err := f.fail("Spaces.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.Spaces, func(space *resource.Space) bool {
+		return opts == nil || (matches(opts.Names, space.Name) && matches(opts.OrganizationGUIDs, space.Relationships.Organization.Data.GUID))
+	}), nil
+}
+
+func (f spaces) Single(_ context.Context, opts *client.SpaceListOptions) (*resource.Space, error) {
+	if err := f.fail("Spaces.Single"); err != nil {
+		return nil, err
+	}
+	return single(filter(f.Spaces, func(space *resource.Space) bool {
+		return opts == nil || (matches(opts.Names, space.Name) && matches(opts.OrganizationGUIDs, space.Relationships.Organization.Data.GUID))
+	}))
+}
+
+func (f tasks) ListAll(_ context.Context, opts *client.TaskListOptions) ([]*resource.Task, error) {
+	if err := f.fail("Tasks.ListAll"); err != nil {
+		return nil, err
+	}
+	return filter(f.Tasks, func(task *resource.Task) bool {
+		if opts == nil {
+			return true
+		}
+		appGuid := task.Relationships.App.Data.GUID
+		spaceGuid := ""
+		if app, err := get(f.Apps, appGuid, func(app *resource.App) string { return app.GUID }); err == nil {
+			spaceGuid = app.Relationships.Space.Data.GUID
+		}
+		if opts.ListOptions != nil && !matchesTimestamps(opts.CreatedAts, task.CreatedAt) {
+			return false
+		}
+		return matches(opts.AppGUIDs, appGuid) && matches(opts.SpaceGUIDs, spaceGuid) && matches(opts.OrganizationGUIDs, f.orgOfSpace(spaceGuid)) && matches(opts.States, task.State)
+	}), nil
+}
+
+/** orgOfSpace - The guid of the org of the given space, empty if the space is unknown. */
+func (f *Fake) orgOfSpace(spaceGuid string) string {
+	if space, err := get(f.Spaces, spaceGuid, func(space *resource.Space) string { return space.GUID }); err == nil {
+		return space.Relationships.Organization.Data.GUID
+	}
+	return ""
+}
+
+/** fail - Return the configured error for the given operation, if any. */
+func (f *Fake) fail(operation string) error {
+	return f.Errors[operation]
+}
+
+/** matches - An empty filter matches everything, otherwise the value must be one of the filter values. */
+func matches(filter client.Filter, value string) bool {
+	return len(filter.Values) == 0 || slices.Contains(filter.Values, value)
+}
+
+/** matchesExclusion - Like matches, but with [not] the value must not be one of the filter values. */
+func matchesExclusion(filter client.ExclusionFilter, value string) bool {
+	return len(filter.Values) == 0 || slices.Contains(filter.Values, value) != filter.Not
+}
+
+/** matchesTimestamps - The timestamp must satisfy all filters in the list, an empty list matches everything. */
+func matchesTimestamps(filters client.TimestampFilterList, timestamp time.Time) bool {
+	for _, timestampFilter := range filters {
+		for _, filterTime := range timestampFilter.Timestamp {
+			switch timestampFilter.Operator {
+			case client.FilterModifierGreaterThan:
+				if !timestamp.After(filterTime) {
+					return false
+				}
+			case client.FilterModifierGreaterThanOrEqual:
+				if timestamp.Before(filterTime) {
+					return false
+				}
+			case client.FilterModifierLessThan:
+				if !timestamp.Before(filterTime) {
+					return false
+				}
+			case client.FilterModifierLessThanOrEqual:
+				if timestamp.After(filterTime) {
+					return false
+				}
+			default:
+				if !timestamp.Equal(filterTime) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func filter[T any](items []T, keep func(T) bool) []T {
+	var kept []T
+	for _, item := range items {
+		if keep(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+func get[T any](items []T, guid string, guidOf func(T) string) (T, error) {
+	for _, item := range items {
+		if guidOf(item) == guid {
+			return item, nil
+		}
+	}
+	var none T
+	notFound := resource.NewResourceNotFoundError()
+	notFound.Detail = fmt.Sprintf("resource %s not found", guid)
+	return none, notFound
+}
+
+func single[T any](items []T) (T, error) {
+	if len(items) != 1 {
+		var none T
+		return none, client.ErrExactlyOneResultNotReturned
+	}
+	return items[0], nil
+}
+
+/** page - Return the requested page of the items, like the CF API does (pages start at 1). */
+func page[T any](items []T, listOptions *client.ListOptions) ([]T, *client.Pager, error) {
+	perPage := listOptions.PerPage
+	if perPage <= 0 {
+		perPage = 50
+	}
+	pageNr := listOptions.Page
+	if pageNr <= 0 {
+		pageNr = 1
+	}
+	pager := &client.Pager{TotalResults: len(items), TotalPages: (len(items) + perPage - 1) / perPage}
+	start := (pageNr - 1) * perPage
+	if start >= len(items) {
+		return nil, pager, nil
+	}
+	return items[start:min(start+perPage, len(items))], pager, nil
+}
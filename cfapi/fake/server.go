@@ -0,0 +1,422 @@
+package fake
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/config"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/metskem/panzer-plugin/cfapi"
+)
+
+// Server is a local stand-in for the CF v3 API (the Cloud Controller), serving the resources of a Fake over http.
+// Point the real go-cfclient to it (see Config and Client) to run the commands end-to-end without a foundation.
+// Errors configured in the Fake are returned as CF API error responses.
+type Server struct {
+	*httptest.Server
+	fake *Fake
+}
+
+// NewServer - Start a stand-in CF API serving the given fake, call Close when done.
+func NewServer(f *Fake) *Server {
+	server := &Server{fake: f}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", server.root)
+	mux.HandleFunc("POST /oauth/token", server.token)
+	mux.HandleFunc("GET /v3/apps", server.listApps)
+	mux.HandleFunc("GET /v3/apps/{guid}", server.getApp)
+	mux.HandleFunc("GET /v3/apps/{guid}/droplets/current", server.getCurrentDroplet)
+	mux.HandleFunc("GET /v3/apps/{guid}/sidecars", server.listSidecars)
+	mux.HandleFunc("GET /v3/audit_events", server.listAuditEvents)
+	mux.HandleFunc("GET /v3/deployments", server.listDeployments)
+	mux.HandleFunc("GET /v3/domains", server.listDomains)
+	mux.HandleFunc("GET /v3/domains/{guid}", server.getDomain)
+	mux.HandleFunc("GET /v3/organization_quotas/{guid}", server.getOrganizationQuota)
+	mux.HandleFunc("GET /v3/organizations", server.listOrganizations)
+	mux.HandleFunc("GET /v3/organizations/{guid}", server.getOrganization)
+	mux.HandleFunc("GET /v3/packages", server.listPackages)
+	mux.HandleFunc("GET /v3/processes", server.listProcesses)
+	mux.HandleFunc("GET /v3/processes/{guid}/stats", server.getProcessStats)
+	mux.HandleFunc("GET /v3/routes", server.listRoutes)
+	mux.HandleFunc("GET /v3/service_brokers/{guid}", server.getServiceBroker)
+	mux.HandleFunc("GET /v3/service_credential_bindings", server.listServiceCredentialBindings)
+	mux.HandleFunc("GET /v3/service_instances", server.listServiceInstances)
+	mux.HandleFunc("GET /v3/service_offerings/{guid}", server.getServiceOffering)
+	mux.HandleFunc("GET /v3/service_plans/{guid}", server.getServicePlan)
+	mux.HandleFunc("GET /v3/space_quotas", server.listSpaceQuotas)
+	mux.HandleFunc("GET /v3/space_quotas/{guid}", server.getSpaceQuota)
+	mux.HandleFunc("GET /v3/spaces", server.listSpaces)
+	mux.HandleFunc("GET /v3/spaces/{guid}", server.getSpace)
+	mux.HandleFunc("GET /v3/tasks", server.listTasks)
+	mux.HandleFunc("GET /routing/v1/router_groups", server.listRouterGroups)
+	server.Server = httptest.NewServer(mux)
+	return server
+}
+
+// Config - Return a go-cfclient config pointing to this server, with a (fake) token that does not expire during the test.
+func (s *Server) Config(options ...config.Option) (*config.Config, error) {
+	return config.New(s.URL, append([]config.Option{config.Token(fakeAccessToken(), "fake-refresh-token")}, options...)...)
+}
+
+// Client - Return a *cfapi.Client using the real go-cfclient against this server.
+func (s *Server) Client() (*cfapi.Client, error) {
+	cfConfig, err := s.Config()
+	if err != nil {
+		return nil, err
+	}
+	cfClient, err := client.New(cfConfig)
+	if err != nil {
+		return nil, err
+	}
+	return cfapi.New(cfClient), nil
+}
+
+// WriteCfConfig - Write a cf CLI config (.cf/config.json in cfHomeDir) that targets this server and is logged in, like "cf login" does.
+// Use it as CF_HOME to run the plugin commands the way the cf CLI does, they create their client from it.
+func (s *Server) WriteCfConfig(cfHomeDir string) error {
+	cfConfig := map[string]any{"ConfigVersion": 3, "Target": s.URL, "AuthorizationEndpoint": s.URL, "UaaEndpoint": s.URL, "UAAOAuthClient": "cf", "AccessToken": "bearer " + fakeAccessToken(), "RefreshToken": "fake-refresh-token"}
+	data, err := json.Marshal(cfConfig)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Join(cfHomeDir, ".cf"), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cfHomeDir, ".cf", "config.json"), data, 0600)
+}
+
+func (s *Server) root(w http.ResponseWriter, _ *http.Request) {
+	var root resource.Root
+	root.Links.Self.Href = s.URL
+	root.Links.Login.Href = s.URL
+	root.Links.Uaa.Href = s.URL
+	root.Links.Routing.Href = s.URL + "/routing"
+	writeJson(w, http.StatusOK, root)
+}
+
+func (s *Server) token(w http.ResponseWriter, _ *http.Request) {
+	writeJson(w, http.StatusOK, map[string]any{"access_token": fakeAccessToken(), "refresh_token": "fake-refresh-token", "token_type": "bearer", "expires_in": 3600})
+}
+
+func (s *Server) listApps(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := &client.AppListOptions{SpaceGUIDs: queryFilter(q, "space_guids"), OrganizationGUIDs: queryFilter(q, "organization_guids"), Names: queryFilter(q, "names"), GUIDs: queryFilter(q, "guids")}
+	all, err := apps{s.fake}.ListAll(r.Context(), opts)
+	writeList(w, r, all, err)
+}
+
+func (s *Server) getApp(w http.ResponseWriter, r *http.Request) {
+	app, err := apps{s.fake}.Get(r.Context(), r.PathValue("guid"))
+	writeResource(w, app, err)
+}
+
+func (s *Server) listAuditEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	listOptions, err := queryListOptions(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	opts := &client.AuditEventListOptions{ListOptions: listOptions, Types: queryFilter(q, "types"), TargetGUIDs: client.ExclusionFilter{Filter: queryFilter(q, "target_guids")}, OrganizationGUIDs: queryFilter(q, "organization_guids"), SpaceGUIDs: queryFilter(q, "space_guids")}
+	if q.Get("target_guids[not]") != "" {
+		opts.TargetGUIDs = client.ExclusionFilter{Filter: queryFilter(q, "target_guids[not]"), Not: true}
+	}
+	events, pager, err := auditEvents{s.fake}.List(r.Context(), opts)
+	writePage(w, r, events, pager, err)
+}
+
+func (s *Server) listDeployments(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := &client.DeploymentListOptions{AppGUIDs: queryFilter(q, "app_guids"), StatusValues: queryFilter(q, "status_values"), StatusReasons: queryFilter(q, "status_reasons")}
+	all, err := deployments{s.fake}.ListAll(r.Context(), opts)
+	writeList(w, r, all, err)
+}
+
+func (s *Server) listDomains(w http.ResponseWriter, r *http.Request) {
+	all, err := domains{s.fake}.ListAll(r.Context(), &client.DomainListOptions{Names: queryFilter(r.URL.Query(), "names")})
+	writeList(w, r, all, err)
+}
+
+func (s *Server) getDomain(w http.ResponseWriter, r *http.Request) {
+	domain, err := domains{s.fake}.Get(r.Context(), r.PathValue("guid"))
+	writeResource(w, domain, err)
+}
+
+func (s *Server) getCurrentDroplet(w http.ResponseWriter, r *http.Request) {
+	droplet, err := droplets{s.fake}.GetCurrentForApp(r.Context(), r.PathValue("guid"))
+	writeResource(w, droplet, err)
+}
+
+func (s *Server) getOrganizationQuota(w http.ResponseWriter, r *http.Request) {
+	orgQuota, err := organizationQuotas{s.fake}.Get(r.Context(), r.PathValue("guid"))
+	writeResource(w, orgQuota, err)
+}
+
+// listOrganizations - The go-cfclient uses this endpoint for both ListAll and Single, an error configured for either of them is returned.
+func (s *Server) listOrganizations(w http.ResponseWriter, r *http.Request) {
+	all, err := organizations{s.fake}.ListAll(r.Context(), &client.OrganizationListOptions{Names: queryFilter(r.URL.Query(), "names")})
+	if err == nil {
+		err = s.fake.fail("Organizations.Single")
+	}
+	writeList(w, r, all, err)
+}
+
+func (s *Server) getOrganization(w http.ResponseWriter, r *http.Request) {
+	org, err := organizations{s.fake}.Get(r.Context(), r.PathValue("guid"))
+	writeResource(w, org, err)
+}
+
+func (s *Server) listPackages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := &client.PackageListOptions{GUIDs: queryFilter(q, "guids"), States: queryFilter(q, "states"), Types: queryFilter(q, "types"), AppGUIDs: queryFilter(q, "app_guids"), SpaceGUIDs: queryFilter(q, "space_guids"), OrganizationGUIDs: queryFilter(q, "organization_guids")}
+	all, err := packages{s.fake}.ListAll(r.Context(), opts)
+	writeList(w, r, all, err)
+}
+
+func (s *Server) listProcesses(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := &client.ProcessListOptions{SpaceGUIDs: queryFilter(q, "space_guids"), OrganizationGUIDs: queryFilter(q, "organization_guids"), AppGUIDs: queryFilter(q, "app_guids"), Types: queryFilter(q, "types")}
+	all, err := processes{s.fake}.ListAll(r.Context(), opts)
+	writeList(w, r, all, err)
+}
+
+func (s *Server) getProcessStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := processes{s.fake}.GetStats(r.Context(), r.PathValue("guid"))
+	writeResource(w, stats, err)
+}
+
+// listRouterGroups - Serve the router groups like the routing API does, a plain list without paging.
+func (s *Server) listRouterGroups(w http.ResponseWriter, r *http.Request) {
+	all, err := routerGroups{s.fake}.ListAll(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if all == nil {
+		all = []*cfapi.RouterGroup{}
+	}
+	writeJson(w, http.StatusOK, all)
+}
+
+func (s *Server) listRoutes(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	listOptions, err := queryListOptions(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	opts := &client.RouteListOptions{ListOptions: listOptions, Hosts: queryFilter(q, "hosts"), Ports: queryFilter(q, "ports"), SpaceGUIDs: queryFilter(q, "space_guids"), OrganizationGUIDs: queryFilter(q, "organization_guids"), DomainGUIDs: queryFilter(q, "domain_guids")}
+	routeList, pager, err := routes{s.fake}.List(r.Context(), opts)
+	writePage(w, r, routeList, pager, err)
+}
+
+func (s *Server) getServiceBroker(w http.ResponseWriter, r *http.Request) {
+	broker, err := serviceBrokers{s.fake}.Get(r.Context(), r.PathValue("guid"))
+	writeResource(w, broker, err)
+}
+
+func (s *Server) listServiceCredentialBindings(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := &client.ServiceCredentialBindingListOptions{AppGUIDs: queryFilter(q, "app_guids"), ServiceInstanceGUIDs: queryFilter(q, "service_instance_guids"), Type: queryFilter(q, "type")}
+	all, err := serviceCredentialBindings{s.fake}.ListAll(r.Context(), opts)
+	writeList(w, r, all, err)
+}
+
+func (s *Server) listServiceInstances(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := &client.ServiceInstanceListOptions{SpaceGUIDs: queryFilter(q, "space_guids"), OrganizationGUIDs: queryFilter(q, "organization_guids"), Names: queryFilter(q, "names"), GUIDs: queryFilter(q, "guids")}
+	all, err := serviceInstances{s.fake}.ListAll(r.Context(), opts)
+	writeList(w, r, all, err)
+}
+
+func (s *Server) getServiceOffering(w http.ResponseWriter, r *http.Request) {
+	offering, err := serviceOfferings{s.fake}.Get(r.Context(), r.PathValue("guid"))
+	writeResource(w, offering, err)
+}
+
+func (s *Server) getServicePlan(w http.ResponseWriter, r *http.Request) {
+	plan, err := servicePlans{s.fake}.Get(r.Context(), r.PathValue("guid"))
+	writeResource(w, plan, err)
+}
+
+func (s *Server) listSidecars(w http.ResponseWriter, r *http.Request) {
+	all, err := sidecars{s.fake}.ListForAppAll(r.Context(), r.PathValue("guid"), nil)
+	writeList(w, r, all, err)
+}
+
+func (s *Server) listSpaceQuotas(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	all, err := spaceQuotas{s.fake}.ListAll(r.Context(), &client.SpaceQuotaListOptions{OrganizationGUIDs: queryFilter(q, "organization_guids"), Names: queryFilter(q, "names")})
+	writeList(w, r, all, err)
+}
+
+func (s *Server) getSpaceQuota(w http.ResponseWriter, r *http.Request) {
+	spaceQuota, err := spaceQuotas{s.fake}.Get(r.Context(), r.PathValue("guid"))
+	writeResource(w, spaceQuota, err)
+}
+
+// listSpaces - The go-cfclient uses this endpoint for both ListAll and Single, an error configured for either of them is returned.
+func (s *Server) listSpaces(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	all, err := spaces{s.fake}.ListAll(r.Context(), &client.SpaceListOptions{Names: queryFilter(q, "names"), OrganizationGUIDs: queryFilter(q, "organization_guids")})
+	if err == nil {
+		err = s.fake.fail("Spaces.Single")
+	}
+	writeList(w, r, all, err)
+}
+
+func (s *Server) getSpace(w http.ResponseWriter, r *http.Request) {
+	space, err := spaces{s.fake}.Get(r.Context(), r.PathValue("guid"))
+	writeResource(w, space, err)
+}
+
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	listOptions, err := queryListOptions(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	opts := &client.TaskListOptions{ListOptions: listOptions, AppGUIDs: queryFilter(q, "app_guids"), SpaceGUIDs: queryFilter(q, "space_guids"), OrganizationGUIDs: queryFilter(q, "organization_guids"), States: queryFilter(q, "states")}
+	all, err := tasks{s.fake}.ListAll(r.Context(), opts)
+	writeList(w, r, all, err)
+}
+
+/** queryFilter - Convert a comma separated query parameter to a filter, a missing parameter is an empty filter. */
+func queryFilter(q url.Values, name string) client.Filter {
+	if q.Get(name) == "" {
+		return client.Filter{}
+	}
+	return client.Filter{Values: strings.Split(q.Get(name), ",")}
+}
+
+/** queryListOptions - Convert the paging, ordering and created_ats query parameters to list options. */
+func queryListOptions(q url.Values) (*client.ListOptions, error) {
+	listOptions := &client.ListOptions{OrderBy: q.Get("order_by")}
+	listOptions.Page, listOptions.PerPage = queryPaging(q)
+	modifiers := map[string]client.FilterModifier{
+		"created_ats":      client.FilterModifierNone,
+		"created_ats[gt]":  client.FilterModifierGreaterThan,
+		"created_ats[gte]": client.FilterModifierGreaterThanOrEqual,
+		"created_ats[lt]":  client.FilterModifierLessThan,
+		"created_ats[lte]": client.FilterModifierLessThanOrEqual,
+	}
+	for param, modifier := range modifiers {
+		if q.Get(param) == "" {
+			continue
+		}
+		timestampFilter := client.TimestampFilter{Operator: modifier}
+		for _, value := range strings.Split(q.Get(param), ",") {
+			timestamp, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, resource.CloudFoundryError{Code: 10005, Title: "CF-BadQueryParameter", Detail: fmt.Sprintf("invalid %s: %s", param, value)}
+			}
+			timestampFilter.Timestamp = append(timestampFilter.Timestamp, timestamp)
+		}
+		listOptions.CreatedAts = append(listOptions.CreatedAts, timestampFilter)
+	}
+	return listOptions, nil
+}
+
+/** queryPaging - Get the page and per_page query parameters, with the CF API defaults (1 and 50). */
+func queryPaging(q url.Values) (int, int) {
+	pageNr, err := strconv.Atoi(q.Get("page"))
+	if err != nil || pageNr < 1 {
+		pageNr = 1
+	}
+	perPage, err := strconv.Atoi(q.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = 50
+	}
+	return pageNr, perPage
+}
+
+/** writeList - Write the requested page of all (already filtered) items as a CF API list response. */
+func writeList[T any](w http.ResponseWriter, r *http.Request, all []T, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	listOptions := &client.ListOptions{}
+	listOptions.Page, listOptions.PerPage = queryPaging(r.URL.Query())
+	items, pager, _ := page(all, listOptions)
+	writePage(w, r, items, pager, nil)
+}
+
+/** writePage - Write one page of items as a CF API list response, with the pagination links the go-cfclient uses to get the next page. */
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T, pager *client.Pager, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pageNr, _ := queryPaging(r.URL.Query())
+	pagination := resource.Pagination{TotalResults: pager.TotalResults, TotalPages: pager.TotalPages}
+	pagination.First.Href = pageHref(r, 1)
+	pagination.Last.Href = pageHref(r, max(pager.TotalPages, 1))
+	if pageNr < pager.TotalPages {
+		pagination.Next.Href = pageHref(r, pageNr+1)
+	}
+	if pageNr > 1 {
+		pagination.Previous.Href = pageHref(r, pageNr-1)
+	}
+	if items == nil {
+		items = []T{}
+	}
+	writeJson(w, http.StatusOK, map[string]any{"pagination": pagination, "resources": items})
+}
+
+func pageHref(r *http.Request, pageNr int) string {
+	q := r.URL.Query()
+	_, perPage := queryPaging(q)
+	q.Set("page", strconv.Itoa(pageNr))
+	q.Set("per_page", strconv.Itoa(perPage))
+	return fmt.Sprintf("http://%s%s?%s", r.Host, r.URL.Path, q.Encode())
+}
+
+func writeResource(w http.ResponseWriter, item any, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJson(w, http.StatusOK, item)
+}
+
+/** writeError - Write the error as a CF API error response, CF errors keep their code (not found gives a 404), all others become a 500. */
+func writeError(w http.ResponseWriter, err error) {
+	var cfError resource.CloudFoundryError
+	if !errors.As(err, &cfError) {
+		cfError = resource.CloudFoundryError{Code: 10001, Title: "CF-UnknownError", Detail: err.Error()}
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case resource.IsResourceNotFoundError(cfError), resource.IsNotFoundError(cfError):
+		status = http.StatusNotFound
+	case cfError.Code == 10005:
+		status = http.StatusBadRequest
+	}
+	writeJson(w, status, resource.CloudFoundryErrors{Errors: []resource.CloudFoundryError{cfError}})
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+/** fakeAccessToken - An unsigned jwt that expires in an hour, the go-cfclient only looks at the expiry. */
+func fakeAccessToken() string {
+	encode := base64.RawURLEncoding.EncodeToString
+	payload := fmt.Sprintf(`{"exp":%d,"user_name":"fake-user"}`, time.Now().Add(time.Hour).Unix())
+	return encode([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + encode([]byte(payload)) + ".fake-signature"
+}
@@ -0,0 +1,37 @@
+package cfapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+)
+
+// RouterGroup is a router group of the routing API, the TCP domains only have its guid.
+type RouterGroup struct {
+	GUID            string `json:"guid"`
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	ReservablePorts string `json:"reservable_ports"`
+}
+
+// routerGroupsClient lists the router groups with plain requests (authenticated by the go-cfclient), go-cfclient does not cover the routing API.
+type routerGroupsClient struct {
+	cfClient *client.Client
+}
+
+// ListAll - List all router groups, the routing API is found with the links of the CF API root, it has no paging.
+func (c routerGroupsClient) ListAll(ctx context.Context) ([]*RouterGroup, error) {
+	root, err := c.cfClient.Root.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if root.Links.Routing.Href == "" {
+		return nil, errors.New("the CF API has no link to the routing API")
+	}
+	var routerGroups []*RouterGroup
+	if err = get(ctx, c.cfClient, root.Links.Routing.Href+"/v1/router_groups", &routerGroups); err != nil {
+		return nil, err
+	}
+	return routerGroups, nil
+}
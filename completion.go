@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/conf"
+	"github.com/metskem/panzer-plugin/event"
+)
+
+const (
+	PanzerHelpText = "Panzer plugin utilities, like the shell completion"
+	// completionCacheTTL is how long the org, space and app names for the completion are kept on disk, if CF_PANZER_CACHE_TTL is not set
+	completionCacheTTL = 5 * time.Minute
+)
+
+var PanzerUsage = "panzer completion <bash|zsh|fish> - Print the shell completion script for the panzer commands, load it with \"source <(cf panzer completion bash)\" (or zsh), or \"cf panzer completion fish | source\""
+
+// completionCommands are the commands we generate the shell completion for, with the function that creates their flag parser.
+var completionCommands = []struct {
+	name          string
+	helpText      string
+	newFlagParser func(*conf.Flags) *flaggy.Parser
+}{
+	{"aa", ListAppsHelpText, newAppsFlagParser},
+	{"lr", ListRoutesHelpText, newRoutesFlagParser},
+	{"ev", event.ListEventsHelpText, event.NewFlagParser},
+	{"domains-overview", ListDomainsHelpText, newDomainsFlagParser},
+	{"ss", ListServicesHelpText, newServicesFlagParser},
+	{"bindings", ListBindingsHelpText, newBindingsFlagParser},
+	{"quota-overview", ListQuotasHelpText, newQuotaFlagParser},
+	{"rightsize", RightsizeHelpText, newRightsizeFlagParser},
+	{"inventory", InventoryHelpText, newInventoryFlagParser},
+	{"stale-apps", StaleAppsHelpText, newStaleFlagParser},
+	{"deployments", DeploymentsHelpText, newDeploymentsFlagParser},
+	{"tt", ListTasksHelpText, newTasksFlagParser},
+}
+
+// completionValues tells what to complete as the value of a flag, keyed by command and long flag name ("*" is any command).
+// The lists and choices (see getCompletionLists) are in the completion script, the others are looked up with "cf panzer complete <kind>".
+var completionValues = map[string]string{
+	"aa/appname":          "apps",
+	"aa/columns":          "columns",
+	"aa/profile":          "profiles",
+	"ev/event-type":       "event-types",
+	"ev/target-name":      "apps",
+	"ev/org":              "orgs",
+	"ev/space":            "spaces",
+	"ss/columns":          "service-columns",
+	"bindings/appname":    "apps",
+	"quota-overview/org":  "orgs",
+	"rightsize/appname":   "apps",
+	"inventory/appname":   "apps",
+	"inventory/by":        "groupings",
+	"stale-apps/appname":  "apps",
+	"deployments/appname": "apps",
+	"tt/appname":          "apps",
+	"*/format":            "formats",
+	"*/scope":             "scopes",
+}
+
+// completionEnvVars are the envvars whose value is completed (zsh only), with the list of values to complete.
+var completionEnvVars = map[string]string{
+	"CF_COLS":          "columns",
+	ServicesColsEnvVar: "service-columns",
+}
+
+// completionCommand is a command with its flags, as offered by the completion script.
+type completionCommand struct {
+	Name     string
+	HelpText string
+	Flags    []completionFlag
+}
+
+// completionFlag is a flag of a command, Values is what to complete as its value (empty if there is nothing to complete).
+type completionFlag struct {
+	Short       string
+	Long        string
+	Description string
+	HasValue    bool
+	Values      string
+}
+
+/** panzerCommand - Run "cf panzer <subcommand>", "completion" prints the completion script, "complete" (used by the script) prints the org, space, app or profile names. */
+func panzerCommand(cmdCtx *conf.Context, cliConnection plugin.CliConnection, args []string) error {
+	if len(args) == 0 {
+		return conf.UsageError("please specify a subcommand, usage: cf %s", PanzerUsage)
+	}
+	switch args[0] {
+	case "completion":
+		if len(args) != 2 {
+			return conf.UsageError("please specify the shell, usage: cf %s", PanzerUsage)
+		}
+		script, err := completionScript(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Print(script)
+		return nil
+	case "complete":
+		for _, name := range completeNames(cmdCtx, cliConnection, args[1:]) {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	return conf.UsageError("unknown subcommand %s, usage: cf %s", args[0], PanzerUsage)
+}
+
+/** completeNames - Get the names to complete for "cf panzer complete <orgs|spaces [org]|apps|profiles>". Errors are ignored, they would only mess up the completion. */
+func completeNames(cmdCtx *conf.Context, cliConnection plugin.CliConnection, args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	if args[0] == "profiles" {
+		var names []string
+		for name := range cmdCtx.Settings.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	if err := connect(cmdCtx, cliConnection, completionCacheTTL); err != nil {
+		return nil
+	}
+	defer cmdCtx.Resolver.Save()
+	var names []string
+	switch args[0] {
+	case "orgs":
+		names, _ = cmdCtx.Resolver.GetOrgNames()
+	case "spaces":
+		var orgGuid string
+		if len(args) > 1 && args[1] != "" {
+			orgGuid, _ = cmdCtx.Resolver.GetOrgGuid(args[1])
+		} else if org, err := cliConnection.GetCurrentOrg(); err == nil {
+			orgGuid = org.Guid
+		}
+		if orgGuid != "" {
+			names, _ = cmdCtx.Resolver.GetSpaceNames(orgGuid)
+		}
+	case "apps":
+		if space, err := cliConnection.GetCurrentSpace(); err == nil && space.Guid != "" {
+			names, _ = cmdCtx.Resolver.GetAppNames(space.Guid)
+		}
+	}
+	return names
+}
+
+/** getCompletionCommands - Get the commands and their flags from the flag parsers of the commands. */
+func getCompletionCommands() []completionCommand {
+	var commands []completionCommand
+	for _, cmd := range completionCommands {
+		command := completionCommand{Name: cmd.name, HelpText: cmd.helpText}
+		for _, flag := range cmd.newFlagParser(&conf.Flags{}).Flags {
+			_, isBool := flag.AssignmentVar.(*bool)
+			completion := completionFlag{Short: flag.ShortName, Long: flag.LongName, Description: flag.Description, HasValue: !isBool}
+			if completion.Values = completionValues[cmd.name+"/"+flag.LongName]; completion.Values == "" {
+				completion.Values = completionValues["*/"+flag.LongName]
+			}
+			command.Flags = append(command.Flags, completion)
+		}
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+/** getCompletionLists - The comma separated lists (by kind) whose values are in the completion script. */
+func getCompletionLists() map[string][]string {
+	return map[string][]string{
+		"columns":         append(append([]string{}, ValidColumns...), colIx, "ALL"),
+		"service-columns": append(append([]string{}, ValidServiceColumns...), "ALL"),
+		"event-types":     event.KnownEventTypes,
+	}
+}
+
+// completionChoices are the flag values (by kind) to choose one from, they are in the completion script.
+var completionChoices = map[string][]string{
+	"formats":   {conf.FormatTable, conf.FormatCsv},
+	"scopes":    {conf.ScopeSpace, conf.ScopeOrg, conf.ScopeAll},
+	"groupings": inventoryGroupings,
+}
+
+/** completionScript - Generate the completion script for the given shell. */
+func completionScript(shell string) (string, error) {
+	scripts := map[string]string{"bash": bashCompletion, "zsh": zshCompletion, "fish": fishCompletion}
+	script, found := scripts[shell]
+	if !found {
+		return "", conf.UsageError("unsupported shell %s, supported are bash, zsh and fish", shell)
+	}
+	funcs := template.FuncMap{
+		"quote":     quote,
+		"fishQuote": fishQuote,
+		"zshDesc":   func(s string) string { return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`).Replace(s) },
+		"join":      strings.Join,
+		"funcName":  func(s string) string { return strings.ToLower(strings.ReplaceAll(s, "-", "_")) },
+	}
+	tmpl, err := template.New(shell).Funcs(funcs).Parse(script)
+	if err != nil {
+		return "", conf.NewError(conf.ExitFailure, err, "failed to parse the %s completion template", shell)
+	}
+	data := struct {
+		Commands       []completionCommand
+		PanzerHelpText string
+		Lists          map[string][]string
+		Choices        map[string][]string
+		EnvVars        map[string]string
+	}{getCompletionCommands(), PanzerHelpText, getCompletionLists(), completionChoices, completionEnvVars}
+	var result strings.Builder
+	if err = tmpl.Execute(&result, data); err != nil {
+		return "", conf.NewError(conf.ExitFailure, err, "failed to generate the %s completion", shell)
+	}
+	return result.String(), nil
+}
+
+/** quote - Single quote the string for bash and zsh. */
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+/** fishQuote - Single quote the string for fish. */
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+const bashCompletion = `# bash completion for the cf panzer plugin commands, generated by "cf panzer completion bash"
+# load it with: source <(cf panzer completion bash)
+
+{{range $kind, $values := .Lists}}_cf_panzer_list_{{funcName $kind}}={{quote (join $values " ")}}
+{{end}}
+# complete the last item of a comma separated list, the item can have a + or - in front
+_cf_panzer_list() {
+	local prefix="" item="${cur##*,}"
+	[[ $cur == *,* ]] && prefix="${cur%,*},"
+	[[ $item == [+-]* ]] && prefix="$prefix${item:0:1}" && item="${item:1}"
+	COMPREPLY=($(compgen -P "$prefix" -W "$1" -- "$item"))
+	compopt -o nospace
+}
+
+# complete the org, space, app or profile names, looked up by the plugin
+_cf_panzer_names() {
+	local IFS=$'\n'
+	COMPREPLY=($(compgen -W "$(cf panzer complete "$@" 2>/dev/null)" -- "$cur"))
+}
+
+# the org given with -o or --org on the command line, if any
+_cf_panzer_org() {
+	local i
+	for ((i = 2; i < COMP_CWORD - 1; i++)); do
+		case "${COMP_WORDS[i]}" in
+		-o | --org) echo "${COMP_WORDS[i + 1]}" ;;
+		esac
+	done
+}
+
+_cf_panzer_values() {
+	case "$1" in
+{{- range $kind, $values := .Lists}}
+	{{$kind}}) _cf_panzer_list "$_cf_panzer_list_{{funcName $kind}}" ;;
+{{- end}}
+{{- range $kind, $values := .Choices}}
+	{{$kind}}) COMPREPLY=($(compgen -W {{quote (join $values " ")}} -- "$cur")) ;;
+{{- end}}
+	spaces) _cf_panzer_names spaces "$(_cf_panzer_org)" ;;
+	*) _cf_panzer_names "$1" ;;
+	esac
+}
+
+_cf_panzer() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD - 1]}"
+	if ((COMP_CWORD > 1)); then
+		case "${COMP_WORDS[1]}" in
+{{- range .Commands}}
+		{{.Name}})
+			case "$prev" in
+{{- range .Flags}}{{if .HasValue}}
+			{{if .Short}}-{{.Short}} | {{end}}--{{.Long}}){{if .Values}} _cf_panzer_values {{.Values}};{{end}} return ;;
+{{- end}}{{end}}
+			esac
+			COMPREPLY=($(compgen -W "{{range $i, $flag := .Flags}}{{if $i}} {{end}}{{if .Short}}-{{.Short}} {{end}}--{{.Long}}{{end}}" -- "$cur"))
+			return
+			;;
+{{- end}}
+		panzer)
+			case "$COMP_CWORD/$prev" in
+			2/*) COMPREPLY=($(compgen -W "completion" -- "$cur")) ;;
+			3/completion) COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")) ;;
+			esac
+			return
+			;;
+		esac
+	fi
+	if [[ -n $_cf_panzer_fallback ]]; then
+		"$_cf_panzer_fallback" "$@"
+	elif ((COMP_CWORD == 1)); then
+		COMPREPLY=($(compgen -W "{{range .Commands}}{{.Name}} {{end}}panzer" -- "$cur"))
+	fi
+}
+
+# keep the completion of the other cf commands
+if [[ -z ${_cf_panzer_fallback+set} ]]; then
+	_cf_panzer_fallback=$(complete -p cf 2>/dev/null | sed -n 's/.*-F \([^ ]*\).*/\1/p')
+fi
+complete -F _cf_panzer cf
+`
+
+const zshCompletion = `# zsh completion for the cf panzer plugin commands, generated by "cf panzer completion zsh"
+# load it with: source <(cf panzer completion zsh) (after compinit), this also completes the column names in CF_COLS=
+
+{{range $kind, $values := .Lists}}_cf_panzer_list_{{funcName $kind}}=({{range $values}}{{quote .}} {{end}})
+{{end}}
+# complete an item of a comma separated list, it can have a + or - in front
+_cf_panzer_list() {
+	compset -P '[+-]'
+	compadd "$@"
+}
+
+_cf_panzer_values() {
+	local -a names
+	case $1 in
+{{- range $kind, $values := .Lists}}
+	{{$kind}}) _sequence _cf_panzer_list - $_cf_panzer_list_{{funcName $kind}} ;;
+{{- end}}
+{{- range $kind, $values := .Choices}}
+	{{$kind}}) compadd {{join $values " "}} ;;
+{{- end}}
+	spaces)
+		names=(${(f)"$(cf panzer complete spaces "${opt_args[-o]:-${opt_args[--org]}}" 2>/dev/null)"})
+		compadd -a names
+		;;
+	*)
+		names=(${(f)"$(cf panzer complete $1 2>/dev/null)"})
+		compadd -a names
+		;;
+	esac
+}
+{{range .Commands}}
+_cf_panzer_{{funcName .Name}}() {
+	_arguments{{range .Flags}} \
+		{{if .Short}}'(-{{.Short}} --{{.Long}})'{-{{.Short}},--{{.Long}}}{{else}}--{{.Long}}{{end}}'[{{zshDesc .Description}}]{{if .HasValue}}:{{.Long}}:{{if .Values}}_cf_panzer_values {{.Values}}{{else}} {{end}}{{end}}'
+{{- end}}
+}
+{{end}}
+_cf_panzer_panzer() {
+	_arguments '1:subcommand:(completion)' '2:shell:(bash zsh fish)'
+}
+
+{{- range $envVar, $kind := .EnvVars}}
+
+_cf_panzer_{{funcName $envVar}}() {
+	_cf_panzer_values {{$kind}}
+}
+{{- end}}
+
+_cf_panzer() {
+	if ((CURRENT > 2)); then
+		case ${words[2]} in
+		{{range .Commands}}{{.Name}} | {{end}}panzer)
+			shift words
+			((CURRENT--))
+			_cf_panzer_${words[1]//-/_}
+			return
+			;;
+		esac
+	fi
+	if [[ -n $_cf_panzer_fallback ]]; then
+		$_cf_panzer_fallback "$@"
+	elif ((CURRENT == 2)); then
+		local -a commands
+		commands=({{range .Commands}}{{quote (printf "%s:%s" .Name .HelpText)}} {{end}}{{quote (printf "panzer:%s" .PanzerHelpText)}})
+		_describe command commands
+	fi
+}
+
+# keep the completion of the other cf commands
+if ((!${+_cf_panzer_fallback})); then
+	_cf_panzer_fallback=${_comps[cf]}
+fi
+compdef _cf_panzer cf
+{{- range $envVar, $kind := .EnvVars}}
+compdef _cf_panzer_{{funcName $envVar}} -value-,{{$envVar}},-default-
+{{- end}}
+`
+
+const fishCompletion = `# fish completion for the cf panzer plugin commands, generated by "cf panzer completion fish"
+# load it with: cf panzer completion fish | source
+
+{{range $kind, $values := .Lists}}set -g __cf_panzer_list_{{funcName $kind}}{{range $values}} {{fishQuote .}}{{end}}
+{{end}}
+# complete the last item of a comma separated list, the item can have a + or - in front
+function __cf_panzer_list
+    set -l prefix (string match -r '^(?:.*,)?[+-]?' -- (commandline -ct))
+    for value in $argv
+        echo $prefix$value
+    end
+end
+
+# the org given with -o or --org on the command line, if any
+function __cf_panzer_org
+    set -l tokens (commandline -opc)
+    for i in (seq 2 (count $tokens))
+        if contains -- $tokens[(math $i - 1)] -o --org
+            echo $tokens[$i]
+        end
+    end
+end
+{{range .Commands}}
+complete -c cf -n __fish_use_subcommand -f -a {{.Name}} -d {{fishQuote .HelpText}}
+{{- $name := .Name}}{{range .Flags}}
+complete -c cf -n '__fish_seen_subcommand_from {{$name}}'{{if .Short}}{{if eq (len .Short) 1}} -s {{.Short}}{{else}} -o {{.Short}}{{end}}{{end}} -l {{.Long}} -d {{fishQuote .Description}}
+{{- if .HasValue}} -x{{end}}
+{{- if index $.Lists .Values}} -a '(__cf_panzer_list $__cf_panzer_list_{{funcName .Values}})'
+{{- else if index $.Choices .Values}} -a {{fishQuote (join (index $.Choices .Values) " ")}}
+{{- else if eq .Values "spaces"}} -a '(cf panzer complete spaces (__cf_panzer_org) 2>/dev/null)'
+{{- else if .Values}} -a '(cf panzer complete {{.Values}} 2>/dev/null)'
+{{- end}}
+{{- end}}
+{{end}}
+complete -c cf -n __fish_use_subcommand -f -a panzer -d {{fishQuote .PanzerHelpText}}
+complete -c cf -n '__fish_seen_subcommand_from panzer; and not __fish_seen_subcommand_from completion' -f -a completion
+complete -c cf -n '__fish_seen_subcommand_from panzer; and __fish_seen_subcommand_from completion' -f -a 'bash zsh fish'
+`
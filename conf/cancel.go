@@ -0,0 +1,48 @@
+package conf
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// Cancel - Stop all API calls of the command (the ones in-flight and new ones), the command will fail with the given error.
+func (c *Context) Cancel(cause *Error) {
+	c.cancel(cause)
+}
+
+// CancelOnInterrupt - Cancel the command when the user hits Ctrl-C. A second Ctrl-C kills the plugin as usual.
+func (c *Context) CancelOnInterrupt() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	stopSignals := make(chan struct{})
+	c.stopSignals = stopSignals
+	go func() {
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			c.Cancel(NewError(ExitInterrupted, nil, "interrupted, the API calls were stopped"))
+		case <-stopSignals:
+			signal.Stop(signals)
+		}
+	}()
+}
+
+// CancelCause - Return the error the command was cancelled with (timeout or Ctrl-C), or nil if it was not cancelled.
+func (c *Context) CancelCause() error {
+	if c.CfCtx.Err() == nil {
+		return nil
+	}
+	return context.Cause(c.CfCtx)
+}
+
+// Stop - Stop the --timeout timer and stop listening for Ctrl-C, call it when the command is done.
+func (c *Context) Stop() {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if c.stopSignals != nil {
+		close(c.stopSignals)
+		c.stopSignals = nil
+	}
+}
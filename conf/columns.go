@@ -0,0 +1,132 @@
+package conf
+
+import (
+	"sort"
+	"strings"
+)
+
+// SelectColumns - Parse a comma separated list of (case insensitive) column names, like "Name,State".
+// If all names start with a + or -, they add columns to, or remove them from the default columns, like "+Stack,-Disk". "ALL" selects all valid columns.
+// The source (like "--columns" or "CF_COLS") is mentioned in the error for an unknown column, which suggests the closest valid column names.
+func SelectColumns(requested, source string, defaults, valid []string) ([]string, error) {
+	if strings.EqualFold(strings.TrimSpace(requested), "ALL") {
+		return valid, nil
+	}
+	var items []string
+	modifiers := 0
+	for _, item := range strings.Split(requested, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+			if item[0] == '+' || item[0] == '-' {
+				modifiers++
+			}
+		}
+	}
+	if modifiers > 0 && modifiers < len(items) {
+		return nil, UsageError("invalid columns in %s (%s), use either column names, or +Col/-Col to change the default columns, not both", source, requested)
+	}
+	var selected []string
+	if modifiers > 0 {
+		selected = append(selected, defaults...)
+	}
+	for _, item := range items {
+		var modifier byte
+		if modifiers > 0 {
+			modifier, item = item[0], item[1:]
+		}
+		column, err := findColumn(item, source, valid)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case modifier == '-':
+			selected = removeColumn(selected, column)
+		case modifier == '+' && HasColumn(selected, column):
+			// already there
+		default:
+			selected = append(selected, column)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, UsageError("no columns selected in %s (%s)", source, requested)
+	}
+	return selected, nil
+}
+
+/** findColumn - Return the valid column with the given (case insensitive) name, or an error suggesting the closest valid column names. */
+func findColumn(name, source string, valid []string) (string, error) {
+	for _, column := range valid {
+		if strings.EqualFold(name, column) {
+			return column, nil
+		}
+	}
+	if suggestions := closestColumns(name, valid); len(suggestions) > 0 {
+		return "", UsageError("unknown column %s in %s, did you mean %s ?", name, source, strings.Join(suggestions, " or "))
+	}
+	return "", UsageError("unknown column %s in %s, valid column names are: %s", name, source, strings.Join(valid, ","))
+}
+
+/** closestColumns - Return (at most 3) valid columns that look like the given name: a small edit distance, or containing the name. */
+func closestColumns(name string, valid []string) []string {
+	type candidate struct {
+		column   string
+		distance int
+	}
+	var candidates []candidate
+	lowerName := strings.ToLower(name)
+	maxDistance := 1 + len(name)/4
+	for _, column := range valid {
+		lowerColumn := strings.ToLower(column)
+		distance := editDistance(lowerName, lowerColumn)
+		if distance <= maxDistance || (len(lowerName) > 1 && strings.Contains(lowerColumn, lowerName)) {
+			candidates = append(candidates, candidate{column: column, distance: distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	var closest []string
+	for ix := 0; ix < len(candidates) && ix < 3; ix++ {
+		closest = append(closest, candidates[ix].column)
+	}
+	return closest
+}
+
+/** editDistance - The Levenshtein distance between the two strings. */
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// HasColumn - Return true if the column is one of the selected columns.
+func HasColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+func removeColumn(columns []string, column string) []string {
+	var remaining []string
+	for _, c := range columns {
+		if c != column {
+			remaining = append(remaining, c)
+		}
+	}
+	return remaining
+}
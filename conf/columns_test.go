@@ -0,0 +1,48 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectColumns(t *testing.T) {
+	defaults := []string{"Name", "State", "Memory"}
+	valid := []string{"Name", "State", "Memory", "Disk", "Stack", "Buildpacks"}
+	tests := []struct {
+		name      string
+		requested string
+		want      string
+		wantErr   string
+	}{
+		{name: "column names", requested: "name,Disk", want: "Name,Disk"},
+		{name: "spaces and empty items", requested: " Name , ,stack ", want: "Name,Stack"},
+		{name: "add a column", requested: "+Stack", want: "Name,State,Memory,Stack"},
+		{name: "remove a column", requested: "-state", want: "Name,Memory"},
+		{name: "add and remove", requested: "+Disk,-Memory", want: "Name,State,Disk"},
+		{name: "add a default column", requested: "+Name", want: "Name,State,Memory"},
+		{name: "all", requested: "all", want: strings.Join(valid, ",")},
+		{name: "all with spaces", requested: " ALL ", want: strings.Join(valid, ",")},
+		{name: "typo", requested: "Name,Stak", wantErr: "unknown column Stak in --columns, did you mean Stack or State ?"},
+		{name: "typo in modifier", requested: "+Buildpack", wantErr: "unknown column Buildpack in --columns, did you mean Buildpacks ?"},
+		{name: "unknown without suggestion", requested: "Xyz", wantErr: "unknown column Xyz in --columns, valid column names are: Name,State,Memory,Disk,Stack,Buildpacks"},
+		{name: "mixed names and modifiers", requested: "Name,+Stack", wantErr: "invalid columns in --columns (Name,+Stack), use either column names, or +Col/-Col to change the default columns, not both"},
+		{name: "nothing left", requested: "-Name,-State,-Memory", wantErr: "no columns selected in --columns (-Name,-State,-Memory)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := SelectColumns(tt.requested, "--columns", defaults, valid)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr || ExitCode(err) != ExitFailure {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := strings.Join(selected, ","); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,30 +3,124 @@ package conf
 import (
 	pluginmodels "code.cloudfoundry.org/cli/plugin/models"
 	"context"
-	"github.com/cloudfoundry/go-cfclient/v3/client"
-	"regexp"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/cache"
+	"github.com/metskem/panzer-plugin/cfapi"
+	"time"
 )
 
-var (
-	CfClient                  *client.Client
-	CfCtx                     = context.Background()
-	CurrentOrg                pluginmodels.Organization
-	CurrentSpace              pluginmodels.Space
-	CurrentUser               string
-	FlagLimit                 = 500
-	FlagFilterEventTargetName string
-	FlagFilterEventTargetType string
-	FlagFilterEventTypes      string
-	FlagFilterEventActor      string
-	FlagFilterEventOrgName    string
-	FlagFilterEventSpaceName  string
-	FlagSwitchToSpace         bool
-	FlagRoute                 string
-	FlagAppName               string
-	FlagHideHeaders           bool
-	FlagShowQuotaUsage        bool
-	FlagTimeBefore            string
-	FlagTimeAfter             string
-	FlagIncludeEventData      bool
-	AppNameRegex              regexp.Regexp
-)
+// Context holds everything a single command invocation needs: the CF client, the current target and user and the parsed flags.
+// Commands get it passed in, so they can be run repeatedly in-process without leaking state between runs.
+type Context struct {
+	CfClient     *cfapi.Client
+	CfCtx        context.Context
+	CfHomeDir    string
+	Resolver     *cache.Resolver
+	Settings     *Settings
+	CurrentOrg   pluginmodels.Organization
+	CurrentSpace pluginmodels.Space
+	CurrentUser  string
+	Flags        Flags
+	failures     failures
+	cancel       context.CancelCauseFunc
+	timer        *time.Timer
+	stopSignals  chan struct{}
+}
+
+// Flags holds the command line flags of all commands.
+type Flags struct {
+	Limit                 int
+	FilterEventTargetName string
+	FilterEventTargetType string
+	FilterEventTypes      string
+	FilterEventActor      string
+	FilterEventOrgName    string
+	FilterEventSpaceName  string
+	SwitchToSpace         bool
+	Route                 string
+	Pick                  int
+	Port                  int
+	Probe                 bool
+	AppName               string
+	HideHeaders           bool
+	ShowQuotaUsage        bool
+	ShowSidecars          bool
+	TimeBefore            string
+	TimeAfter             string
+	IncludeEventData      bool
+	Debug                 bool
+	Timeout               time.Duration
+	Columns               string
+	Profile               string
+	Scope                 string
+	Org                   string
+	Samples               int
+	Window                time.Duration
+	Margin                int
+	GroupBy               string
+	Days                  int
+	States                string
+	Format                string
+}
+
+// NewContext - Create the context for one command invocation, with the default flag values. Call SetCfClient before running a command.
+// All API calls use CfCtx, it is cancelled on --timeout or Ctrl-C.
+func NewContext(cfHomeDir string) *Context {
+	cfCtx, cancel := context.WithCancelCause(context.Background())
+	return &Context{
+		CfCtx:     cfCtx,
+		CfHomeDir: cfHomeDir,
+		Settings:  DefaultSettings(),
+		Flags:     Flags{Limit: 500, Format: FormatTable},
+		cancel:    cancel,
+	}
+}
+
+// SetCfClient - Set the CF client, and the resolver that uses it.
+func (c *Context) SetCfClient(cfClient *cfapi.Client) {
+	c.CfClient = cfClient
+	c.Resolver = cache.New(c.CfCtx, cfClient, c.CfHomeDir)
+}
+
+// LoadSettings - Load the plugin config file, its default output format is used unless --format is given.
+func (c *Context) LoadSettings() error {
+	settings, err := LoadSettings(c.CfHomeDir)
+	if err != nil {
+		return err
+	}
+	c.Settings = settings
+	c.Flags.Format = settings.Format
+	return nil
+}
+
+// NewFlagParser - Create a flag parser for the given command, not showing help on unexpected arguments and not handling --version.
+// The flags that all commands have (like --debug) are added to it, ParseFlags also adds the default flags from the config file.
+func NewFlagParser(command string, flags *Flags) *flaggy.Parser {
+	parser := flaggy.NewParser(command)
+	parser.ShowHelpOnUnexpected = false
+	parser.ShowVersionWithVersionFlag = false
+	parser.ShowCompletion = false
+	parser.Bool(&flags.Debug, "", "debug", "Print the http requests to the CF API, and the details of failed requests")
+	parser.String(&flags.Format, "", "format", "The output format, table or csv, the default can be set in the config file")
+	parser.Duration(&flags.Timeout, "", "timeout", "Stop the command if it takes longer than this (like 30s or 2m), default is no timeout")
+	return parser
+}
+
+// ParseFlags - Parse the command line flags of the command (after the default flags for the command from the config file), and start the --timeout timer (if given).
+func (c *Context) ParseFlags(parser *flaggy.Parser, args []string) error {
+	if err := parser.ParseArgs(append(c.Settings.GetDefaultFlags(parser.Name), args...)); err != nil {
+		return UsageError("failed to parse flags: %s", err)
+	}
+	if c.Flags.Format != FormatTable && c.Flags.Format != FormatCsv {
+		return UsageError("invalid --format %s, should be %s or %s", c.Flags.Format, FormatTable, FormatCsv)
+	}
+	if c.Flags.Timeout < 0 {
+		return UsageError("invalid --timeout %s, should be positive", c.Flags.Timeout)
+	}
+	if c.Flags.Timeout > 0 {
+		c.timer = time.AfterFunc(c.Flags.Timeout, func() {
+			c.Cancel(NewError(ExitTimeout, nil, "timed out after %s, the API calls were stopped", c.Flags.Timeout))
+		})
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package conf
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// debugTransport prints the http requests (and the body of failed ones) to stderr, if debug has been switched on.
+type debugTransport struct {
+	base  http.RoundTripper
+	debug *bool
+}
+
+// NewHttpClient - Create the http client for the CF API, it prints the requests to stderr when *debug is true at the time of the request.
+func NewHttpClient(skipSslValidation bool, debug *bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: skipSslValidation}
+	return &http.Client{Transport: &debugTransport{base: transport, debug: debug}}
+}
+
+func (t *debugTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !*t.debug {
+		return t.base.RoundTrip(request)
+	}
+	startTime := time.Now()
+	fmt.Fprintf(os.Stderr, "REQUEST: %s %s\n", request.Method, request.URL)
+	response, err := t.base.RoundTrip(request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "RESPONSE: failed after %dms: %s\n", time.Since(startTime).Milliseconds(), err)
+		return response, err
+	}
+	fmt.Fprintf(os.Stderr, "RESPONSE: %s (%dms)", response.Status, time.Since(startTime).Milliseconds())
+	if requestId := response.Header.Get("X-Vcap-Request-Id"); requestId != "" {
+		fmt.Fprintf(os.Stderr, " X-Vcap-Request-Id: %s", requestId)
+	}
+	fmt.Fprintln(os.Stderr)
+	if response.StatusCode >= http.StatusBadRequest && !strings.HasSuffix(request.URL.Path, "/oauth/token") {
+		// print the body of failed requests, and give the client a copy of it
+		body, _ := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		fmt.Fprintln(os.Stderr, strings.TrimSpace(string(body)))
+		response.Body = io.NopCloser(strings.NewReader(string(body)))
+	}
+	return response, nil
+}
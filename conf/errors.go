@@ -0,0 +1,105 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+)
+
+// The exit codes of the plugin, so scripts can tell the different kinds of failures apart.
+const (
+	ExitOK          = 0
+	ExitFailure     = 1 // any other failure, like invalid flags
+	ExitAuth        = 2 // not logged in, or the token is not accepted
+	ExitNotTargeted = 3 // no org/space targeted
+	ExitNotFound    = 4 // the requested org, space, app or route does not exist
+	ExitAPIError    = 5 // the CF API call failed
+	ExitPartial     = 6 // output was produced, but some of the API calls failed
+	ExitTimeout     = 7 // the command took longer than --timeout
+	ExitInterrupted = 130
+)
+
+// Error is an error with an exit code, commands return it up to Run, which prints it and exits with the exit code.
+type Error struct {
+	ExitCode int
+	Message  string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Message, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewError - Create an error with the given exit code, the underlying error (if any) and a message.
+func NewError(exitCode int, err error, format string, args ...any) *Error {
+	return &Error{ExitCode: exitCode, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// UsageError - Create an error for invalid flags or arguments.
+func UsageError(format string, args ...any) *Error {
+	return NewError(ExitFailure, nil, format, args...)
+}
+
+// APIError - Create an error for a failed CF API call, the exit code depends on the kind of failure (auth, not found or other).
+func APIError(err error, format string, args ...any) *Error {
+	exitCode := ExitAPIError
+	switch {
+	case resource.IsNotAuthenticatedError(err), resource.IsInvalidAuthTokenError(err):
+		exitCode = ExitAuth
+	case resource.IsResourceNotFoundError(err), resource.IsNotFoundError(err), errors.Is(err, client.ErrNoResultsReturned), errors.Is(err, client.ErrExactlyOneResultNotReturned):
+		exitCode = ExitNotFound
+	}
+	return NewError(exitCode, err, format, args...)
+}
+
+// ExitCode - Get the exit code for the given error, errors that are not an *Error give ExitFailure.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var panzerErr *Error
+	if errors.As(err, &panzerErr) {
+		return panzerErr.ExitCode
+	}
+	return ExitFailure
+}
+
+// failures collects the errors a command could continue after, they are reported as one partial failure at the end.
+type failures struct {
+	mutex  sync.Mutex
+	errors []error
+}
+
+// AddFailure - Print (to stderr) and record an error the command could continue after, the command will exit with ExitPartial.
+// Once the command is cancelled (timeout or Ctrl-C) the failing API calls are not printed, Run reports the cancellation instead.
+func (c *Context) AddFailure(err error) {
+	if c.CfCtx.Err() != nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, terminal.FailureColor(err.Error()))
+	c.failures.mutex.Lock()
+	defer c.failures.mutex.Unlock()
+	c.failures.errors = append(c.failures.errors, err)
+}
+
+// PartialError - Return nil if no failures were recorded with AddFailure, otherwise an error with exit code ExitPartial.
+func (c *Context) PartialError() error {
+	c.failures.mutex.Lock()
+	defer c.failures.mutex.Unlock()
+	if len(c.failures.errors) == 0 {
+		return nil
+	}
+	return NewError(ExitPartial, nil, "%d API call(s) failed, the output is incomplete", len(c.failures.errors))
+}
@@ -0,0 +1,47 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+)
+
+// The values of the --scope flag, for the commands that can look beyond the current space.
+const (
+	ScopeSpace = "space" // the targeted space
+	ScopeOrg   = "org"   // all spaces of the targeted org
+	ScopeAll   = "all"   // all orgs and spaces you can see
+)
+
+// ScopeFilters - Check the --scope flag against the current target, and return the org and space guid filters for it (empty filters for ScopeAll).
+func (c *Context) ScopeFilters() (orgGuids client.Filter, spaceGuids client.Filter, err error) {
+	switch c.Flags.Scope {
+	case ScopeSpace:
+		if c.CurrentSpace.Guid == "" {
+			return orgGuids, spaceGuids, NewError(ExitNotTargeted, nil, "please target your org/space first, or use --scope %s", ScopeAll)
+		}
+		spaceGuids.Values = []string{c.CurrentSpace.Guid}
+	case ScopeOrg:
+		if c.CurrentOrg.Guid == "" {
+			return orgGuids, spaceGuids, NewError(ExitNotTargeted, nil, "please target your org first, or use --scope %s", ScopeAll)
+		}
+		orgGuids.Values = []string{c.CurrentOrg.Guid}
+	case ScopeAll:
+	default:
+		return orgGuids, spaceGuids, UsageError("invalid --scope %s, should be one of %s", c.Flags.Scope, strings.Join([]string{ScopeSpace, ScopeOrg, ScopeAll}, ","))
+	}
+	return orgGuids, spaceGuids, nil
+}
+
+// ScopeDescription - Describe the --scope for the "Getting ..." line, like "org x / space y".
+func (c *Context) ScopeDescription() string {
+	switch c.Flags.Scope {
+	case ScopeSpace:
+		return fmt.Sprintf("org %s / space %s", terminal.EntityNameColor(c.CurrentOrg.Name), terminal.EntityNameColor(c.CurrentSpace.Name))
+	case ScopeOrg:
+		return fmt.Sprintf("org %s", terminal.EntityNameColor(c.CurrentOrg.Name))
+	}
+	return "all orgs"
+}
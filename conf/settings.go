@@ -0,0 +1,107 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	settingsFile = "panzer.yml"
+	FormatTable  = "table"
+	FormatCsv    = "csv"
+)
+
+// Settings is the content of the plugin config file $CF_HOME/.cf/panzer.yml, all of it is optional.
+type Settings struct {
+	// Profiles are named column sets for "cf aa", like "perf: Name,Cpu%,MemUsed,Uptime", the profile "default" is used if no columns are requested.
+	Profiles map[string]string `yaml:"profiles"`
+	// Defaults are the default flags per command, like "aa: -u", they are put in front of the flags given on the command line.
+	Defaults map[string]DefaultFlags `yaml:"defaults"`
+	// Thresholds are the percentages (and the droplet age) where we start coloring usage.
+	Thresholds Thresholds `yaml:"thresholds"`
+	// Format is the default output format, "table" or "csv".
+	Format string `yaml:"format"`
+}
+
+// Thresholds holds the percentages used to color the usage columns, and the age used to color the droplet age.
+type Thresholds struct {
+	UsageLow    int `yaml:"usage_low"`     // instance memory/disk usage below this is shown in yellow (over-allocated)
+	UsageHigh   int `yaml:"usage_high"`    // instance memory/disk usage above this is shown in red
+	LogRateHigh int `yaml:"log_rate_high"` // instance log rate usage above this is shown in red
+	QuotaHigh   int `yaml:"quota_high"`    // quota usage above this is shown in red
+	DropletAge  int `yaml:"droplet_age"`   // droplets older than this (in days) are shown in red, the app misses the fixes of newer buildpacks
+}
+
+// DefaultFlags are the default flags of a command, either a string that is split on whitespace (like "--limit 100", there is no quoting),
+// or a list (like ["-a", "my app"]) for flag values with spaces.
+type DefaultFlags []string
+
+// UnmarshalYAML - Accept both a list of flags and a string of flags separated by whitespace.
+func (f *DefaultFlags) UnmarshalYAML(unmarshal func(any) error) error {
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*f = list
+		return nil
+	}
+	var flags string
+	if err := unmarshal(&flags); err != nil {
+		return err
+	}
+	*f = strings.Fields(flags)
+	return nil
+}
+
+// DefaultSettings - The settings used if there is no config file, or for the values missing in it.
+func DefaultSettings() *Settings {
+	return &Settings{
+		Profiles:   make(map[string]string),
+		Defaults:   make(map[string]DefaultFlags),
+		Thresholds: Thresholds{UsageLow: 25, UsageHigh: 90, LogRateHigh: 80, QuotaHigh: 80, DropletAge: 90},
+		Format:     FormatTable,
+	}
+}
+
+// LoadSettings - Read the config file from the .cf directory in cfHomeDir, if there is no config file we return the default settings.
+func LoadSettings(cfHomeDir string) (*Settings, error) {
+	settings := DefaultSettings()
+	path := filepath.Join(cfHomeDir, ".cf", settingsFile)
+	fileContents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, NewError(ExitFailure, err, "failed to read config file %s", path)
+	}
+	if err = yaml.UnmarshalStrict(fileContents, settings); err != nil {
+		return nil, NewError(ExitFailure, err, "invalid config file %s", path)
+	}
+	if settings.Format != FormatTable && settings.Format != FormatCsv {
+		return nil, UsageError("invalid config file %s: format should be %s or %s, not %s", path, FormatTable, FormatCsv, settings.Format)
+	}
+	return settings, nil
+}
+
+// GetProfile - Get the columns of the named profile, returns an error listing the known profiles if there is no such profile.
+func (s *Settings) GetProfile(name string) (string, error) {
+	if columns, found := s.Profiles[name]; found {
+		return columns, nil
+	}
+	var names []string
+	for profileName := range s.Profiles {
+		names = append(names, profileName)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return "", UsageError("unknown profile %s, there are no profiles in %s", name, settingsFile)
+	}
+	return "", UsageError("unknown profile %s, known profiles are: %s", name, strings.Join(names, ","))
+}
+
+// GetDefaultFlags - Get the default flags for the given command from the config file.
+func (s *Settings) GetDefaultFlags(command string) []string {
+	return s.Defaults[command]
+}
@@ -0,0 +1,160 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/** writeSettings - Write the config file in a new CF_HOME, and return the CF_HOME. */
+func writeSettings(t *testing.T, contents string) string {
+	t.Helper()
+	cfHomeDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(cfHomeDir, ".cf"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfHomeDir, ".cf", settingsFile), []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return cfHomeDir
+}
+
+func TestLoadSettingsMissingFile(t *testing.T) {
+	settings, err := LoadSettings(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if defaults := DefaultSettings(); settings.Thresholds != defaults.Thresholds || settings.Format != FormatTable || len(settings.Profiles) != 0 || len(settings.Defaults) != 0 {
+		t.Errorf("got %+v, want the default settings %+v", settings, defaults)
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	defaultThresholds := DefaultSettings().Thresholds
+	tests := []struct {
+		name           string
+		contents       string
+		wantThresholds Thresholds
+		wantFormat     string
+		wantDefaults   map[string]string // the default flags per command, joined with "|"
+		wantErr        string
+	}{
+		{
+			name:           "partial thresholds keep the other defaults",
+			contents:       "thresholds:\n  usage_high: 95\n  droplet_age: 30\n",
+			wantThresholds: Thresholds{UsageLow: defaultThresholds.UsageLow, UsageHigh: 95, LogRateHigh: defaultThresholds.LogRateHigh, QuotaHigh: defaultThresholds.QuotaHigh, DropletAge: 30},
+			wantFormat:     FormatTable,
+		},
+		{
+			name:           "format",
+			contents:       "format: csv\n",
+			wantThresholds: defaultThresholds,
+			wantFormat:     FormatCsv,
+		},
+		{
+			name:           "default flags are split on whitespace, or given as a list",
+			contents:       "defaults:\n  ev: \"--limit  100 -q\"\n  tt: [\"-a\", \"my app\"]\n",
+			wantThresholds: defaultThresholds,
+			wantFormat:     FormatTable,
+			wantDefaults:   map[string]string{"ev": "--limit|100|-q", "tt": "-a|my app", "aa": ""},
+		},
+		{name: "invalid format", contents: "format: json\n", wantErr: "format should be table or csv, not json"},
+		{name: "unknown setting", contents: "treshold:\n  usage_high: 95\n", wantErr: "field treshold not found"},
+		{name: "invalid default flags", contents: "defaults:\n  ev:\n    limit: 100\n", wantErr: "invalid config file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, err := LoadSettings(writeSettings(t, tt.contents))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || ExitCode(err) != ExitFailure {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if settings.Thresholds != tt.wantThresholds {
+				t.Errorf("got thresholds %+v, want %+v", settings.Thresholds, tt.wantThresholds)
+			}
+			if settings.Format != tt.wantFormat {
+				t.Errorf("got format %s, want %s", settings.Format, tt.wantFormat)
+			}
+			for command, wantFlags := range tt.wantDefaults {
+				if got := strings.Join(settings.GetDefaultFlags(command), "|"); got != wantFlags {
+					t.Errorf("%s: got default flags %q, want %q", command, got, wantFlags)
+				}
+			}
+		})
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles map[string]string
+		profile  string
+		want     string
+		wantErr  string
+	}{
+		{name: "known profile", profiles: map[string]string{"perf": "Name,Cpu%"}, profile: "perf", want: "Name,Cpu%"},
+		{name: "unknown profile", profiles: map[string]string{"perf": "Name,Cpu%", "default": "Name"}, profile: "pref", wantErr: "unknown profile pref, known profiles are: default,perf"},
+		{name: "no profiles", profile: "perf", wantErr: "unknown profile perf, there are no profiles in panzer.yml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := DefaultSettings()
+			for name, columns := range tt.profiles {
+				settings.Profiles[name] = columns
+			}
+			columns, err := settings.GetProfile(tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr || ExitCode(err) != ExitFailure {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || columns != tt.want {
+				t.Errorf("got %q (%v), want %q", columns, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFlagsDefaultFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		args        []string
+		wantFormat  string
+		wantTimeout time.Duration
+		wantDebug   bool
+	}{
+		{name: "no config", wantFormat: FormatTable},
+		{name: "format setting", config: "format: csv\n", wantFormat: FormatCsv},
+		{name: "default flags before the format setting", config: "format: csv\ndefaults:\n  aa: --format table --timeout 1m\n", wantFormat: FormatTable, wantTimeout: time.Minute},
+		{name: "command line before the default flags", config: "format: csv\ndefaults:\n  aa: --format table --timeout 1m\n", args: []string{"--format", "csv", "--debug"}, wantFormat: FormatCsv, wantTimeout: time.Minute, wantDebug: true},
+		{name: "default flags of another command", config: "defaults:\n  ev: --format csv\n", wantFormat: FormatTable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfHomeDir := t.TempDir()
+			if tt.config != "" {
+				cfHomeDir = writeSettings(t, tt.config)
+			}
+			c := NewContext(cfHomeDir)
+			defer c.Stop()
+			if err := c.LoadSettings(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err := c.ParseFlags(NewFlagParser("aa", &c.Flags), tt.args); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if c.Flags.Format != tt.wantFormat || c.Flags.Timeout != tt.wantTimeout || c.Flags.Debug != tt.wantDebug {
+				t.Errorf("got format %s, timeout %s and debug %t, want %s, %s and %t", c.Flags.Format, c.Flags.Timeout, c.Flags.Debug, tt.wantFormat, tt.wantTimeout, tt.wantDebug)
+			}
+		})
+	}
+}
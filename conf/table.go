@@ -0,0 +1,71 @@
+package conf
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+)
+
+// Table prints the rows of a command in the requested output format (--format), a (colored) terminal table or csv.
+type Table struct {
+	format    string
+	headers   []string
+	rows      [][]string
+	noHeaders bool
+}
+
+// NewTable - Create a table with the given column headers, in the output format of the command.
+func (c *Context) NewTable(headers []string) *Table {
+	return &Table{format: c.Flags.Format, headers: headers}
+}
+
+// NoHeaders - Do not print the column headers.
+func (t *Table) NoHeaders() {
+	t.noHeaders = true
+}
+
+// Add - Add a row, with one value per column.
+func (t *Table) Add(row ...string) {
+	t.rows = append(t.rows, row)
+}
+
+// PrintTo - Print the table to the given writer. For csv the colors and the padding are removed from the values.
+func (t *Table) PrintTo(writer io.Writer) error {
+	if t.format != FormatCsv {
+		table := terminal.NewTable(t.headers)
+		if t.noHeaders {
+			table.NoHeaders()
+		}
+		for _, row := range t.rows {
+			table.Add(row...)
+		}
+		return table.PrintTo(writer)
+	}
+	csvWriter := csv.NewWriter(writer)
+	if !t.noHeaders {
+		_ = csvWriter.Write(t.headers)
+	}
+	for _, row := range t.rows {
+		var values []string
+		for _, value := range row {
+			values = append(values, strings.TrimSpace(terminal.Decolorize(value)))
+		}
+		_ = csvWriter.Write(values)
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// ShowInfo - Return true if we should print the informational lines, like "Getting apps..." and the summaries, not with -q or csv output.
+func (c *Context) ShowInfo() bool {
+	return !c.Flags.HideHeaders && c.Flags.Format != FormatCsv
+}
+
+// Notice - Print a message that is not part of the output, like "no apps found" or a warning, to stderr, so it never ends up in the (csv) output.
+func (c *Context) Notice(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/cfapi"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+const deploymentActive = "ACTIVE" // the status value of a deployment that is in flight, finished ones are FINALIZED
+
+var deploymentColNames = []string{"app", "strategy", "status", "reason", "step", "type", "old inst", "new inst", "revision", "started", "error"}
+
+/** newDeploymentsFlagParser - Create the flag parser for "cf deployments", also used to generate the shell completion. */
+func newDeploymentsFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("deployments", flags)
+	parser.String(&flags.AppName, "a", "appname", "Filter the output by the given appname (regular expression)")
+	parser.String(&flags.Scope, "", "scope", "Which apps to show: space (the targeted space, default), org (all spaces of the targeted org) or all")
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers (and summary) of the output (handy for automated processing), default is false")
+	return parser
+}
+
+/** listDeployments - The main function to produce the response to list the active (rolling or canary) deployments of the apps. */
+func listDeployments(cmdCtx *conf.Context, args []string) error {
+	cmdCtx.Flags.Scope = conf.ScopeSpace
+	if err := cmdCtx.ParseFlags(newDeploymentsFlagParser(&cmdCtx.Flags), args); err != nil {
+		return err
+	}
+	orgGuids, spaceGuids, err := cmdCtx.ScopeFilters()
+	if err != nil {
+		return err
+	}
+	appNameRegex, err := regexp.Compile(cmdCtx.Flags.AppName)
+	if err != nil {
+		return conf.UsageError("invalid appname filter %s: %s", cmdCtx.Flags.AppName, err)
+	}
+	if cmdCtx.ShowInfo() {
+		fmt.Printf("Getting active deployments for %s as %s...\n\n", cmdCtx.ScopeDescription(), terminal.EntityNameColor(cmdCtx.CurrentUser))
+	}
+	unfilteredApps, err := cmdCtx.CfClient.Applications.ListAll(cmdCtx.CfCtx, &client.AppListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return conf.APIError(err, "failed to get apps")
+	}
+	appData := make(map[string]*resource.App)
+	var appGuids []string
+	for _, app := range unfilteredApps {
+		if appNameRegex.MatchString(app.Name) {
+			appData[app.GUID] = app
+			appGuids = append(appGuids, app.GUID)
+		}
+	}
+	deployments, err := getActiveDeployments(cmdCtx, appGuids)
+	if err != nil {
+		return conf.APIError(err, "failed to get deployments")
+	}
+	if len(deployments) == 0 {
+		cmdCtx.Notice("no active deployments found (of %d apps)", len(appData))
+		return nil
+	}
+	// only the processes of the deploying apps
+	var deployingAppGuids []string
+	for appGuid := range deployments {
+		deployingAppGuids = append(deployingAppGuids, appGuid)
+	}
+	processes, err := listInChunks(deployingAppGuids, func(chunk []string) ([]*resource.Process, error) {
+		return cmdCtx.CfClient.Processes.ListAll(cmdCtx.CfCtx, &client.ProcessListOptions{ListOptions: &client.ListOptions{}, AppGUIDs: client.Filter{Values: chunk}})
+	})
+	if err != nil {
+		return conf.APIError(err, "failed to get processes")
+	}
+	appProcesses := make(map[string][]*resource.Process) // keyed by app guid
+	for _, process := range processes {
+		appProcesses[process.Relationships.App.Data.GUID] = append(appProcesses[process.Relationships.App.Data.GUID], process)
+	}
+
+	colNames := deploymentColNames
+	spaceNames, orgNames := make(map[string]string), make(map[string]string) // keyed by app guid
+	if cmdCtx.Flags.Scope != conf.ScopeSpace {
+		colNames = append([]string{"space"}, colNames...)
+		if cmdCtx.Flags.Scope == conf.ScopeAll {
+			colNames = append([]string{"org"}, colNames...)
+		}
+		for appGuid := range deployments {
+			spaceNames[appGuid], orgNames[appGuid] = getAppLocation(cmdCtx, appData[appGuid], cmdCtx.Flags.Scope == conf.ScopeAll)
+		}
+	}
+	sort.Slice(deployingAppGuids, func(i, j int) bool {
+		iGuid, jGuid := deployingAppGuids[i], deployingAppGuids[j]
+		if orgNames[iGuid] != orgNames[jGuid] {
+			return orgNames[iGuid] < orgNames[jGuid]
+		}
+		if spaceNames[iGuid] != spaceNames[jGuid] {
+			return spaceNames[iGuid] < spaceNames[jGuid]
+		}
+		return strings.ToLower(appData[iGuid].Name) < strings.ToLower(appData[jGuid].Name)
+	})
+
+	table := cmdCtx.NewTable(colNames)
+	if cmdCtx.Flags.HideHeaders {
+		table.NoHeaders()
+	}
+	var failed int
+	for _, appGuid := range deployingAppGuids {
+		deployment := deployments[appGuid]
+		types, oldInstances, newInstances := getDeploymentInstances(deployment, appProcesses[appGuid])
+		revision := "-"
+		if deployment.Revision.Version != nil {
+			revision = fmt.Sprintf("%d", *deployment.Revision.Version)
+		}
+		deploymentError := "-"
+		if deployment.Status.Details["error"] != "" {
+			deploymentError = terminal.FailureColor(deployment.Status.Details["error"])
+			failed++
+		}
+		var colValues []string
+		if cmdCtx.Flags.Scope == conf.ScopeAll {
+			colValues = append(colValues, orgNames[appGuid])
+		}
+		if cmdCtx.Flags.Scope != conf.ScopeSpace {
+			colValues = append(colValues, spaceNames[appGuid])
+		}
+		colValues = append(colValues, appData[appGuid].Name, deployment.Strategy, strings.ToLower(deployment.Status.Value), colorDeploymentReason(deployment.Status.Reason), getCanaryStep(deployment), strings.Join(types, ","),
+			fmt.Sprintf("%8d", oldInstances), fmt.Sprintf("%8d", newInstances), fmt.Sprintf("%8s", revision), deployment.CreatedAt.Format(time.RFC3339), deploymentError)
+		table.Add(colValues...)
+	}
+	_ = table.PrintTo(os.Stdout)
+	if cmdCtx.ShowInfo() {
+		summary := fmt.Sprintf("%d active deployments (of %d apps)", len(deployments), len(appData))
+		if failed > 0 {
+			fmt.Printf("\n  %s, %s\n", terminal.StoppedColor(summary), terminal.FailureColor(fmt.Sprintf("%d with errors", failed)))
+		} else {
+			fmt.Printf("\n  %s\n", terminal.StoppedColor(summary))
+		}
+	}
+	return nil
+}
+
+/** getActiveDeployments - Get the active deployments of the given apps, keyed by app guid (the most recent one, if an app has more than one). */
+func getActiveDeployments(cmdCtx *conf.Context, appGuids []string) (map[string]*cfapi.Deployment, error) {
+	deployments, err := listInChunks(appGuids, func(chunk []string) ([]*cfapi.Deployment, error) {
+		return cmdCtx.CfClient.Deployments.ListAll(cmdCtx.CfCtx, &client.DeploymentListOptions{ListOptions: &client.ListOptions{}, AppGUIDs: client.Filter{Values: chunk}, StatusValues: client.Filter{Values: []string{deploymentActive}}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	appDeployments := make(map[string]*cfapi.Deployment)
+	for _, deployment := range deployments {
+		if deployment.Relationships.App.Data == nil {
+			continue
+		}
+		appGuid := deployment.Relationships.App.Data.GUID
+		if appDeployments[appGuid] == nil || deployment.CreatedAt.After(appDeployments[appGuid].CreatedAt) {
+			appDeployments[appGuid] = deployment
+		}
+	}
+	return appDeployments, nil
+}
+
+/** getDeploymentInstances - The process types the deployment replaces, with the instances of the old processes (being replaced) and of the new processes (of the deployment). */
+func getDeploymentInstances(deployment *cfapi.Deployment, processes []*resource.Process) (types []string, oldInstances, newInstances int) {
+	for _, newProcess := range deployment.NewProcesses {
+		if !slices.Contains(types, newProcess.Type) {
+			types = append(types, newProcess.Type)
+		}
+	}
+	for _, process := range processes {
+		switch getDeploymentRole(deployment, process) {
+		case "new":
+			newInstances += process.Instances
+		case "old":
+			oldInstances += process.Instances
+		}
+	}
+	return types, oldInstances, newInstances
+}
+
+/** getDeploymentRole - "new" if the process is created by the deployment, "old" if it is being replaced by it, and empty if the deployment does not touch it. */
+func getDeploymentRole(deployment *cfapi.Deployment, process *resource.Process) string {
+	role := ""
+	for _, newProcess := range deployment.NewProcesses {
+		if newProcess.GUID == process.GUID {
+			return "new"
+		}
+		if newProcess.Type == process.Type {
+			role = "old"
+		}
+	}
+	return role
+}
+
+/** colorDeploymentReason - The reason of the deployment status in lowercase, paused (like a canary waiting to be continued) in yellow and canceling in red. */
+func colorDeploymentReason(reason string) string {
+	switch reason {
+	case "PAUSED":
+		return terminal.AdvisoryColor(strings.ToLower(reason))
+	case "CANCELING":
+		return terminal.FailureColor(strings.ToLower(reason))
+	}
+	return strings.ToLower(reason)
+}
+
+/** getCanaryStep - The progress of a canary deployment with steps, like "2/3" (step 2 of 3), "-" for other deployments. */
+func getCanaryStep(deployment *cfapi.Deployment) string {
+	if deployment.Canary == nil || deployment.Canary.Steps.Total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%d", deployment.Canary.Steps.Current, deployment.Canary.Steps.Total)
+}
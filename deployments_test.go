@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/metskem/panzer-plugin/cfapi"
+)
+
+/** newTestDeployment - An active rolling deployment of the app, with the given new processes (guid and type). */
+func newTestDeployment(guid, appGuid string, newProcesses ...resource.ProcessReference) *cfapi.Deployment {
+	deployment := &cfapi.Deployment{Deployment: &resource.Deployment{Strategy: "rolling", NewProcesses: newProcesses, Status: resource.DeploymentStatus{Value: deploymentActive, Reason: "DEPLOYING"}, Resource: resource.Resource{GUID: guid}}}
+	deployment.Relationships.App.Data = &resource.Relationship{GUID: appGuid}
+	return deployment
+}
+
+func TestGetDeploymentRole(t *testing.T) {
+	deployment := newTestDeployment("deployment-1", "app-1", resource.ProcessReference{GUID: "proc-web-new", Type: "web"}, resource.ProcessReference{GUID: "proc-worker-new", Type: "worker"})
+	tests := []struct {
+		name    string
+		process *resource.Process
+		want    string
+	}{
+		{name: "new web process", process: newTestProcess("proc-web-new", "app-1", "web", 1, 256), want: "new"},
+		{name: "old web process", process: newTestProcess("proc-web", "app-1", "web", 3, 256), want: "old"},
+		{name: "new worker process", process: newTestProcess("proc-worker-new", "app-1", "worker", 1, 256), want: "new"},
+		{name: "old worker process", process: newTestProcess("proc-worker", "app-1", "worker", 1, 256), want: "old"},
+		{name: "other type is not touched", process: newTestProcess("proc-task", "app-1", "task", 0, 256), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getDeploymentRole(deployment, tt.process); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDeploymentInstances(t *testing.T) {
+	deployment := newTestDeployment("deployment-1", "app-1", resource.ProcessReference{GUID: "proc-web-new", Type: "web"}, resource.ProcessReference{GUID: "proc-worker-new", Type: "worker"}, resource.ProcessReference{GUID: "proc-web-newer", Type: "web"})
+	processes := []*resource.Process{
+		newTestProcess("proc-web", "app-1", "web", 3, 256),
+		newTestProcess("proc-web-new", "app-1", "web", 1, 256),
+		newTestProcess("proc-worker", "app-1", "worker", 2, 256),
+		newTestProcess("proc-worker-new", "app-1", "worker", 1, 256),
+		newTestProcess("proc-task", "app-1", "task", 0, 256),
+	}
+	types, oldInstances, newInstances := getDeploymentInstances(deployment, processes)
+	if strings.Join(types, ",") != "web,worker" || oldInstances != 5 || newInstances != 2 {
+		t.Errorf("got types %v, %d old and %d new instances, want [web worker], 5 old and 2 new instances", types, oldInstances, newInstances)
+	}
+}
+
+func TestGetCanaryStep(t *testing.T) {
+	tests := []struct {
+		name   string
+		canary *cfapi.CanaryStatus
+		want   string
+	}{
+		{name: "rolling deployment has no canary status", want: "-"},
+		{name: "canary without steps", canary: &cfapi.CanaryStatus{}, want: "-"},
+		{name: "canary with steps", canary: &cfapi.CanaryStatus{Steps: cfapi.CanarySteps{Current: 2, Total: 3}}, want: "2/3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := newTestDeployment("deployment-1", "app-1")
+			deployment.Canary = tt.canary
+			if got := getCanaryStep(deployment); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+var domainColNames = []string{"domain", "owner org", "shared orgs", "internal", "router group", "routes"}
+
+/** newDomainsFlagParser - Create the flag parser for "cf domains-overview", also used to generate the shell completion. */
+func newDomainsFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("domains-overview", flags)
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers of the output (handy for automated processing), default is false")
+	return parser
+}
+
+/** listDomains - The main function to produce the response to list the domains overview. */
+func listDomains(cmdCtx *conf.Context, args []string) error {
+	if err := cmdCtx.ParseFlags(newDomainsFlagParser(&cmdCtx.Flags), args); err != nil {
+		return err
+	}
+
+	if cmdCtx.ShowInfo() {
+		fmt.Printf("Getting domains as %s...\n\n", terminal.EntityNameColor(cmdCtx.CurrentUser))
+	}
+	if domains, err := cmdCtx.CfClient.Domains.ListAll(cmdCtx.CfCtx, &client.DomainListOptions{ListOptions: &client.ListOptions{}}); err != nil {
+		return conf.APIError(err, "failed to get domains")
+	} else {
+		if len(domains) == 0 {
+			cmdCtx.Notice("no domains found")
+			return nil
+		}
+		sort.Slice(domains, func(i, j int) bool { return domains[i].Name < domains[j].Name })
+		table := cmdCtx.NewTable(domainColNames)
+		if cmdCtx.Flags.HideHeaders {
+			table.NoHeaders()
+		}
+		// the names of the router groups (of the TCP domains) are only in the routing API
+		var routerGroupNames map[string]string
+		for _, domain := range domains {
+			if domain.RouterGroup != nil {
+				routerGroupNames = getRouterGroupNames(cmdCtx)
+				break
+			}
+		}
+		var totalRoutes, unusedDomains int
+		for _, domain := range domains {
+			if cmdCtx.CfCtx.Err() != nil {
+				return nil // cancelled, Run reports why
+			}
+			ownerOrg := "<shared>"
+			if domain.Relationships.Organization != nil && domain.Relationships.Organization.Data != nil {
+				ownerOrg = getOrgName(cmdCtx, domain.Relationships.Organization.Data.GUID)
+			}
+			var sharedOrgs []string
+			if domain.Relationships.SharedOrganizations != nil {
+				for _, sharedOrg := range domain.Relationships.SharedOrganizations.Data {
+					sharedOrgs = append(sharedOrgs, getOrgName(cmdCtx, sharedOrg.GUID))
+				}
+			}
+			sort.Strings(sharedOrgs)
+			sharedOrgsStr := "-"
+			if len(sharedOrgs) > 0 {
+				sharedOrgsStr = strings.Join(sharedOrgs, ",")
+			}
+			routerGroup := getRouterGroupName(routerGroupNames, domain.RouterGroup)
+			routeCount := getRouteCount(cmdCtx, domain)
+			routeCountStr := fmt.Sprintf("%6d", routeCount)
+			if routeCount == 0 {
+				routeCountStr = terminal.AdvisoryColor(routeCountStr)
+				unusedDomains++
+			} else if routeCount < 0 {
+				routeCountStr = terminal.FailureColor(fmt.Sprintf("%6s", "?"))
+			} else {
+				totalRoutes += routeCount
+			}
+			table.Add(domain.Name, ownerOrg, sharedOrgsStr, strconv.FormatBool(domain.Internal), routerGroup, routeCountStr)
+		}
+		_ = table.PrintTo(os.Stdout)
+		if cmdCtx.ShowInfo() {
+			fmt.Printf("\n  %s\n", terminal.StoppedColor(fmt.Sprintf("%d domains (%d without routes), %d routes", len(domains), unusedDomains, totalRoutes)))
+		}
+	}
+	return nil
+}
+
+/** getRouteCount - Get the number of routes for the given domain, we only ask for one route and use the total from the pagination. Returns -1 if it fails. */
+func getRouteCount(cmdCtx *conf.Context, domain *resource.Domain) int {
+	routeListOptions := client.RouteListOptions{ListOptions: &client.ListOptions{PerPage: 1}, DomainGUIDs: client.Filter{Values: []string{domain.GUID}}}
+	if _, pager, err := cmdCtx.CfClient.Routes.List(cmdCtx.CfCtx, &routeListOptions); err != nil {
+		cmdCtx.AddFailure(conf.APIError(err, "failed to get routes for domain %s", domain.Name))
+		return -1
+	} else {
+		return pager.TotalResults
+	}
+}
+
+/** getOrgName - Get the org name for the given guid, if we can't see the org (not authorized), we return the guid. */
+func getOrgName(cmdCtx *conf.Context, orgGuid string) string {
+	if org, err := cmdCtx.Resolver.GetOrg(orgGuid); err != nil {
+		return orgGuid
+	} else {
+		return org.Name
+	}
+}
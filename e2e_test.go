@@ -0,0 +1,565 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	pluginmodels "code.cloudfoundry.org/cli/plugin/models"
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/metskem/panzer-plugin/cfapi"
+	"github.com/metskem/panzer-plugin/cfapi/fake"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+// envPluginApi holds the url of the stand-in CF API when the test binary runs as the plugin, see runPlugin.
+const envPluginApi = "PANZER_TEST_PLUGIN_API"
+
+// TestMain runs the plugin instead of the tests when runPlugin started the test binary for a command.
+func TestMain(m *testing.M) {
+	if os.Getenv(envPluginApi) != "" {
+		runPluginProcess()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+/** runPluginProcess - Run the plugin like the cf CLI does (the args are the rpc port and the command), logged in as "tester" and targeted at the test space. */
+func runPluginProcess() {
+	cliConnection := &pluginfakes.FakeCliConnection{}
+	cliConnection.IsLoggedInReturns(true, nil)
+	cliConnection.HasOrganizationReturns(true, nil)
+	cliConnection.GetCurrentOrgReturns(pluginmodels.Organization{OrganizationFields: pluginmodels.OrganizationFields{Guid: "org-1", Name: "org1"}}, nil)
+	cliConnection.HasSpaceReturns(true, nil)
+	cliConnection.GetCurrentSpaceReturns(pluginmodels.Space{SpaceFields: pluginmodels.SpaceFields{Guid: testSpaceGuid, Name: "space1"}}, nil)
+	cliConnection.UsernameReturns("tester", nil)
+	cliConnection.ApiEndpointReturns(os.Getenv(envPluginApi), nil)
+	cliConnection.UserGuidReturns("user-1", nil)
+	new(PanzerPlugin).Run(cliConnection, os.Args[2:])
+}
+
+// pluginResult is what a plugin command printed and its exit code.
+type pluginResult struct {
+	stdout   string
+	stderr   string
+	exitCode int
+}
+
+/** runPlugin - Run the plugin command (like "aa") in a new process of the test binary, against a stand-in CF API serving the fake. */
+func runPlugin(t *testing.T, f *fake.Fake, args ...string) pluginResult {
+	server := fake.NewServer(f)
+	defer server.Close()
+	cfHomeDir := t.TempDir()
+	if err := server.WriteCfConfig(cfHomeDir); err != nil {
+		t.Fatalf("failed to write the cf config: %s", err)
+	}
+	cmd := exec.Command(os.Args[0], append([]string{"0"}, args...)...)
+	cmd.Env = append(os.Environ(), envPluginApi+"="+server.URL, "CF_HOME="+cfHomeDir)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	result := pluginResult{}
+	if err := cmd.Run(); err != nil {
+		var exitError *exec.ExitError
+		if !errors.As(err, &exitError) {
+			t.Fatalf("failed to run the plugin: %s", err)
+		}
+		result.exitCode = exitError.ExitCode()
+	}
+	result.stdout, result.stderr = terminal.Decolorize(stdout.String()), terminal.Decolorize(stderr.String())
+	return result
+}
+
+/** newTestSpaceFake - A fake with the test org and space, and the given number of started apps with one web instance each (app-000, app-001, ...). */
+func newTestSpaceFake(apps int) *fake.Fake {
+	f := &fake.Fake{
+		Organizations: []*resource.Organization{{Name: "org1", Resource: resource.Resource{GUID: "org-1"}}},
+		Spaces:        []*resource.Space{newTestSpace(testSpaceGuid, "space1", "org-1")},
+		ProcessStats:  make(map[string]*resource.ProcessStats),
+	}
+	for ix := 0; ix < apps; ix++ {
+		appGuid, processGuid := fmt.Sprintf("app-%03d", ix), fmt.Sprintf("proc-%03d", ix)
+		f.Apps = append(f.Apps, newTestApp(appGuid, appGuid, "STARTED", nil))
+		f.Processes = append(f.Processes, newTestProcess(processGuid, appGuid, "web", 1, 256))
+		f.ProcessStats[processGuid] = newTestStats(1, 128, 0.01)
+	}
+	return f
+}
+
+/** newTestSpace - A space in the given org. */
+func newTestSpace(guid, name, orgGuid string) *resource.Space {
+	return &resource.Space{Name: name, Relationships: &resource.SpaceRelationships{Organization: &resource.ToOneRelationship{Data: &resource.Relationship{GUID: orgGuid}}}, Resource: resource.Resource{GUID: guid}}
+}
+
+/** outputLines - The non-empty lines of the output, without trailing spaces. */
+func outputLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimRight(line, " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+/** newTestRoutesFake - A fake with the test space and 2 apps, host "myhost" in the test space (bound to both apps) and in another org, and TCP port 1024 (router group default-tcp) bound to app-001. */
+func newTestRoutesFake() *fake.Fake {
+	f := newTestSpaceFake(2)
+	f.Organizations = append(f.Organizations, &resource.Organization{Name: "org2", Resource: resource.Resource{GUID: "org-2"}})
+	f.Spaces = append(f.Spaces, newTestSpace("space-2", "space2", "org-2"))
+	f.Domains = []*resource.Domain{{Name: "example.com", Resource: resource.Resource{GUID: "domain-1"}}, {Name: "tcp.example.com", RouterGroup: &resource.Relationship{GUID: "rg-1"}, Resource: resource.Resource{GUID: "domain-tcp"}}}
+	f.RouterGroups = []*cfapi.RouterGroup{{GUID: "rg-1", Name: "default-tcp", Type: "tcp"}}
+	port := 1024
+	f.Routes = []*resource.Route{
+		newTestRoute("route-1", "myhost", "example.com", testSpaceGuid, "domain-1", nil, "app-000", "app-001"),
+		newTestRoute("route-2", "myhost", "example.com", "space-2", "domain-1", nil),
+		newTestRoute("route-3", "", "tcp.example.com:1024", testSpaceGuid, "domain-tcp", &port, "app-001"),
+	}
+	return f
+}
+
+/** newTestRoute - A route in the space and domain, with the apps as destinations. */
+func newTestRoute(guid, host, url, spaceGuid, domainGuid string, port *int, appGuids ...string) *resource.Route {
+	route := &resource.Route{Host: host, URL: url, Port: port, Resource: resource.Resource{GUID: guid}}
+	route.Relationships.Space.Data = &resource.Relationship{GUID: spaceGuid}
+	route.Relationships.Domain.Data = &resource.Relationship{GUID: domainGuid}
+	for _, appGuid := range appGuids {
+		route.Destinations = append(route.Destinations, resource.RouteDestination{App: resource.RouteDestinationApp{GUID: &appGuid}})
+	}
+	return route
+}
+
+/** newTestEventsFake - A fake with the test space and three app events of user "tester", an hour apart (the newest an hour ago). */
+func newTestEventsFake(now time.Time) *fake.Fake {
+	f := newTestSpaceFake(0)
+	for ix, event := range []struct{ eventType, targetName string }{{"audit.app.update", "app-000"}, {"audit.app.restage", "app-001"}, {"audit.app.update", "app-001"}} {
+		f.AuditEvents = append(f.AuditEvents, &resource.AuditEvent{
+			Type:         event.eventType,
+			Actor:        resource.AuditEventRelatedObject{GUID: "user-1", Type: "user", Name: "tester"},
+			Target:       resource.AuditEventRelatedObject{GUID: event.targetName, Type: "app", Name: event.targetName},
+			Space:        resource.Relationship{GUID: testSpaceGuid},
+			Organization: resource.Relationship{GUID: "org-1"},
+			Resource:     resource.Resource{GUID: fmt.Sprintf("event-%d", ix), CreatedAt: now.Add(-time.Duration(3-ix) * time.Hour)},
+		})
+	}
+	return f
+}
+
+// pluginTest is a plugin command run against a fake, with the output and exit code it should give.
+type pluginTest struct {
+	name     string
+	fake     *fake.Fake
+	cfCols   string
+	args     []string
+	exitCode int
+	stdout   []string // all non-empty lines of stdout (without trailing spaces), nil to skip
+	contains []string // parts of stdout
+	lines    int      // the number of non-empty lines of stdout, 0 to skip
+	stderr   string   // part of stderr, empty if nothing should be printed on stderr
+}
+
+/** runPluginTests - Run the plugin commands of the tests and check their output and exit code. */
+func runPluginTests(t *testing.T, tests []pluginTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CF_COLS", tt.cfCols)
+			result := runPlugin(t, tt.fake, tt.args...)
+			if result.exitCode != tt.exitCode {
+				t.Errorf("got exit code %d, want %d\nstdout:\n%s\nstderr:\n%s", result.exitCode, tt.exitCode, result.stdout, result.stderr)
+			}
+			lines := outputLines(result.stdout)
+			if tt.stdout != nil && strings.Join(lines, "\n") != strings.Join(tt.stdout, "\n") {
+				t.Errorf("got stdout:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.stdout, "\n"))
+			}
+			for _, text := range tt.contains {
+				if !strings.Contains(result.stdout, text) {
+					t.Errorf("stdout does not contain %q:\n%s", text, result.stdout)
+				}
+			}
+			if tt.lines != 0 && len(lines) != tt.lines {
+				t.Errorf("got %d lines on stdout, want %d", len(lines), tt.lines)
+			}
+			if (tt.stderr == "" && result.stderr != "") || !strings.Contains(result.stderr, tt.stderr) {
+				t.Errorf("got stderr %q, want %q", result.stderr, tt.stderr)
+			}
+		})
+	}
+}
+
+func TestAppsCommand(t *testing.T) {
+	failingStats := newTestSpaceFake(2)
+	failingStats.Errors = map[string]error{"Processes.GetStats": errors.New("stats unavailable")}
+	failingApps := newTestSpaceFake(2)
+	failingApps.Errors = map[string]error{"Applications.ListAll": errors.New("apps unavailable")}
+	runPluginTests(t, []pluginTest{
+		{
+			name:   "columns with the summary",
+			fake:   newTestSpaceFake(2),
+			cfCols: "Name,Memory,Host,MemUsed",
+			args:   []string{"aa"},
+			stdout: []string{
+				"Getting apps for org org1 / space space1 as tester...",
+				"Ix   Name      Memory   Host       MemUsed",
+				"0    app-000     256M   10.0.0.1   128M (50%)",
+				"0    app-001     256M   10.0.0.1   128M (50%)",
+				"  2 apps (2 started), 2 running instances, Memory(MB): requested:512M, used:256M (50%), Cpu    2%, Disk(MB): requested:1024M, used:256M (25%), LogRate(BPS): requested:32K, used:2048 ( 6%)",
+			},
+		},
+		{
+			name:     "more apps than fit in a page",
+			fake:     newTestSpaceFake(120),
+			cfCols:   "Name,State,#Inst",
+			args:     []string{"aa", "-q"},
+			lines:    120,
+			contains: []string{"app-000   started       1", "app-119   started       1"},
+		},
+		{
+			name:     "failing stats",
+			fake:     failingStats,
+			args:     []string{"aa"},
+			contains: []string{"app-000   started     256M     512M", "1   ?      ?           ?        ?      ?"},
+			stderr:   "failed to get process stats for app app-001: error executing GET request for /v3/processes/proc-001/stats: cfclient error (CF-UnknownError|10001): stats unavailable\n2 API call(s) failed, the output is incomplete",
+			exitCode: conf.ExitPartial,
+		},
+		{
+			name:     "unknown column",
+			fake:     newTestSpaceFake(1),
+			args:     []string{"aa", "--columns", "Name,Stak"},
+			stdout:   []string{"Getting apps for org org1 / space space1 as tester..."},
+			stderr:   "unknown column Stak in --columns, did you mean Stack or State ?",
+			exitCode: conf.ExitFailure,
+		},
+		{
+			name:     "failing apps call",
+			fake:     failingApps,
+			args:     []string{"aa"},
+			stdout:   []string{"Getting apps for org org1 / space space1 as tester..."},
+			stderr:   "(CF-UnknownError|10001): apps unavailable",
+			exitCode: conf.ExitAPIError,
+		},
+	})
+}
+
+func TestRoutesCommand(t *testing.T) {
+	failingRouterGroups := newTestRoutesFake()
+	failingRouterGroups.Errors = map[string]error{"RouterGroups.ListAll": errors.New("routing API unavailable")}
+	runPluginTests(t, []pluginTest{
+		{
+			name: "hostname in two org/spaces",
+			fake: newTestRoutesFake(),
+			args: []string{"lr", "-r", "myhost"},
+			stdout: []string{
+				"Getting routes for hostname myhost as tester...",
+				"hostname   domain        org    space    bound apps",
+				"myhost     example.com   org1   space1   app-000 app-001",
+				"myhost     example.com   org2   space2",
+			},
+		},
+		{
+			name:     "the org/spaces to choose from and the prompt are on stderr",
+			fake:     newTestRoutesFake(),
+			args:     []string{"lr", "-r", "myhost", "-t"},
+			exitCode: conf.ExitFailure,
+			contains: []string{"myhost     example.com   org1   space1   app-000 app-001"},
+			lines:    4,
+			stderr:   "The route was found in 2 org/spaces:\n  1. org1 / space1\n  2. org2 / space2\nWhich one do you want to target (1-2) ? \nno choice made, not targeting",
+		},
+		{
+			name: "tcp port",
+			fake: newTestRoutesFake(),
+			args: []string{"lr", "--port", "1024"},
+			stdout: []string{
+				"Getting TCP routes for port 1024 as tester...",
+				"port   domain            router group   org    space    bound apps",
+				"1024   tcp.example.com   default-tcp    org1   space1   app-001",
+			},
+		},
+		{
+			name: "failing router groups show the guid",
+			fake: failingRouterGroups,
+			args: []string{"lr", "--port", "1024"},
+			stdout: []string{
+				"Getting TCP routes for port 1024 as tester...",
+				"port   domain            router group   org    space    bound apps",
+				"1024   tcp.example.com   rg-1           org1   space1   app-001",
+			},
+			stderr:   "failed to get the router groups, showing their guids",
+			exitCode: conf.ExitPartial,
+		},
+		{
+			name:     "unknown hostname",
+			fake:     newTestRoutesFake(),
+			args:     []string{"lr", "-r", "nohost"},
+			stdout:   []string{"Getting routes for hostname nohost as tester..."},
+			stderr:   "no routes found for hostname nohost",
+			exitCode: conf.ExitNotFound,
+		},
+		{
+			name:     "no hostname or port",
+			fake:     newTestRoutesFake(),
+			args:     []string{"lr"},
+			stdout:   []string{},
+			stderr:   "Please use the -r flag to specify the route name, or the --port flag to specify the TCP port",
+			exitCode: conf.ExitFailure,
+		},
+	})
+}
+
+func TestEventsCommand(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	timestamp := func(hoursAgo int) string {
+		return now.Add(-time.Duration(hoursAgo) * time.Hour).Local().Format("2006-01-02T15:04:05")
+	}
+	failingEvents := newTestEventsFake(now)
+	failingEvents.Errors = map[string]error{"AuditEvents.List": errors.New("events unavailable")}
+	runPluginTests(t, []pluginTest{
+		{
+			name: "oldest first",
+			fake: newTestEventsFake(now),
+			args: []string{"ev"},
+			stdout: []string{
+				"Getting events as tester...",
+				"timestamp             event-type          target-name   target-type   actor          data",
+				timestamp(3) + "   audit.app.update    app-000       app           user: tester   -",
+				timestamp(2) + "   audit.app.restage   app-001       app           user: tester   -",
+				timestamp(1) + "   audit.app.update    app-001       app           user: tester   -",
+			},
+		},
+		{
+			name:   "event type and target name filter without headers",
+			fake:   newTestEventsFake(now),
+			args:   []string{"ev", "-q", "--event-type", "audit.app.update", "--target-name", "app-001"},
+			stdout: []string{timestamp(1) + "   audit.app.update   app-001   app   user: tester   -"},
+		},
+		{
+			name:     "limit gives the newest",
+			fake:     newTestEventsFake(now),
+			args:     []string{"ev", "-q", "--limit", "2"},
+			contains: []string{timestamp(2) + "   audit.app.restage", timestamp(1) + "   audit.app.update"},
+			lines:    2,
+		},
+		{
+			name:   "no events",
+			fake:   newTestSpaceFake(0),
+			args:   []string{"ev"},
+			stdout: []string{"Getting events as tester..."},
+			stderr: "no audit_events found",
+		},
+		{
+			name:     "invalid time",
+			fake:     newTestEventsFake(now),
+			args:     []string{"ev", "--time-after", "yesterday"},
+			stdout:   []string{"Getting events as tester..."},
+			stderr:   "invalid time format: yesterday",
+			exitCode: conf.ExitFailure,
+		},
+		{
+			name:     "failing events call",
+			fake:     failingEvents,
+			args:     []string{"ev"},
+			stdout:   []string{"Getting events as tester..."},
+			stderr:   "(CF-UnknownError|10001): events unavailable",
+			exitCode: conf.ExitAPIError,
+		},
+	})
+}
+
+func TestRightsizeCommand(t *testing.T) {
+	crashed := newTestSpaceFake(2)
+	crashed.ProcessStats["proc-001"].Stats[0].State = "CRASHED"
+	failingStats := newTestSpaceFake(1)
+	failingStats.Errors = map[string]error{"Processes.GetStats": errors.New("stats unavailable")}
+	runPluginTests(t, []pluginTest{
+		{
+			name: "totals skip the apps without usage",
+			fake: crashed,
+			args: []string{"rightsize", "-s", "2", "-w", "0s", "-m", "25"},
+			stdout: []string{
+				"Sampling the usage of the apps in org org1 / space space1 2 times over 0s as tester...",
+				"sample 1/2",
+				"sample 2/2",
+				"app       type   #inst   memory   peak memory   rec. memory   disk     peak disk    rec. disk   memory saving",
+				"app-000   web        1     256M   128M (50%)      192M          512M   128M (25%)     256M         64M",
+				"app-001   web        1     256M   ?             ?               512M   ?            ?           ?",
+				"  2 processes, Memory: allocated:256M, recommended:192M, saving:64M (25%), Disk: allocated:512M, recommended:256M, saving:256M (50%)",
+			},
+		},
+		{
+			name:     "failed samples",
+			fake:     failingStats,
+			args:     []string{"rightsize", "-s", "2", "-w", "0s"},
+			contains: []string{"app-000   web        1     256M   ?", "app-000 (web): the stats failed in 2 of 2 samples"},
+			stderr:   "failed to get process stats for app app-000",
+			exitCode: conf.ExitPartial,
+		},
+	})
+}
+
+/** newTestInventoryFake - A fake with a java app, a nodejs+java app, a docker app and a stopped app that is not staged, in the test space. */
+func newTestInventoryFake() *fake.Fake {
+	f := newTestSpaceFake(0)
+	f.Apps = []*resource.App{
+		newTestApp("app-java", "java-app", "STARTED", &resource.Lifecycle{Type: "buildpack", Data: &resource.BuildpackLifecycle{Stack: "cflinuxfs4"}}),
+		newTestApp("app-multi", "multi-app", "STARTED", &resource.Lifecycle{Type: "buildpack", Data: &resource.BuildpackLifecycle{Stack: "cflinuxfs4"}}),
+		newTestApp("app-docker", "docker-app", "STARTED", &resource.Lifecycle{Type: "docker", Data: &resource.DockerLifecycle{}}),
+		newTestApp("app-new", "new-app", "STOPPED", &resource.Lifecycle{Type: "buildpack", Data: &resource.BuildpackLifecycle{Stack: "cflinuxfs3"}}),
+	}
+	java := resource.DetectedBuildpack{Name: "java_buildpack", Version: "4.77.0"}
+	javaDroplet, multiDroplet, dockerDroplet := newTestDroplet("droplet-java", "app-java", 0), newTestDroplet("droplet-multi", "app-multi", 0), newTestDroplet("droplet-docker", "app-docker", 0)
+	javaDroplet.Stack, javaDroplet.Buildpacks = "cflinuxfs4", []resource.DetectedBuildpack{java}
+	multiDroplet.Stack, multiDroplet.Buildpacks = "cflinuxfs4", []resource.DetectedBuildpack{{Name: "nodejs_buildpack", Version: "1.8.30"}, java}
+	f.Droplets = []*resource.Droplet{javaDroplet, multiDroplet, dockerDroplet}
+	f.CurrentDroplets = map[string]string{"app-java": "droplet-java", "app-multi": "droplet-multi", "app-docker": "droplet-docker"}
+	return f
+}
+
+func TestInventoryCommand(t *testing.T) {
+	runPluginTests(t, []pluginTest{
+		{
+			name: "by stack",
+			fake: newTestInventoryFake(),
+			args: []string{"inventory", "--scope", "space"},
+			stdout: []string{
+				"Getting the stack inventory of the apps for org org1 / space space1 as tester...",
+				"stack        #apps   #started   apps",
+				"cflinuxfs4       2          2   java-app",
+				"                                multi-app",
+				"<docker>         1          1   docker-app",
+				"cflinuxfs3       1          0   new-app",
+				"  4 apps, 3 stack groups, 1 apps not staged",
+			},
+		},
+		{
+			name: "by buildpack",
+			fake: newTestInventoryFake(),
+			args: []string{"inventory", "--scope", "space", "--by", "buildpack"},
+			stdout: []string{
+				"Getting the buildpack inventory of the apps for org org1 / space space1 as tester...",
+				"buildpack                 #apps   #started   apps",
+				"java_buildpack@4.77.0         2          2   java-app",
+				"                                             multi-app",
+				"<docker>                      1          1   docker-app",
+				"<not staged>                  1          0   new-app",
+				"nodejs_buildpack@1.8.30       1          1   multi-app",
+				"  4 apps, 4 buildpack groups, 1 apps not staged",
+			},
+		},
+		{
+			name: "by lifecycle in the org, without headers",
+			fake: newTestInventoryFake(),
+			args: []string{"inventory", "--scope", "org", "-b", "lifecycle", "-q"},
+			stdout: []string{
+				"buildpack       3          2   space1/java-app",
+				"                               space1/multi-app",
+				"                               space1/new-app",
+				"docker          1          1   space1/docker-app",
+			},
+		},
+		{
+			name:     "invalid grouping",
+			fake:     newTestInventoryFake(),
+			args:     []string{"inventory", "--by", "language"},
+			stdout:   []string{},
+			stderr:   "invalid --by language, should be one of stack,buildpack,lifecycle",
+			exitCode: conf.ExitFailure,
+		},
+	})
+}
+
+/** newTestDeploymentsFake - A fake with 3 apps in the test space, app-000 in a paused canary deployment (step 1 of 3) and app-001 in a rolling deployment. */
+func newTestDeploymentsFake() *fake.Fake {
+	f := newTestSpaceFake(3)
+	version := 2
+	canary := newTestDeployment("deployment-1", "app-000", resource.ProcessReference{GUID: "proc-000-new", Type: "web"})
+	canary.Strategy, canary.Status.Reason, canary.Revision.Version = "canary", "PAUSED", &version
+	canary.Canary = &cfapi.CanaryStatus{Steps: cfapi.CanarySteps{Current: 1, Total: 3}}
+	canary.CreatedAt = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	rolling := newTestDeployment("deployment-2", "app-001", resource.ProcessReference{GUID: "proc-001-new", Type: "web"})
+	rolling.CreatedAt = time.Date(2026, 10, 1, 13, 0, 0, 0, time.UTC)
+	f.Deployments = []*cfapi.Deployment{canary, rolling}
+	f.Processes = append(f.Processes, newTestProcess("proc-000-new", "app-000", "web", 1, 256), newTestProcess("proc-001-new", "app-001", "web", 2, 256))
+	return f
+}
+
+func TestDeploymentsCommand(t *testing.T) {
+	runPluginTests(t, []pluginTest{
+		{
+			name: "canary and rolling deployments",
+			fake: newTestDeploymentsFake(),
+			args: []string{"deployments"},
+			stdout: []string{
+				"Getting active deployments for org org1 / space space1 as tester...",
+				"app       strategy   status   reason      step   type   old inst   new inst   revision   started                error",
+				"app-000   canary     active   paused      1/3    web           1          1          2   2026-10-01T12:00:00Z   -",
+				"app-001   rolling    active   deploying   -      web           1          2          -   2026-10-01T13:00:00Z   -",
+				"  2 active deployments (of 3 apps)",
+			},
+		},
+		{
+			name: "deployment column",
+			fake: newTestDeploymentsFake(),
+			args: []string{"aa", "-q", "--columns", "Name,#Inst,Deployment"},
+			stdout: []string{
+				"app-000       1   old (canary 1/3, paused)",
+				"app-000       1   new (canary 1/3, paused)",
+				"app-001       1   old (rolling, deploying)",
+				"app-001       2   new (rolling, deploying)",
+				"app-002       1   -",
+			},
+		},
+		{
+			name:   "no deployments",
+			fake:   newTestSpaceFake(1),
+			args:   []string{"deployments"},
+			stdout: []string{"Getting active deployments for org org1 / space space1 as tester..."},
+			stderr: "no active deployments found (of 1 apps)",
+		},
+	})
+}
+
+func TestTasksCommand(t *testing.T) {
+	f := newTestSpaceFake(1)
+	f.Tasks = []*resource.Task{
+		newTestTask("running-old", "app-000", taskRunning, 48*time.Hour),
+		newTestTask("failed-new", "app-000", taskFailed, time.Hour),
+		newTestTask("failed-old", "app-000", taskFailed, 48*time.Hour),
+		newTestTask("succeeded-new", "app-000", taskSucceeded, time.Hour),
+	}
+	runPluginTests(t, []pluginTest{
+		{
+			name:     "states, case insensitive",
+			fake:     f,
+			args:     []string{"tt", "-q", "--state", "Failed, running"},
+			contains: []string{"running-old", "failed-new"},
+			lines:    2,
+		},
+		{
+			name:     "invalid state",
+			fake:     f,
+			args:     []string{"tt", "--state", "done"},
+			stdout:   []string{},
+			stderr:   "invalid --state done, should be one or more of pending,running,canceling,succeeded,failed",
+			exitCode: conf.ExitFailure,
+		},
+	})
+}
+
+func TestDomainsCommand(t *testing.T) {
+	runPluginTests(t, []pluginTest{
+		{
+			name: "router group name of the tcp domain",
+			fake: newTestRoutesFake(),
+			args: []string{"domains-overview"},
+			contains: []string{
+				"example.com       <shared>    -             false      -                   2",
+				"tcp.example.com   <shared>    -             false      default-tcp         1",
+			},
+		},
+	})
+}
@@ -24,8 +24,6 @@ const (
 var (
 	ListEventsUsage  = "ev - List recent audit events, use \"cf ev -help\" for full help message"
 	colNames         = []string{"timestamp", "event-type", "target-name", "target-type", "actor", "data"}
-	beforeTime       time.Time
-	afterTime        time.Time
 	TypeAppCreate    = "audit.app.create"
 	TypeProcessCrash = "audit.app.process.crash"
 	TypeProcessReady = "audit.app.process.ready"
@@ -72,122 +70,128 @@ func (list AuditEventList) Swap(i, j int) {
 	list[i], list[j] = list[j], list[i]
 }
 
+// NewFlagParser - Create the flag parser for "cf ev", also used to generate the shell completion.
+func NewFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("ev", flags)
+	parser.Int(&flags.Limit, "l", "limit", "Limit the output to max XXX events")
+	parser.String(&flags.FilterEventTypes, "e", "event-type", "Filter the output (server side), (comma separated list of) event type to exactly match the filter (i.e. audit.app.update,app.crash)")
+	parser.String(&flags.FilterEventTargetName, "n", "target-name", "Filter the output (client side), target name to fuzzy match the filter")
+	parser.String(&flags.FilterEventTargetType, "t", "target-type", "Filter the output (client side), target type to fuzzy match the filter (i.e. app service_binding route)")
+	parser.String(&flags.FilterEventActor, "a", "actor", "Filter the output (client side), actor name to fuzzy match the filter")
+	parser.String(&flags.FilterEventOrgName, "o", "org", "Filter the output (server side), org name to exactly match the filter")
+	parser.String(&flags.FilterEventSpaceName, "s", "space", "Filter the output (server side), space name to exactly match the filter")
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers of the output (handy for automated processing), default is false")
+	parser.String(&flags.TimeBefore, "tb", "time-before", "Filter the output (server side), time before the given time (timeformat: YYYY-MM-DDThh:mm:ssZ)")
+	parser.String(&flags.TimeAfter, "ta", "time-after", "Filter the output (server side), time after the given time (timeformat: YYYY-MM-DDThh:mm:ssZ)")
+	parser.Bool(&flags.IncludeEventData, "d", "include-data", "Include the event data in the output (requires a lot of space), default is false")
+	return parser
+}
+
 // GetEvents - Perform an http request to get the audit events
-func GetEvents(cliConnection plugin.CliConnection) {
-	flaggy.DefaultParser.ShowHelpOnUnexpected = false
-	flaggy.DefaultParser.ShowVersionWithVersionFlag = false
-	// Add flags
-	flaggy.Int(&conf.FlagLimit, "l", "limit", "Limit the output to max XXX events")
-	flaggy.String(&conf.FlagFilterEventTypes, "e", "event-type", "Filter the output (server side), (comma separated list of) event type to exactly match the filter (i.e. audit.app.update,app.crash)")
-	flaggy.String(&conf.FlagFilterEventTargetName, "n", "target-name", "Filter the output (client side), target name to fuzzy match the filter")
-	flaggy.String(&conf.FlagFilterEventTargetType, "t", "target-type", "Filter the output (client side), target type to fuzzy match the filter (i.e. app service_binding route)")
-	flaggy.String(&conf.FlagFilterEventActor, "a", "actor", "Filter the output (client side), actor name to fuzzy match the filter")
-	flaggy.String(&conf.FlagFilterEventOrgName, "o", "org", "Filter the output (server side), org name to exactly match the filter")
-	flaggy.String(&conf.FlagFilterEventSpaceName, "s", "space", "Filter the output (server side), space name to exactly match the filter")
-	flaggy.Bool(&conf.FlagHideHeaders, "q", "hide-headers", "Hide the headers of the output (handy for automated processing), default is false")
-	flaggy.String(&conf.FlagTimeBefore, "tb", "time-before", "Filter the output (server side), time before the given time (timeformat: YYYY-MM-DDThh:mm:ssZ)")
-	flaggy.String(&conf.FlagTimeAfter, "ta", "time-after", "Filter the output (server side), time after the given time (timeformat: YYYY-MM-DDThh:mm:ssZ)")
-	flaggy.Bool(&conf.FlagIncludeEventData, "d", "include-data", "Include the event data in the output (requires a lot of space), default is false")
-	flaggy.Parse()
-	if conf.FlagLimit > 5000 {
-		fmt.Printf("Output limited to 5000 rows\n")
-		conf.FlagLimit = 5000
+func GetEvents(cmdCtx *conf.Context, cliConnection plugin.CliConnection, args []string) error {
+	flags := &cmdCtx.Flags
+	if err := cmdCtx.ParseFlags(NewFlagParser(flags), args); err != nil {
+		return err
 	}
-	if conf.FlagLimit == 0 {
-		conf.FlagLimit = 500
+	if flags.Limit > 5000 {
+		cmdCtx.Notice("Output limited to 5000 rows")
+		flags.Limit = 5000
+	}
+	if flags.Limit == 0 {
+		flags.Limit = 500
 	}
 
-	if !conf.FlagHideHeaders {
-		fmt.Printf("Getting events as %s...\n\n", terminal.EntityNameColor(conf.CurrentUser))
+	if cmdCtx.ShowInfo() {
+		fmt.Printf("Getting events as %s...\n\n", terminal.EntityNameColor(cmdCtx.CurrentUser))
 	}
 
+	var beforeTime, afterTime time.Time
 	var timePatternRegex = regexp.MustCompile("^(\\d{4})-(\\d{2})-(\\d{2})T(\\d{2}):(\\d{2}):(\\d{2})Z$")
-	if conf.FlagTimeBefore != "" {
-		if timePatternRegex.MatchString(conf.FlagTimeBefore) {
+	if flags.TimeBefore != "" {
+		if timePatternRegex.MatchString(flags.TimeBefore) {
 			var err error
-			if beforeTime, err = time.Parse(timeFormat+"Z", conf.FlagTimeBefore); err != nil {
-				fmt.Printf("failed to parse time %s: %s\n", conf.FlagTimeBefore, err)
-				os.Exit(1)
+			if beforeTime, err = time.Parse(timeFormat+"Z", flags.TimeBefore); err != nil {
+				return conf.UsageError("failed to parse time %s: %s", flags.TimeBefore, err)
 			}
 		} else {
-			fmt.Printf("invalid time format: %s\n", conf.FlagTimeBefore)
-			os.Exit(1)
+			return conf.UsageError("invalid time format: %s", flags.TimeBefore)
 		}
 	}
-	if conf.FlagTimeAfter != "" {
-		if timePatternRegex.MatchString(conf.FlagTimeAfter) {
+	if flags.TimeAfter != "" {
+		if timePatternRegex.MatchString(flags.TimeAfter) {
 			var err error
-			if afterTime, err = time.Parse(timeFormat+"Z", conf.FlagTimeAfter); err != nil {
-				fmt.Printf("failed to parse time %s: %s\n", conf.FlagTimeAfter, err)
-				os.Exit(1)
+			if afterTime, err = time.Parse(timeFormat+"Z", flags.TimeAfter); err != nil {
+				return conf.UsageError("failed to parse time %s: %s", flags.TimeAfter, err)
 			}
 		} else {
-			fmt.Printf("invalid time format: %s\n", conf.FlagTimeAfter)
-			os.Exit(1)
+			return conf.UsageError("invalid time format: %s", flags.TimeAfter)
 		}
 	}
 	// handle the serverside filters. You can specify one or both of orgname and spacename.
 	var orgGuid, spaceGuid string
-	if conf.FlagFilterEventOrgName != "" {
-		if conf.FlagFilterEventSpaceName != "" {
-			orgGuid = getOrgGuid(conf.FlagFilterEventOrgName)
-			spaceGuid = getSpaceGuid(orgGuid, conf.FlagFilterEventSpaceName)
-		} else {
-			orgGuid = getOrgGuid(conf.FlagFilterEventOrgName)
+	var err error
+	if flags.FilterEventOrgName != "" {
+		if orgGuid, err = getOrgGuid(cmdCtx, flags.FilterEventOrgName); err != nil {
+			return err
+		}
+		if flags.FilterEventSpaceName != "" {
+			if spaceGuid, err = getSpaceGuid(cmdCtx, orgGuid, flags.FilterEventSpaceName); err != nil {
+				return err
+			}
 		}
 	} else {
-		if conf.FlagFilterEventSpaceName != "" {
-			if currentOrg, err := cliConnection.GetCurrentOrg(); err != nil {
-				fmt.Printf("failed to get current org: %s\n", err)
-				os.Exit(1)
+		if flags.FilterEventSpaceName != "" {
+			if currentOrg, err := cliConnection.GetCurrentOrg(); err != nil || currentOrg.Guid == "" {
+				return conf.NewError(conf.ExitNotTargeted, err, "failed to get current org, target an org or use the -o flag")
 			} else {
-				spaceGuid = getSpaceGuid(currentOrg.Guid, conf.FlagFilterEventSpaceName)
+				if spaceGuid, err = getSpaceGuid(cmdCtx, currentOrg.Guid, flags.FilterEventSpaceName); err != nil {
+					return err
+				}
 			}
 		}
 	}
 
 	var types, orgGuids, spaceGuids client.Filter
-	if conf.FlagFilterEventTypes != "" {
-		types = client.Filter{Values: strings.Split(conf.FlagFilterEventTypes, ",")}
+	if flags.FilterEventTypes != "" {
+		types = client.Filter{Values: strings.Split(flags.FilterEventTypes, ",")}
 	}
-	if conf.FlagFilterEventOrgName != "" {
+	if flags.FilterEventOrgName != "" {
 		orgGuids = client.Filter{Values: []string{orgGuid}}
 	}
-	if conf.FlagFilterEventSpaceName != "" {
+	if flags.FilterEventSpaceName != "" {
 		spaceGuids = client.Filter{Values: []string{spaceGuid}}
 	}
 
 	var createdAfter, createdBefore client.TimestampFilter
-	if conf.FlagTimeAfter != "" {
+	if flags.TimeAfter != "" {
 		createdAfter = client.TimestampFilter{Timestamp: []time.Time{afterTime}, Operator: client.FilterModifierGreaterThan}
 	}
-	if conf.FlagTimeBefore != "" {
+	if flags.TimeBefore != "" {
 		createdBefore = client.TimestampFilter{Timestamp: []time.Time{beforeTime}, Operator: client.FilterModifierLessThan}
 	}
 	timeStampFilterList := client.TimestampFilterList{createdAfter, createdBefore}
 
 	auditListOptions := client.AuditEventListOptions{
-		ListOptions:       &client.ListOptions{PerPage: conf.FlagLimit, Page: 1, OrderBy: "-created_at", CreatedAts: timeStampFilterList},
+		ListOptions:       &client.ListOptions{PerPage: flags.Limit, Page: 1, OrderBy: "-created_at", CreatedAts: timeStampFilterList},
 		Types:             types,
 		OrganizationGUIDs: orgGuids,
 		SpaceGUIDs:        spaceGuids}
 
-	if events, _, err := conf.CfClient.AuditEvents.List(conf.CfCtx, &auditListOptions); err != nil {
-		fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get audit events: %s", err)))
-		os.Exit(1)
+	if events, _, err := cmdCtx.CfClient.AuditEvents.List(cmdCtx.CfCtx, &auditListOptions); err != nil {
+		return conf.APIError(err, "failed to get audit events")
 	} else {
 		if len(events) == 0 {
-			fmt.Println("no audit_events found")
+			cmdCtx.Notice("no audit_events found")
 		} else {
-			table := terminal.NewTable(colNames)
-			if conf.FlagHideHeaders {
+			table := cmdCtx.NewTable(colNames)
+			if flags.HideHeaders {
 				table.NoHeaders()
 			}
 			var eventList AuditEventList
 			eventList = events
 			sort.Sort(eventList)
 			for _, event := range eventList {
-				if strings.Contains(event.Target.Name, conf.FlagFilterEventTargetName) && strings.Contains(event.Target.Type, conf.FlagFilterEventTargetType) && strings.Contains(event.Actor.Name, conf.FlagFilterEventActor) {
+				if strings.Contains(event.Target.Name, flags.FilterEventTargetName) && strings.Contains(event.Target.Type, flags.FilterEventTargetType) && strings.Contains(event.Actor.Name, flags.FilterEventActor) {
 					var colValues [6]string
 					colValues[0] = event.CreatedAt.Local().Format(timeFormat)
 					colValues[1] = event.Type
@@ -197,17 +201,13 @@ func GetEvents(cliConnection plugin.CliConnection) {
 						colValues[2] = event.Target.Name
 					}
 					colValues[3] = event.Target.Type
-					actorName := event.Actor.Name
-					if event.Actor.Name == "" {
-						actorName = event.Actor.GUID
-					}
-					colValues[4] = fmt.Sprintf("%s: %s", event.Actor.Type, actorName)
+					colValues[4] = FormatActor(event)
 					colValues[5] = "-"
-					if conf.FlagIncludeEventData {
+					if flags.IncludeEventData {
 						if event.Type == TypeProcessCrash {
 							var processCrashData DataProcessCrashEvent
 							if err = json.Unmarshal(*event.Data, &processCrashData); err != nil {
-								fmt.Printf("failed to unmarshal process crash data: %s\n", err)
+								cmdCtx.Notice("failed to unmarshal process crash data: %s", err)
 							} else {
 								colValues[5] = fmt.Sprintf("index: %d, cell_id: %s, crash_count: %d, exit_description: %s", processCrashData.Index, processCrashData.CellId, processCrashData.CrashCount, processCrashData.ExitDescription)
 							}
@@ -215,7 +215,7 @@ func GetEvents(cliConnection plugin.CliConnection) {
 						if event.Type == TypeProcessReady {
 							var processReadyData DataProcessReadyEvent
 							if err = json.Unmarshal(*event.Data, &processReadyData); err != nil {
-								fmt.Printf("failed to unmarshal process ready data: %s\n", err)
+								cmdCtx.Notice("failed to unmarshal process ready data: %s", err)
 							} else {
 								colValues[5] = fmt.Sprintf("index: %d, cell_id: %s", processReadyData.Index, processReadyData.CellId)
 							}
@@ -223,7 +223,7 @@ func GetEvents(cliConnection plugin.CliConnection) {
 						if event.Type == TypeAppCreate {
 							var appCreateData DataAppCreateEvent
 							if err = json.Unmarshal(*event.Data, &appCreateData); err != nil {
-								fmt.Printf("failed to unmarshal app create data: %s\n", err)
+								cmdCtx.Notice("failed to unmarshal app create data: %s", err)
 							} else {
 								colValues[5] = fmt.Sprintf("buildpacks: %s", strings.Join(appCreateData.Request.Lifecycle.Data.Buildpacks, ","))
 							}
@@ -235,25 +235,44 @@ func GetEvents(cliConnection plugin.CliConnection) {
 			_ = table.PrintTo(os.Stdout)
 		}
 	}
+	return nil
 }
 
-// getOrgGuid - Get the organization guid, given the organization name. Will os.Exit if it fails to find it.
-func getOrgGuid(orgName string) string {
-	org, err := conf.CfClient.Organizations.Single(conf.CfCtx, &client.OrganizationListOptions{ListOptions: &client.ListOptions{}, Names: client.Filter{Values: []string{orgName}}})
+// getOrgGuid - Get the organization guid, given the organization name.
+func getOrgGuid(cmdCtx *conf.Context, orgName string) (string, error) {
+	orgGuid, err := cmdCtx.Resolver.GetOrgGuid(orgName)
 	if err != nil {
-		fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get org by name (%s): %s", orgName, err)))
-		os.Exit(1)
+		return "", conf.APIError(err, "failed to get org by name (%s)", orgName)
 	}
-	return org.GUID
+	return orgGuid, nil
 }
 
-// getSpaceGuid - Get the space guid, given the organization guid and space name. Will os.Exit if it fails to find it.
-func getSpaceGuid(orgGuid, spaceName string) string {
-	spaceListOptions := client.SpaceListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: client.Filter{Values: []string{orgGuid}}, Names: client.Filter{Values: []string{spaceName}}}
-	space, err := conf.CfClient.Spaces.Single(conf.CfCtx, &spaceListOptions)
+// getSpaceGuid - Get the space guid, given the organization guid and space name.
+func getSpaceGuid(cmdCtx *conf.Context, orgGuid, spaceName string) (string, error) {
+	spaceGuid, err := cmdCtx.Resolver.GetSpaceGuid(orgGuid, spaceName)
 	if err != nil {
-		fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get space by name (%s): %s", spaceName, err)))
-		os.Exit(1)
+		return "", conf.APIError(err, "failed to get space by name (%s)", spaceName)
+	}
+	return spaceGuid, nil
+}
+
+// GetLastEvent - Get the most recent audit event of the given target (like an app), nil if there are none (audit events are only kept for a limited time).
+func GetLastEvent(cmdCtx *conf.Context, targetGuid string) (*resource.AuditEvent, error) {
+	events, _, err := cmdCtx.CfClient.AuditEvents.List(cmdCtx.CfCtx, &client.AuditEventListOptions{
+		ListOptions: &client.ListOptions{PerPage: 1, Page: 1, OrderBy: "-created_at"},
+		TargetGUIDs: client.ExclusionFilter{Filter: client.Filter{Values: []string{targetGuid}}},
+	})
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return events[0], nil
+}
+
+// FormatActor - The actor of the event, like "user: admin", with the guid if the actor has no name.
+func FormatActor(event *resource.AuditEvent) string {
+	actorName := event.Actor.Name
+	if event.Actor.Name == "" {
+		actorName = event.Actor.GUID
 	}
-	return space.GUID
+	return fmt.Sprintf("%s: %s", event.Actor.Type, actorName)
 }
@@ -13,6 +13,7 @@ require (
 	code.cloudfoundry.org/cli v7.1.0+incompatible
 	github.com/cloudfoundry/go-cfclient/v3 v3.0.0-alpha.17
 	github.com/integrii/flaggy v1.8.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -75,6 +76,5 @@ require (
 	google.golang.org/protobuf v1.36.11 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/cheggaaa/pb.v1 v1.0.28 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+// The values of the --by flag of "cf inventory".
+const (
+	inventoryByStack     = "stack"
+	inventoryByBuildpack = "buildpack"
+	inventoryByLifecycle = "lifecycle"
+)
+
+const (
+	lifecycleBuildpack = "buildpack"
+	lifecycleDocker    = "docker"
+	dockerImage        = "<docker>"     // docker apps have no stack and no buildpacks
+	notStaged          = "<not staged>" // apps without a staged droplet
+)
+
+var inventoryGroupings = []string{inventoryByStack, inventoryByBuildpack, inventoryByLifecycle}
+
+// inventoryGroup is one stack, buildpack (with version) or lifecycle type, with the apps that use it.
+type inventoryGroup struct {
+	name    string
+	apps    []string
+	started int
+}
+
+/** newInventoryFlagParser - Create the flag parser for "cf inventory", also used to generate the shell completion. */
+func newInventoryFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("inventory", flags)
+	parser.String(&flags.GroupBy, "b", "by", "Group the apps by stack (default), buildpack (name and version, from the droplet) or lifecycle (buildpack, docker or cnb)")
+	parser.String(&flags.AppName, "a", "appname", "Filter the apps by the given appname (regular expression)")
+	parser.String(&flags.Scope, "", "scope", "Which apps to count: all (all orgs and spaces you can see, default), org (all spaces of the targeted org) or space (the targeted space)")
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers (and summary) of the output (handy for automated processing), default is false")
+	return parser
+}
+
+/** listInventory - The main function to produce the response to show the apps grouped by stack, buildpack or lifecycle type. */
+func listInventory(cmdCtx *conf.Context, args []string) error {
+	cmdCtx.Flags.Scope, cmdCtx.Flags.GroupBy = conf.ScopeAll, inventoryByStack
+	if err := cmdCtx.ParseFlags(newInventoryFlagParser(&cmdCtx.Flags), args); err != nil {
+		return err
+	}
+	orgGuids, spaceGuids, err := cmdCtx.ScopeFilters()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(inventoryGroupings, cmdCtx.Flags.GroupBy) {
+		return conf.UsageError("invalid --by %s, should be one of %s", cmdCtx.Flags.GroupBy, strings.Join(inventoryGroupings, ","))
+	}
+	appNameRegex, err := regexp.Compile(cmdCtx.Flags.AppName)
+	if err != nil {
+		return conf.UsageError("invalid appname filter %s: %s", cmdCtx.Flags.AppName, err)
+	}
+	if cmdCtx.ShowInfo() {
+		fmt.Printf("Getting the %s inventory of the apps for %s as %s...\n\n", cmdCtx.Flags.GroupBy, cmdCtx.ScopeDescription(), terminal.EntityNameColor(cmdCtx.CurrentUser))
+	}
+	unfilteredApps, err := cmdCtx.CfClient.Applications.ListAll(cmdCtx.CfCtx, &client.AppListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return conf.APIError(err, "failed to get apps")
+	}
+	var apps []*resource.App
+	for _, app := range unfilteredApps {
+		if appNameRegex.MatchString(app.Name) {
+			apps = append(apps, app)
+		}
+	}
+	if len(apps) == 0 {
+		cmdCtx.Notice("no apps found")
+		return nil
+	}
+	droplets, err := getCurrentDroplets(cmdCtx, getAppGuids(apps))
+	if err != nil {
+		return conf.APIError(err, "failed to get droplets")
+	}
+
+	groups := make(map[string]*inventoryGroup)
+	var unstaged int
+	for _, app := range apps {
+		if cmdCtx.CfCtx.Err() != nil {
+			return nil // cancelled, Run reports why
+		}
+		droplet := droplets[app.GUID]
+		if droplet == nil {
+			unstaged++
+		}
+		appName := app.Name
+		if cmdCtx.Flags.Scope != conf.ScopeSpace {
+			spaceName, orgName := getAppLocation(cmdCtx, app, cmdCtx.Flags.Scope == conf.ScopeAll)
+			appName = spaceName + "/" + appName
+			if cmdCtx.Flags.Scope == conf.ScopeAll {
+				appName = orgName + "/" + appName
+			}
+		}
+		for _, groupName := range getInventoryGroupNames(cmdCtx.Flags.GroupBy, app, droplet) {
+			group := groups[groupName]
+			if group == nil {
+				group = &inventoryGroup{name: groupName}
+				groups[groupName] = group
+			}
+			group.apps = append(group.apps, appName)
+			if app.State == "STARTED" {
+				group.started++
+			}
+		}
+	}
+	var sortedGroups []*inventoryGroup
+	for _, group := range groups {
+		sort.Slice(group.apps, func(i, j int) bool { return strings.ToLower(group.apps[i]) < strings.ToLower(group.apps[j]) })
+		sortedGroups = append(sortedGroups, group)
+	}
+	// the largest groups first
+	sort.Slice(sortedGroups, func(i, j int) bool {
+		if len(sortedGroups[i].apps) != len(sortedGroups[j].apps) {
+			return len(sortedGroups[i].apps) > len(sortedGroups[j].apps)
+		}
+		return sortedGroups[i].name < sortedGroups[j].name
+	})
+
+	table := cmdCtx.NewTable([]string{cmdCtx.Flags.GroupBy, "#apps", "#started", "apps"})
+	if cmdCtx.Flags.HideHeaders {
+		table.NoHeaders()
+	}
+	for _, group := range sortedGroups {
+		table.Add(group.name, fmt.Sprintf("%5d", len(group.apps)), fmt.Sprintf("%8d", group.started), strings.Join(group.apps, "\n"))
+	}
+	_ = table.PrintTo(os.Stdout)
+	if cmdCtx.ShowInfo() {
+		summary := fmt.Sprintf("%d apps, %d %s groups", len(apps), len(sortedGroups), cmdCtx.Flags.GroupBy)
+		if unstaged > 0 {
+			fmt.Printf("\n  %s, %s\n", terminal.StoppedColor(summary), terminal.AdvisoryColor(fmt.Sprintf("%d apps not staged", unstaged)))
+		} else {
+			fmt.Printf("\n  %s\n", terminal.StoppedColor(summary))
+		}
+	}
+	return nil
+}
+
+/** getCurrentDroplets - Get the current droplet (the one the app runs, also after a rollback or "cf set-droplet") of the given apps (not staged ones are left out), keyed by app guid. The CF API only has it per app, so the calls are done concurrently. */
+func getCurrentDroplets(cmdCtx *conf.Context, appGuids []string) (map[string]*resource.Droplet, error) {
+	appDroplets := make(map[string]*resource.Droplet)
+	var mutex sync.Mutex
+	var firstErr error
+	forEachThrottled(cmdCtx.CfCtx, len(appGuids), func(ix int) {
+		droplet, err := cmdCtx.CfClient.Droplets.GetCurrentForApp(cmdCtx.CfCtx, appGuids[ix])
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch {
+		case err == nil:
+			appDroplets[appGuids[ix]] = droplet
+		case resource.IsResourceNotFoundError(err), resource.IsNotFoundError(err):
+			// no current droplet
+		case firstErr == nil:
+			firstErr = err
+		}
+	})
+	if firstErr == nil {
+		firstErr = cmdCtx.CfCtx.Err()
+	}
+	return appDroplets, firstErr
+}
+
+/** getAppGuids - The guids of the given apps. */
+func getAppGuids(apps []*resource.App) []string {
+	var appGuids []string
+	for _, app := range apps {
+		appGuids = append(appGuids, app.GUID)
+	}
+	return appGuids
+}
+
+/** getInventoryGroupNames - The group(s) the app belongs to for the given grouping, an app with more than one buildpack is in the group of each buildpack. */
+func getInventoryGroupNames(groupBy string, app *resource.App, droplet *resource.Droplet) []string {
+	switch groupBy {
+	case inventoryByLifecycle:
+		return []string{getLifecycleType(app.Lifecycle)}
+	case inventoryByStack:
+		if getLifecycleType(app.Lifecycle) == lifecycleDocker {
+			return []string{dockerImage}
+		}
+		if droplet != nil && droplet.Stack != "" {
+			return []string{droplet.Stack}
+		}
+		return []string{getLifecycleStack(app.Lifecycle)}
+	}
+	if getLifecycleType(app.Lifecycle) == lifecycleDocker {
+		return []string{dockerImage}
+	}
+	if droplet == nil {
+		return []string{notStaged}
+	}
+	var buildpacks []string
+	for _, buildpack := range droplet.Buildpacks {
+		buildpacks = append(buildpacks, formatDetectedBuildpack(buildpack))
+	}
+	if len(buildpacks) == 0 {
+		return []string{"?"}
+	}
+	return buildpacks
+}
+
+/** getLifecycleType - The lifecycle type of the app (buildpack, docker or cnb), the CF API leaves it out for buildpack apps on older versions. */
+func getLifecycleType(lifecycle resource.Lifecycle) string {
+	if lifecycle.Type == "" {
+		return lifecycleBuildpack
+	}
+	return lifecycle.Type
+}
+
+/** getLifecycleStack - The stack from the lifecycle of the app, "-" for docker apps (they have no stack) or if it is not set. */
+func getLifecycleStack(lifecycle resource.Lifecycle) string {
+	switch data := lifecycle.Data.(type) {
+	case *resource.BuildpackLifecycle:
+		if data.Stack != "" {
+			return data.Stack
+		}
+	case *resource.CNBLifecycle:
+		if data.Stack != "" {
+			return data.Stack
+		}
+	}
+	return "-"
+}
+
+/** formatDetectedBuildpack - The (system) buildpack name as staging detected it, with its version if the buildpack reports it, like "java_buildpack@4.77.0". */
+func formatDetectedBuildpack(buildpack resource.DetectedBuildpack) string {
+	name := buildpack.Name
+	if name == "" {
+		name = buildpack.BuildpackName
+	}
+	if buildpack.Version == "" {
+		return name
+	}
+	return name + "@" + buildpack.Version
+}
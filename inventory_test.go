@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+)
+
+func TestGetInventoryGroupNames(t *testing.T) {
+	buildpackApp := newTestApp("app-bp", "buildpack-app", "STARTED", &resource.Lifecycle{Type: "buildpack", Data: &resource.BuildpackLifecycle{Buildpacks: []string{"java_buildpack"}, Stack: "cflinuxfs3"}})
+	cnbApp := newTestApp("app-cnb", "cnb-app", "STARTED", &resource.Lifecycle{Type: "cnb", Data: &resource.CNBLifecycle{Stack: "cflinuxfs4"}})
+	dockerApp := newTestApp("app-docker", "docker-app", "STARTED", &resource.Lifecycle{Type: "docker", Data: &resource.DockerLifecycle{}})
+	noLifecycleTypeApp := newTestApp("app-old", "old-app", "STARTED", &resource.Lifecycle{Data: &resource.BuildpackLifecycle{}})
+	droplet := func(stack string, buildpacks ...resource.DetectedBuildpack) *resource.Droplet {
+		droplet := newTestDroplet("droplet", "app", 0)
+		droplet.Stack, droplet.Buildpacks = stack, buildpacks
+		return droplet
+	}
+	java := resource.DetectedBuildpack{Name: "java_buildpack", BuildpackName: "java", Version: "4.77.0"}
+	nodejs := resource.DetectedBuildpack{Name: "nodejs_buildpack", BuildpackName: "nodejs", Version: "1.8.30"}
+	tests := []struct {
+		name    string
+		groupBy string
+		app     *resource.App
+		droplet *resource.Droplet
+		want    string
+	}{
+		{name: "stack of the droplet", groupBy: inventoryByStack, app: buildpackApp, droplet: droplet("cflinuxfs4"), want: "cflinuxfs4"},
+		{name: "stack of the lifecycle without droplet", groupBy: inventoryByStack, app: buildpackApp, want: "cflinuxfs3"},
+		{name: "stack of the lifecycle if the droplet has none", groupBy: inventoryByStack, app: cnbApp, droplet: droplet(""), want: "cflinuxfs4"},
+		{name: "docker has no stack", groupBy: inventoryByStack, app: dockerApp, droplet: droplet(""), want: dockerImage},
+		{name: "no stack", groupBy: inventoryByStack, app: noLifecycleTypeApp, want: "-"},
+		{name: "buildpack with version", groupBy: inventoryByBuildpack, app: buildpackApp, droplet: droplet("cflinuxfs4", java), want: "java_buildpack@4.77.0"},
+		{name: "multi buildpack app is in each group", groupBy: inventoryByBuildpack, app: buildpackApp, droplet: droplet("cflinuxfs4", nodejs, java), want: "nodejs_buildpack@1.8.30,java_buildpack@4.77.0"},
+		{name: "buildpack not staged", groupBy: inventoryByBuildpack, app: buildpackApp, want: notStaged},
+		{name: "buildpack not detected", groupBy: inventoryByBuildpack, app: cnbApp, droplet: droplet("cflinuxfs4"), want: "?"},
+		{name: "docker has no buildpacks", groupBy: inventoryByBuildpack, app: dockerApp, droplet: droplet(""), want: dockerImage},
+		{name: "buildpack lifecycle", groupBy: inventoryByLifecycle, app: buildpackApp, want: "buildpack"},
+		{name: "cnb lifecycle", groupBy: inventoryByLifecycle, app: cnbApp, want: "cnb"},
+		{name: "docker lifecycle", groupBy: inventoryByLifecycle, app: dockerApp, want: "docker"},
+		{name: "no lifecycle type is buildpack", groupBy: inventoryByLifecycle, app: noLifecycleTypeApp, want: "buildpack"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(getInventoryGroupNames(tt.groupBy, tt.app, tt.droplet), ","); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDetectedBuildpack(t *testing.T) {
+	tests := []struct {
+		name      string
+		buildpack resource.DetectedBuildpack
+		want      string
+	}{
+		{name: "name and version", buildpack: resource.DetectedBuildpack{Name: "java_buildpack", BuildpackName: "java", Version: "4.77.0"}, want: "java_buildpack@4.77.0"},
+		{name: "without version", buildpack: resource.DetectedBuildpack{Name: "custom_buildpack"}, want: "custom_buildpack"},
+		{name: "buildpack name if the name is not reported", buildpack: resource.DetectedBuildpack{BuildpackName: "staticfile", Version: "1.6.0"}, want: "staticfile@1.6.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatDetectedBuildpack(tt.buildpack); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
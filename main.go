@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"code.cloudfoundry.org/cli/cf/i18n"
 	"code.cloudfoundry.org/cli/cf/terminal"
@@ -10,19 +11,38 @@ import (
 	"code.cloudfoundry.org/cli/util/configv3"
 	"github.com/cloudfoundry/go-cfclient/v3/client"
 	"github.com/cloudfoundry/go-cfclient/v3/config"
+	"github.com/metskem/panzer-plugin/cfapi"
 	"github.com/metskem/panzer-plugin/conf"
 	"github.com/metskem/panzer-plugin/event"
 	"github.com/metskem/panzer-plugin/version"
 )
 
 const (
-	ListAppsHelpText   = "Lists basic information of apps in the current space"
-	ListRoutesHelpText = "Find the routes with their domain/org/space"
+	ListAppsHelpText     = "Lists basic information of apps in the current space"
+	ListRoutesHelpText   = "Find the routes with their domain/org/space"
+	ListDomainsHelpText  = "List all domains with their owner, shared orgs and number of routes"
+	ListServicesHelpText = "List the service instances with their offering, plan, broker, last operation and bindings"
+	ListBindingsHelpText = "List the service instances bound to the apps, with their plan, binding name and last operation"
+	ListQuotasHelpText   = "Show the org quota and the space quotas of an org, with the usage per space"
+	RightsizeHelpText    = "Recommend memory and disk limits for the apps in the current space, based on their sampled usage"
+	InventoryHelpText    = "Count the apps per stack, buildpack (with version) or lifecycle type, across all orgs and spaces"
+	StaleAppsHelpText    = "List the apps that are stopped for long, have no instances or have an old droplet"
+	DeploymentsHelpText  = "List the active rolling and canary deployments of the apps, with the old and new instances"
+	ListTasksHelpText    = "List the running and recent tasks of the apps, with their state, duration and failure reason"
 )
 
 var (
-	ListAppsUsage   = fmt.Sprintf("aa [-a appname-filter] [-q] [-u], use \"cf aa -help\" for full help message - Use the envvar CF_COLS to specify the output columns, available columns are (comma separated): %s", ValidColumns)
-	ListRoutesUsage = "lr [-t] <-r host-to-lookup>, use \"cf lr -help\" for full help message- Specify the host without the domain name, we will find all routes using this hostname, if option -t given we will also target the org/space"
+	ListAppsUsage     = fmt.Sprintf("aa [-a appname-filter] [-c columns] [-q] [-u] [-s], use \"cf aa -help\" for full help message - Use -c (or the envvar CF_COLS) to specify the output columns (case insensitive, +Col/-Col to add/remove default columns), available columns are (comma separated): %s", ValidColumns)
+	ListRoutesUsage   = "lr [-t [-p N]] [--probe] <-r host-to-lookup | --port tcp-port-to-lookup>, use \"cf lr -help\" for full help message- Specify the host without the domain name, we will find all routes using this hostname, if option -t given we will also target the org/space (if found in multiple org/spaces, you will be asked which one, or use -p N). Use --port to lookup a TCP route by port, use --probe to also check if the (http) routes respond"
+	ListDomainsUsage  = "domains-overview [-q], use \"cf domains-overview -help\" for full help message - List all domains visible to you, with the owning org, shared orgs, internal flag, router group and the number of routes"
+	ListBindingsUsage = "bindings [-a appname-filter] [--scope space|org|all] [-q], use \"cf bindings -help\" for full help message - List for each app the bound service instances with offering, plan, binding name and the last operation of the binding (failed ones in red)"
+	ListQuotasUsage   = "quota-overview [-o org] [-q], use \"cf quota-overview -help\" for full help message - Show the org quota and all space quotas of the (targeted) org, with the memory, instances, routes, service instances and log rate usage per space"
+	RightsizeUsage    = "rightsize [-a appname-filter] [-s samples] [-w window] [-m margin%] [-q], use \"cf rightsize -help\" for full help message - Sample the memory and disk usage of all instances of the started apps a number of times over the window (default 5 times in 1m), and recommend limits (peak usage plus the margin, default 25%) with the projected savings"
+	InventoryUsage    = "inventory [-b stack|buildpack|lifecycle] [-a appname-filter] [--scope all|org|space] [-q], use \"cf inventory -help\" for full help message - Group the apps by stack (default), by buildpack with the version from the droplet, or by lifecycle type (buildpack, docker or cnb), with the number of (started) apps and the list of apps per group"
+	StaleAppsUsage    = "stale-apps [-d days] [-a appname-filter] [--scope space|org|all] [-q], use \"cf stale-apps -help\" for full help message - List the apps that are stopped for more than the given days (default 90), have zero instances, or have a droplet older than the given days, with their memory and their last audit event"
+	DeploymentsUsage  = "deployments [-a appname-filter] [--scope space|org|all] [-q], use \"cf deployments -help\" for full help message - List the active deployments per app with their strategy (rolling or canary), status and reason, the process types being replaced and the instances of the old and new processes"
+	ListTasksUsage    = "tt [-a appname-filter] [-s states] [-w window] [--scope space|org|all] [-q], use \"cf tt -help\" for full help message - List the active tasks and the tasks that started within the window (default 24h) with their name, command, state, memory, disk, duration and failure reason, use -s to filter on state, like \"cf tt -s failed -w 168h\""
+	ListServicesUsage = fmt.Sprintf("ss [--scope space|org|all] [-c columns] [-q], use \"cf ss -help\" for full help message - Use -c (or the envvar %s) to specify the output columns, available columns are (comma separated): %s", ServicesColsEnvVar, ValidServiceColumns)
 )
 
 // PanzerPlugin is the struct implementing the interface defined by the core CLI. It can be found at  "code.cloudfoundry.org/cli/plugin/plugin.go"
@@ -37,30 +57,107 @@ type PanzerPlugin struct{}
 // Any error handling should be handled with the plugin itself (this means printing user facing errors).
 // The CLI will exit 0 if the plugin exits 0 and will exit 1 should the plugin exits nonzero.
 func (c *PanzerPlugin) Run(cliConnection plugin.CliConnection, args []string) {
-	preCheck(cliConnection)
-	cfHomeDir := os.Getenv("CF_HOME")
-	if cfHomeDir == "" {
-		cfHomeDir = os.Getenv("HOME")
+	cmdCtx := conf.NewContext(getCfHomeDir())
+	cmdCtx.CancelOnInterrupt()
+	err := runCommand(cmdCtx, cliConnection, args)
+	cmdCtx.Stop()
+	if cause := cmdCtx.CancelCause(); cause != nil {
+		err = cause
+	} else if err == nil {
+		err = cmdCtx.PartialError()
 	}
-	if cfConfig, err := config.NewFromCFHomeDir(cfHomeDir); err != nil {
-		fmt.Printf("failed to create new config: %s", err)
-		os.Exit(1)
-	} else {
-		if conf.CfClient, err = client.New(cfConfig); err != nil {
-			fmt.Printf("failed to create new cf client: %s\n", err)
-			os.Exit(1)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, terminal.FailureColor(err.Error()))
+		if cmdCtx.Flags.Debug {
+			fmt.Fprintf(os.Stderr, "exit code %d\n", conf.ExitCode(err))
 		}
+		os.Exit(conf.ExitCode(err))
+	}
+}
+
+/** runCommand - Do the common checks, create the CF client and run the requested command. Any error is returned, to be printed by Run. */
+func runCommand(cmdCtx *conf.Context, cliConnection plugin.CliConnection, args []string) error {
+	if err := cmdCtx.LoadSettings(); err != nil {
+		return err
+	}
+	if args[0] == "panzer" {
+		return panzerCommand(cmdCtx, cliConnection, args[1:])
 	}
+	if err := connect(cmdCtx, cliConnection, 0); err != nil {
+		return err
+	}
+	defer cmdCtx.Resolver.Save()
 	switch args[0] {
 	case "aa":
-		checkTarget(cliConnection)
-		listApps(cliConnection)
+		if err := checkTarget(cmdCtx, cliConnection); err != nil {
+			return err
+		}
+		return listApps(cmdCtx, cliConnection, args[1:])
 	case "lr":
-		listRoutes(cliConnection)
+		return listRoutes(cmdCtx, cliConnection, args[1:])
 	case "ev":
-		event.GetEvents(cliConnection)
+		return event.GetEvents(cmdCtx, cliConnection, args[1:])
+	case "domains-overview":
+		return listDomains(cmdCtx, args[1:])
+	case "ss":
+		loadTarget(cmdCtx, cliConnection)
+		return listServices(cmdCtx, args[1:])
+	case "bindings":
+		loadTarget(cmdCtx, cliConnection)
+		return listBindings(cmdCtx, args[1:])
+	case "rightsize":
+		if err := checkTarget(cmdCtx, cliConnection); err != nil {
+			return err
+		}
+		return listRightsize(cmdCtx, args[1:])
+	case "quota-overview":
+		loadTarget(cmdCtx, cliConnection)
+		return listQuotas(cmdCtx, args[1:])
+	case "inventory":
+		loadTarget(cmdCtx, cliConnection)
+		return listInventory(cmdCtx, args[1:])
+	case "stale-apps":
+		loadTarget(cmdCtx, cliConnection)
+		return listStaleApps(cmdCtx, args[1:])
+	case "deployments":
+		loadTarget(cmdCtx, cliConnection)
+		return listDeployments(cmdCtx, args[1:])
+	case "tt":
+		loadTarget(cmdCtx, cliConnection)
+		return listTasks(cmdCtx, args[1:])
+	}
+	return nil
+}
+
+/** connect - Check that we are logged in, create the CF client and load the on-disk cache of the API endpoint and user (defaultCacheTTL is used if CF_PANZER_CACHE_TTL is not set, 0 disables it). The caller should Save the cache when done. */
+func connect(cmdCtx *conf.Context, cliConnection plugin.CliConnection, defaultCacheTTL time.Duration) error {
+	if err := preCheck(cliConnection); err != nil {
+		return err
 	}
+	skipSslValidation, _ := cliConnection.IsSSLDisabled()
+	httpClient := conf.NewHttpClient(skipSslValidation, &cmdCtx.Flags.Debug)
+	if cfConfig, err := config.NewFromCFHomeDir(cmdCtx.CfHomeDir, config.HttpClient(httpClient)); err != nil {
+		return conf.NewError(conf.ExitFailure, err, "failed to create new config")
+	} else {
+		if cfClient, err := client.New(cfConfig); err != nil {
+			return conf.NewError(conf.ExitAuth, err, "failed to create new cf client")
+		} else {
+			cmdCtx.SetCfClient(cfapi.New(cfClient))
+		}
+	}
+	cmdCtx.CurrentUser, _ = cliConnection.Username()
+	apiEndpoint, _ := cliConnection.ApiEndpoint()
+	userGuid, _ := cliConnection.UserGuid()
+	cmdCtx.Resolver.Load(defaultCacheTTL, apiEndpoint, userGuid)
+	return nil
+}
 
+/** getCfHomeDir - The directory that holds the .cf directory, that is $CF_HOME, or $HOME if CF_HOME is not set. */
+func getCfHomeDir() string {
+	if cfHomeDir := os.Getenv("CF_HOME"); cfHomeDir != "" {
+		return cfHomeDir
+	}
+	return os.Getenv("HOME")
 }
 
 // GetMetadata returns a PluginMetadata struct. The first field, Name, determines the name of the plugin which should generally be without spaces.
@@ -77,38 +174,56 @@ func (c *PanzerPlugin) GetMetadata() plugin.PluginMetadata {
 			{Name: "aa", HelpText: ListAppsHelpText, UsageDetails: plugin.Usage{Usage: ListAppsUsage}},
 			{Name: "lr", HelpText: ListRoutesHelpText, UsageDetails: plugin.Usage{Usage: ListRoutesUsage}},
 			{Name: "ev", HelpText: event.ListEventsHelpText, UsageDetails: plugin.Usage{Usage: event.ListEventsUsage}},
+			{Name: "domains-overview", HelpText: ListDomainsHelpText, UsageDetails: plugin.Usage{Usage: ListDomainsUsage}},
+			{Name: "ss", HelpText: ListServicesHelpText, UsageDetails: plugin.Usage{Usage: ListServicesUsage}},
+			{Name: "bindings", HelpText: ListBindingsHelpText, UsageDetails: plugin.Usage{Usage: ListBindingsUsage}},
+			{Name: "quota-overview", HelpText: ListQuotasHelpText, UsageDetails: plugin.Usage{Usage: ListQuotasUsage}},
+			{Name: "rightsize", HelpText: RightsizeHelpText, UsageDetails: plugin.Usage{Usage: RightsizeUsage}},
+			{Name: "inventory", HelpText: InventoryHelpText, UsageDetails: plugin.Usage{Usage: InventoryUsage}},
+			{Name: "stale-apps", HelpText: StaleAppsHelpText, UsageDetails: plugin.Usage{Usage: StaleAppsUsage}},
+			{Name: "deployments", HelpText: DeploymentsHelpText, UsageDetails: plugin.Usage{Usage: DeploymentsUsage}},
+			{Name: "tt", HelpText: ListTasksHelpText, UsageDetails: plugin.Usage{Usage: ListTasksUsage}},
+			{Name: "panzer", HelpText: PanzerHelpText, UsageDetails: plugin.Usage{Usage: PanzerUsage}},
 		},
 	}
 }
 
 // checkTarget Checks if you currently have a targeted org and space.
-func checkTarget(cliConnection plugin.CliConnection) {
+func checkTarget(cmdCtx *conf.Context, cliConnection plugin.CliConnection) error {
 	hasOrg, err := cliConnection.HasOrganization()
 	if err != nil || !hasOrg {
-		fmt.Println(terminal.FailureColor("please target your org/space first"))
-		os.Exit(1)
+		return conf.NewError(conf.ExitNotTargeted, err, "please target your org/space first")
 	}
 	org, _ := cliConnection.GetCurrentOrg()
-	conf.CurrentOrg = org
+	cmdCtx.CurrentOrg = org
 	hasSpace, err := cliConnection.HasSpace()
 	if err != nil || !hasSpace {
-		fmt.Println(terminal.FailureColor("please target your space first"))
-		os.Exit(1)
+		return conf.NewError(conf.ExitNotTargeted, err, "please target your space first")
 	}
 	space, _ := cliConnection.GetCurrentSpace()
-	conf.CurrentSpace = space
+	cmdCtx.CurrentSpace = space
+	return nil
+}
+
+// loadTarget Sets the current org and space (if targeted), for the commands that check the target themselves (like with --scope).
+func loadTarget(cmdCtx *conf.Context, cliConnection plugin.CliConnection) {
+	if hasOrg, err := cliConnection.HasOrganization(); err == nil && hasOrg {
+		cmdCtx.CurrentOrg, _ = cliConnection.GetCurrentOrg()
+	}
+	if hasSpace, err := cliConnection.HasSpace(); err == nil && hasSpace {
+		cmdCtx.CurrentSpace, _ = cliConnection.GetCurrentSpace()
+	}
 }
 
 // preCheck Does all common validations, like being logged in.
-func preCheck(cliConnection plugin.CliConnection) {
+func preCheck(cliConnection plugin.CliConnection) error {
 	v3Config, _ := configv3.LoadConfig()
 	i18n.T = i18n.Init(v3Config)
 	loggedIn, err := cliConnection.IsLoggedIn()
 	if err != nil || !loggedIn {
-		fmt.Println(terminal.NotLoggedInText())
-		os.Exit(1)
+		return conf.NewError(conf.ExitAuth, err, "%s", terminal.NotLoggedInText())
 	}
-	conf.CurrentUser, _ = cliConnection.Username()
+	return nil
 }
 
 // Unlike most Go programs, the `Main()` function will not be used to run all the commands provided in your plugin.
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+)
+
+const (
+	probeTimeout         = 10 * time.Second
+	certExpiryWarnPeriod = 14 * 24 * time.Hour
+)
+
+var probeColNames = []string{"status", "latency", "cert expiry"}
+
+// probeResult - The outcome of probing a route.
+type probeResult struct {
+	scheme     string
+	statusCode int
+	latency    time.Duration
+	certExpiry *time.Time
+	certErr    error // the certificate of the route is not valid (expired, self-signed, wrong host), the request is still done
+	err        error
+}
+
+/** probeRoute - Probe the route (host and path, without scheme) with https, or with http if https is not served at all. Routes on an internal domain are only probed with http. */
+func probeRoute(ctx context.Context, rootCAs *x509.CertPool, routeUrl string, internal bool) probeResult {
+	if internal {
+		return probeUrl(ctx, rootCAs, "http", routeUrl)
+	}
+	result := probeUrl(ctx, rootCAs, "https", routeUrl)
+	if result.err != nil && result.certExpiry == nil && ctx.Err() == nil {
+		// no TLS handshake at all (like an http-only route), try plain http
+		if httpResult := probeUrl(ctx, rootCAs, "http", routeUrl); httpResult.err == nil {
+			return httpResult
+		}
+	}
+	return result
+}
+
+/** probeUrl - Issue a GET request to the url with the given scheme and report the status code, latency and (for https) the expiry and validity of the server certificate (verified against rootCAs, nil is the system roots). */
+func probeUrl(ctx context.Context, rootCAs *x509.CertPool, scheme, routeUrl string) probeResult {
+	result := probeResult{scheme: scheme}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+routeUrl, nil)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	var certMutex sync.Mutex // the handshake runs in a goroutine of the transport, it may outlive a timed out request
+	var certExpiry *time.Time
+	var certErr error
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		// we verify the certificate ourselves in VerifyConnection, so we also get the expiry of an invalid certificate
+		InsecureSkipVerify: true,
+		VerifyConnection: func(connState tls.ConnectionState) error {
+			if len(connState.PeerCertificates) == 0 {
+				return nil
+			}
+			certMutex.Lock()
+			defer certMutex.Unlock()
+			certExpiry = &connState.PeerCertificates[0].NotAfter
+			certErr = verifyCertificate(connState, rootCAs)
+			return nil
+		},
+	}
+	// do not follow redirects, we want to report the status code of the route itself
+	httpClient := &http.Client{Timeout: probeTimeout, Transport: transport, CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	defer httpClient.CloseIdleConnections()
+	startTime := time.Now()
+	resp, err := httpClient.Do(request)
+	result.latency = time.Since(startTime)
+	certMutex.Lock()
+	result.certExpiry, result.certErr = certExpiry, certErr
+	certMutex.Unlock()
+	if err != nil {
+		result.err = err
+		return result
+	}
+	_ = resp.Body.Close()
+	result.statusCode = resp.StatusCode
+	return result
+}
+
+/** verifyCertificate - Verify the certificate chain of the connection for its server name, like the http client does by default. */
+func verifyCertificate(connState tls.ConnectionState, rootCAs *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range connState.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := connState.PeerCertificates[0].Verify(x509.VerifyOptions{DNSName: connState.ServerName, Roots: rootCAs, Intermediates: intermediates})
+	return err
+}
+
+/** getProbeColValues - Format the probe result as values for the probe columns (status, latency, cert expiry). */
+func getProbeColValues(result probeResult) []string {
+	latency := fmt.Sprintf("%dms", result.latency.Milliseconds())
+	certExpiry := "-"
+	if result.certExpiry != nil {
+		daysLeft := int(time.Until(*result.certExpiry).Hours() / 24)
+		certExpiry = fmt.Sprintf("%s (%dd)", result.certExpiry.Format(time.DateOnly), daysLeft)
+		if result.certErr != nil {
+			certExpiry = terminal.FailureColor(fmt.Sprintf("%s %s", certExpiry, result.certErr))
+		} else if time.Until(*result.certExpiry) < certExpiryWarnPeriod {
+			certExpiry = terminal.FailureColor(certExpiry)
+		}
+	}
+	if result.err != nil {
+		if result.certExpiry == nil {
+			certExpiry = terminal.FailureColor(result.err.Error())
+		}
+		return []string{terminal.FailureColor("failed"), latency, certExpiry}
+	}
+	status := fmt.Sprintf("%d", result.statusCode)
+	if result.scheme == "http" {
+		status = fmt.Sprintf("%d (http)", result.statusCode)
+	}
+	if result.statusCode >= 400 {
+		status = terminal.FailureColor(status)
+	} else {
+		status = terminal.SuccessColor(status)
+	}
+	return []string{status, latency, certExpiry}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+)
+
+func TestProbeRoute(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	tlsServer := httptest.NewTLSServer(handler)
+	defer tlsServer.Close()
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	trusted := x509.NewCertPool()
+	trusted.AddCert(tlsServer.Certificate())
+	notAfter := tlsServer.Certificate().NotAfter
+
+	tests := []struct {
+		name       string
+		rootCAs    *x509.CertPool
+		routeUrl   string
+		internal   bool
+		scheme     string
+		statusCode int
+		certExpiry bool
+		certErr    bool
+	}{
+		{name: "valid certificate", rootCAs: trusted, routeUrl: strings.TrimPrefix(tlsServer.URL, "https://"), scheme: "https", statusCode: http.StatusOK, certExpiry: true},
+		{name: "untrusted certificate keeps its expiry", rootCAs: x509.NewCertPool(), routeUrl: strings.TrimPrefix(tlsServer.URL, "https://"), scheme: "https", statusCode: http.StatusOK, certExpiry: true, certErr: true},
+		{name: "redirect is not followed", rootCAs: trusted, routeUrl: strings.TrimPrefix(tlsServer.URL, "https://") + "/redirect", scheme: "https", statusCode: http.StatusFound, certExpiry: true},
+		{name: "http only route falls back to http", routeUrl: strings.TrimPrefix(httpServer.URL, "http://"), scheme: "http", statusCode: http.StatusOK},
+		{name: "internal route uses http", routeUrl: strings.TrimPrefix(httpServer.URL, "http://"), internal: true, scheme: "http", statusCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := probeRoute(context.Background(), tt.rootCAs, tt.routeUrl, tt.internal)
+			if result.err != nil {
+				t.Fatalf("unexpected error: %s", result.err)
+			}
+			if result.scheme != tt.scheme || result.statusCode != tt.statusCode {
+				t.Errorf("got %s %d, want %s %d", result.scheme, result.statusCode, tt.scheme, tt.statusCode)
+			}
+			if tt.certExpiry && (result.certExpiry == nil || !result.certExpiry.Equal(notAfter)) {
+				t.Errorf("got cert expiry %v, want %v", result.certExpiry, notAfter)
+			}
+			if !tt.certExpiry && result.certExpiry != nil {
+				t.Errorf("got cert expiry %v, want none", result.certExpiry)
+			}
+			if (result.certErr != nil) != tt.certErr {
+				t.Errorf("got cert error %v, want error: %t", result.certErr, tt.certErr)
+			}
+		})
+	}
+}
+
+func TestProbeRouteFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	routeUrl := strings.TrimPrefix(server.URL, "http://")
+	server.Close() // nothing listens anymore
+	result := probeRoute(context.Background(), nil, routeUrl, false)
+	if result.err == nil {
+		t.Fatalf("expected an error for a closed server")
+	}
+	values := getProbeColValues(result)
+	if terminal.Decolorize(values[0]) != "failed" || !strings.Contains(values[2], "connect") {
+		t.Errorf("unexpected column values %q", values)
+	}
+}
+
+func TestGetProbeColValues(t *testing.T) {
+	expiry := time.Now().Add(100 * 24 * time.Hour)
+	soon := time.Now().Add(24 * time.Hour)
+	expired := time.Now().Add(-48 * time.Hour)
+	tests := []struct {
+		name   string
+		result probeResult
+		want   []string
+	}{
+		{name: "ok", result: probeResult{scheme: "https", statusCode: 200, latency: 12 * time.Millisecond, certExpiry: &expiry}, want: []string{"200", "12ms", expiry.Format(time.DateOnly) + " (99d)"}},
+		{name: "http", result: probeResult{scheme: "http", statusCode: 404, latency: 3 * time.Millisecond}, want: []string{"404 (http)", "3ms", "-"}},
+		{name: "expires soon", result: probeResult{scheme: "https", statusCode: 200, certExpiry: &soon}, want: []string{"200", "0ms", soon.Format(time.DateOnly) + " (0d)"}},
+		{name: "expired", result: probeResult{scheme: "https", statusCode: 200, certExpiry: &expired, certErr: x509.CertificateInvalidError{Reason: x509.Expired}}, want: []string{"200", "0ms", expired.Format(time.DateOnly) + " (-2d) x509: certificate has expired or is not yet valid: "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := getProbeColValues(tt.result)
+			for ix := range values {
+				values[ix] = terminal.Decolorize(values[ix])
+			}
+			if strings.Join(values, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %q, want %q", values, tt.want)
+			}
+		})
+	}
+}
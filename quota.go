@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+const (
+	unlimited    = "∞"
+	quotaMemory  = "memory"
+	quotaLogRate = "log rate"
+)
+
+// quotaLimits holds the name and limits of an org or space quota (nil limits are unlimited), both quota types of the CF API have these.
+type quotaLimits struct {
+	name     string
+	apps     resource.AppsQuota
+	services resource.ServicesQuota
+	routes   resource.RoutesQuota
+	unknown  bool // we failed to get the quota, only the usage is shown
+}
+
+// quotaUsage holds what counts against a quota, for an org or a space.
+type quotaUsage struct {
+	memory           int // MB, of the started app instances and running tasks
+	maxProcessMemory int // MB, the largest of a started process or running task
+	instances        int // of the started apps
+	maxAppTasks      int // running tasks of the app with the most
+	routes           int
+	reservedPorts    int // of the tcp routes
+	serviceInstances int
+	serviceKeys      int
+	logRate          int // bytes per second, of the started app instances and running tasks
+}
+
+// quotaDimension is one of the limits of a quota, with the usage that counts against it.
+type quotaDimension struct {
+	name      string
+	used      int
+	limit     *int // nil is unlimited
+	allocated bool // used is what is allocated (like memory), not a number of things
+	format    func(int) string
+}
+
+/** newQuotaFlagParser - Create the flag parser for "cf quota-overview", also used to generate the shell completion. */
+func newQuotaFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("quota-overview", flags)
+	parser.String(&flags.Org, "o", "org", "The org to show the quotas for, default is the targeted org")
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers (and summary) of the output (handy for automated processing), default is false")
+	return parser
+}
+
+/** listQuotas - The main function to produce the response to show the org quota and the space quotas of the org, with their usage. */
+func listQuotas(cmdCtx *conf.Context, args []string) error {
+	if err := cmdCtx.ParseFlags(newQuotaFlagParser(&cmdCtx.Flags), args); err != nil {
+		return err
+	}
+	orgGuid, orgName := cmdCtx.CurrentOrg.Guid, cmdCtx.CurrentOrg.Name
+	if cmdCtx.Flags.Org != "" {
+		var err error
+		if orgGuid, err = cmdCtx.Resolver.GetOrgGuid(cmdCtx.Flags.Org); err != nil {
+			return conf.APIError(err, "failed to get org by name (%s)", cmdCtx.Flags.Org)
+		}
+		orgName = cmdCtx.Flags.Org
+	}
+	if orgGuid == "" {
+		return conf.NewError(conf.ExitNotTargeted, nil, "please target your org first, or use --org")
+	}
+	if cmdCtx.ShowInfo() {
+		fmt.Printf("Getting quotas for org %s as %s...\n\n", terminal.EntityNameColor(orgName), terminal.EntityNameColor(cmdCtx.CurrentUser))
+	}
+	org, err := cmdCtx.Resolver.GetOrg(orgGuid)
+	if err != nil {
+		return conf.APIError(err, "failed to get org %s", orgName)
+	}
+	spaces, err := cmdCtx.CfClient.Spaces.ListAll(cmdCtx.CfCtx, &client.SpaceListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: client.Filter{Values: []string{orgGuid}}})
+	if err != nil {
+		return conf.APIError(err, "failed to get spaces")
+	}
+	sort.Slice(spaces, func(i, j int) bool { return strings.ToLower(spaces[i].Name) < strings.ToLower(spaces[j].Name) })
+	usages, err := getQuotaUsages(cmdCtx, client.Filter{Values: []string{orgGuid}}, client.Filter{})
+	if err != nil {
+		return err
+	}
+	spaceQuotas := make(map[string]quotaLimits) // keyed by space quota guid
+	if quotas, err := cmdCtx.CfClient.SpaceQuotas.ListAll(cmdCtx.CfCtx, &client.SpaceQuotaListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: client.Filter{Values: []string{orgGuid}}}); err != nil {
+		return conf.APIError(err, "failed to get space quotas")
+	} else {
+		for _, quota := range quotas {
+			spaceQuotas[quota.GUID] = quotaLimits{name: quota.Name, apps: quota.Apps, services: quota.Services, routes: quota.Routes}
+		}
+	}
+
+	table := cmdCtx.NewTable(getQuotaColNames())
+	if cmdCtx.Flags.HideHeaders {
+		table.NoHeaders()
+	}
+	var orgUsage quotaUsage
+	for _, usage := range usages {
+		orgUsage.add(usage)
+	}
+	orgQuota := quotaLimits{name: "-"}
+	if org.Relationships.Quota.Data != nil {
+		if quota, err := cmdCtx.CfClient.OrganizationQuotas.Get(cmdCtx.CfCtx, org.Relationships.Quota.Data.GUID); err != nil {
+			cmdCtx.AddFailure(conf.APIError(err, "failed to get org quota"))
+			orgQuota = quotaLimits{name: terminal.FailureColor("?"), unknown: true}
+		} else {
+			orgQuota = quotaLimits{name: quota.Name, apps: quota.Apps, services: quota.Services, routes: quota.Routes}
+		}
+	}
+	nearLimit := addQuotaRow(cmdCtx, table, "<org>", orgQuota, orgUsage)
+	spacesNearLimit, spacesWithQuota := 0, 0
+	for _, space := range spaces {
+		quota := quotaLimits{name: "-"}
+		if space.Relationships != nil && space.Relationships.Quota != nil && space.Relationships.Quota.Data != nil {
+			quota = spaceQuotas[space.Relationships.Quota.Data.GUID]
+			spacesWithQuota++
+		}
+		if addQuotaRow(cmdCtx, table, space.Name, quota, usages[space.GUID]) {
+			spacesNearLimit++
+		}
+	}
+	_ = table.PrintTo(os.Stdout)
+	if cmdCtx.ShowInfo() {
+		summary := fmt.Sprintf("%d spaces (%d with a space quota)", len(spaces), spacesWithQuota)
+		if spacesNearLimit > 0 || nearLimit {
+			warning := fmt.Sprintf("%d spaces above %d%% of a quota limit", spacesNearLimit, cmdCtx.Settings.Thresholds.QuotaHigh)
+			if nearLimit {
+				warning = "the org and " + warning
+			}
+			fmt.Printf("\n  %s, %s\n", terminal.StoppedColor(summary), terminal.FailureColor(warning))
+		} else {
+			fmt.Printf("\n  %s\n", terminal.StoppedColor(summary))
+		}
+	}
+	return nil
+}
+
+/** getQuotaUsages - Get what counts against the quotas for the spaces in the given org or space filter, keyed by space guid. */
+func getQuotaUsages(cmdCtx *conf.Context, orgGuids, spaceGuids client.Filter) (map[string]quotaUsage, error) {
+	apps, err := cmdCtx.CfClient.Applications.ListAll(cmdCtx.CfCtx, &client.AppListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return nil, conf.APIError(err, "failed to get apps")
+	}
+	appData := make(map[string]*resource.App)
+	for _, app := range apps {
+		appData[app.GUID] = app
+	}
+	processes, err := cmdCtx.CfClient.Processes.ListAll(cmdCtx.CfCtx, &client.ProcessListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return nil, conf.APIError(err, "failed to get processes")
+	}
+	tasks, err := cmdCtx.CfClient.Tasks.ListAll(cmdCtx.CfCtx, &client.TaskListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids, States: client.Filter{Values: []string{"RUNNING"}}})
+	if err != nil {
+		return nil, conf.APIError(err, "failed to get tasks")
+	}
+	routes, err := cmdCtx.CfClient.Routes.ListAll(cmdCtx.CfCtx, &client.RouteListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return nil, conf.APIError(err, "failed to get routes")
+	}
+	serviceInstances, err := cmdCtx.CfClient.ServiceInstances.ListAll(cmdCtx.CfCtx, &client.ServiceInstanceListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return nil, conf.APIError(err, "failed to get service instances")
+	}
+	var serviceInstanceGuids []string
+	for _, serviceInstance := range serviceInstances {
+		serviceInstanceGuids = append(serviceInstanceGuids, serviceInstance.GUID)
+	}
+	serviceKeys, err := listCredentialBindings(cmdCtx, serviceInstanceGuids, func(opts *client.ServiceCredentialBindingListOptions, guids []string) {
+		opts.ServiceInstanceGUIDs = client.Filter{Values: guids}
+		opts.Type = client.Filter{Values: []string{"key"}}
+	})
+	if err != nil {
+		return nil, conf.APIError(err, "failed to get service keys")
+	}
+
+	usages := make(map[string]quotaUsage)
+	update := func(spaceGuid string, apply func(usage *quotaUsage)) {
+		usage := usages[spaceGuid]
+		apply(&usage)
+		usages[spaceGuid] = usage
+	}
+	for _, process := range processes {
+		app := appData[process.Relationships.App.Data.GUID]
+		if app == nil || app.State != "STARTED" || process.Instances == 0 {
+			continue
+		}
+		update(app.Relationships.Space.Data.GUID, func(usage *quotaUsage) {
+			usage.instances += process.Instances
+			usage.memory += process.MemoryInMB * process.Instances
+			usage.maxProcessMemory = max(usage.maxProcessMemory, process.MemoryInMB)
+			if process.LogRateLimitInBytesPerSecond > 0 { // -1 is unlimited
+				usage.logRate += process.LogRateLimitInBytesPerSecond * process.Instances
+			}
+		})
+	}
+	runningTasks := make(map[string]int) // keyed by app guid
+	for _, task := range tasks {
+		app := appData[task.Relationships.App.Data.GUID]
+		if app == nil {
+			continue
+		}
+		runningTasks[app.GUID]++
+		update(app.Relationships.Space.Data.GUID, func(usage *quotaUsage) {
+			usage.memory += task.MemoryInMB
+			usage.maxProcessMemory = max(usage.maxProcessMemory, task.MemoryInMB)
+			usage.maxAppTasks = max(usage.maxAppTasks, runningTasks[app.GUID])
+			if task.LogRateLimitInBytesPerSecond > 0 {
+				usage.logRate += task.LogRateLimitInBytesPerSecond
+			}
+		})
+	}
+	for _, route := range routes {
+		update(route.Relationships.Space.Data.GUID, func(usage *quotaUsage) {
+			usage.routes++
+			if route.Port != nil && *route.Port > 0 {
+				usage.reservedPorts++
+			}
+		})
+	}
+	serviceInstanceSpaces := make(map[string]string) // space guid, keyed by service instance guid
+	for _, serviceInstance := range serviceInstances {
+		if serviceInstance.Relationships.Space == nil || serviceInstance.Relationships.Space.Data == nil {
+			continue
+		}
+		serviceInstanceSpaces[serviceInstance.GUID] = serviceInstance.Relationships.Space.Data.GUID
+		update(serviceInstance.Relationships.Space.Data.GUID, func(usage *quotaUsage) { usage.serviceInstances++ })
+	}
+	for _, serviceKey := range serviceKeys {
+		if serviceKey.Relationships.ServiceInstance == nil || serviceKey.Relationships.ServiceInstance.Data == nil || serviceInstanceSpaces[serviceKey.Relationships.ServiceInstance.Data.GUID] == "" {
+			continue
+		}
+		update(serviceInstanceSpaces[serviceKey.Relationships.ServiceInstance.Data.GUID], func(usage *quotaUsage) { usage.serviceKeys++ })
+	}
+	return usages, nil
+}
+
+func (u *quotaUsage) add(other quotaUsage) {
+	u.memory += other.memory
+	u.maxProcessMemory = max(u.maxProcessMemory, other.maxProcessMemory)
+	u.instances += other.instances
+	u.maxAppTasks = max(u.maxAppTasks, other.maxAppTasks)
+	u.routes += other.routes
+	u.reservedPorts += other.reservedPorts
+	u.serviceInstances += other.serviceInstances
+	u.serviceKeys += other.serviceKeys
+	u.logRate += other.logRate
+}
+
+/** dimensions - The limits of the quota, with the usage that counts against them. */
+func (q quotaLimits) dimensions(usage quotaUsage) []quotaDimension {
+	formatMB := func(mb int) string { return getFormattedUnit(mb * 1024 * 1024) }
+	return []quotaDimension{
+		{quotaMemory, usage.memory, q.apps.TotalMemoryInMB, true, formatMB},
+		{"process memory", usage.maxProcessMemory, q.apps.PerProcessMemoryInMB, true, formatMB},
+		{"app instances", usage.instances, q.apps.TotalInstances, false, strconv.Itoa},
+		{"app tasks", usage.maxAppTasks, q.apps.PerAppTasks, false, strconv.Itoa},
+		{"routes", usage.routes, q.routes.TotalRoutes, false, strconv.Itoa},
+		{"reserved ports", usage.reservedPorts, q.routes.TotalReservedPorts, false, strconv.Itoa},
+		{"service instances", usage.serviceInstances, q.services.TotalServiceInstances, false, strconv.Itoa},
+		{"service keys", usage.serviceKeys, q.services.TotalServiceKeys, false, strconv.Itoa},
+		{quotaLogRate, usage.logRate, q.apps.LogRateLimitInBytesPerSecond, true, getFormattedUnit},
+	}
+}
+
+/** getQuotaColNames - The columns of the quota overview, one per quota dimension. */
+func getQuotaColNames() []string {
+	colNames := []string{"space", "quota"}
+	for _, dimension := range (quotaLimits{}).dimensions(quotaUsage{}) {
+		colNames = append(colNames, dimension.name)
+	}
+	return colNames
+}
+
+/** addQuotaRow - Add the row with the usage and limits of an org or space quota to the table, returns true if one of the limits is used above the quota_high threshold. */
+func addQuotaRow(cmdCtx *conf.Context, table *conf.Table, name string, quota quotaLimits, usage quotaUsage) bool {
+	nearLimit := false
+	cells := []string{name, quota.name}
+	for _, dimension := range quota.dimensions(usage) {
+		switch {
+		case quota.unknown:
+			cells = append(cells, dimension.format(dimension.used))
+		case dimension.unlimited():
+			cells = append(cells, fmt.Sprintf("%s / %s", dimension.format(dimension.used), unlimited))
+		default:
+			nearLimit = nearLimit || dimension.percentage() > cmdCtx.Settings.Thresholds.QuotaHigh
+			cells = append(cells, fmt.Sprintf("%s / %s (%s%%)", dimension.format(dimension.used), dimension.format(*dimension.limit), colorQuotaPercentage(cmdCtx, dimension.percentage(), 0)))
+		}
+	}
+	table.Add(cells...)
+	return nearLimit
+}
+
+/** unlimited - Return true if there is no limit, the CF API has null (and for the log rate also -1) for unlimited. */
+func (d quotaDimension) unlimited() bool {
+	return d.limit == nil || *d.limit < 0
+}
+
+/** percentage - The percentage of the limit that is used, 0 if unlimited. */
+func (d quotaDimension) percentage() int {
+	if d.unlimited() {
+		return 0
+	}
+	return getPercentage(d.used, *d.limit)
+}
+
+/** formatLimit - The formatted limit, or ∞ if unlimited. */
+func (d quotaDimension) formatLimit() string {
+	if d.unlimited() {
+		return unlimited
+	}
+	return d.format(*d.limit)
+}
+
+/** formatHeadroom - What is left before the limit is reached (right aligned in the given width), red if nothing is left, ∞ if unlimited. */
+func (d quotaDimension) formatHeadroom(width int) string {
+	if d.unlimited() {
+		return fmt.Sprintf("%*s", width, unlimited)
+	}
+	if headroom := *d.limit - d.used; headroom > 0 {
+		return fmt.Sprintf("%*s", width, d.format(headroom))
+	}
+	return terminal.FailureColor(fmt.Sprintf("%*s", width, d.format(0)))
+}
+
+/** getPercentage - The percentage of the limit that is used, for a limit of 0 it is 100 if anything is used (and 0 otherwise). */
+func getPercentage(used, limit int) int {
+	if limit <= 0 {
+		if used > 0 {
+			return 100
+		}
+		return 0
+	}
+	return 100 * used / limit
+}
+
+/** colorQuotaPercentage - Format the percentage right aligned in the given width, red if it is above the quota_high threshold, otherwise green. */
+func colorQuotaPercentage(cmdCtx *conf.Context, percentage, width int) string {
+	text := fmt.Sprintf("%*d", width, percentage)
+	if percentage > cmdCtx.Settings.Thresholds.QuotaHigh {
+		return terminal.FailureColor(text)
+	}
+	return terminal.SuccessColor(text)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"testing"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+)
+
+func TestGetQuotaUsages(t *testing.T) {
+	otherSpaceApp := newTestApp("app-3", "app3", "STARTED", nil)
+	otherSpaceApp.Relationships.Space.Data.GUID = "space-2"
+	unlimitedLogRate := newTestProcess("proc-3", "app-3", "web", 1, 128)
+	unlimitedLogRate.LogRateLimitInBytesPerSecond = -1
+	serviceInstance := func(guid, spaceGuid string) *resource.ServiceInstance {
+		instance := &resource.ServiceInstance{Resource: resource.Resource{GUID: guid}}
+		if spaceGuid != "" {
+			instance.Relationships.Space = &resource.ToOneRelationship{Data: &resource.Relationship{GUID: spaceGuid}}
+		}
+		return instance
+	}
+	task := func(guid, appGuid, state string, memoryMB int) *resource.Task {
+		task := &resource.Task{State: state, MemoryInMB: memoryMB, LogRateLimitInBytesPerSecond: 1024, Resource: resource.Resource{GUID: guid}}
+		task.Relationships.App.Data = &resource.Relationship{GUID: appGuid}
+		return task
+	}
+	serviceKey := func(guid, serviceInstanceGuid string) *resource.ServiceCredentialBinding {
+		return &resource.ServiceCredentialBinding{Type: "key", Relationships: resource.ServiceCredentialBindingRelationships{ServiceInstance: &resource.ToOneRelationship{Data: &resource.Relationship{GUID: serviceInstanceGuid}}}, Resource: resource.Resource{GUID: guid}}
+	}
+	port := 1024
+	f := newTestSpaceFake(0)
+	f.Spaces = append(f.Spaces, newTestSpace("space-2", "space2", "org-1"))
+	f.Apps = []*resource.App{newTestApp("app-1", "app1", "STARTED", nil), newTestApp("app-2", "app2", "STOPPED", nil), otherSpaceApp}
+	f.Processes = []*resource.Process{newTestProcess("proc-1", "app-1", "web", 2, 512), newTestProcess("proc-1w", "app-1", "worker", 1, 256), newTestProcess("proc-2", "app-2", "web", 4, 1024), unlimitedLogRate}
+	f.Tasks = []*resource.Task{task("task-1", "app-1", "RUNNING", 2048), task("task-2", "app-1", "RUNNING", 128), task("task-3", "app-3", "RUNNING", 64), task("task-4", "app-3", "SUCCEEDED", 4096)}
+	f.Routes = []*resource.Route{newTestRoute("route-1", "app1", "app1.example.com", testSpaceGuid, "domain-1", nil), newTestRoute("route-2", "app3", "app3.example.com", "space-2", "domain-1", nil), newTestRoute("route-3", "", "tcp.example.com:1024", "space-2", "domain-tcp", &port)}
+	f.ServiceInstances = []*resource.ServiceInstance{serviceInstance("si-1", testSpaceGuid), serviceInstance("si-2", testSpaceGuid), serviceInstance("si-3", "")}
+	f.ServiceCredentialBindings = []*resource.ServiceCredentialBinding{serviceKey("key-1", "si-1"), serviceKey("key-2", "si-1"), serviceKey("key-3", "si-3")}
+
+	usages, err := getQuotaUsages(newTestContext(t, f), client.Filter{Values: []string{"org-1"}}, client.Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[string]quotaUsage{
+		testSpaceGuid: {memory: 1280 + 2048 + 128, maxProcessMemory: 2048, instances: 3, maxAppTasks: 2, routes: 1, serviceInstances: 2, serviceKeys: 2, logRate: 3*16*1024 + 2*1024},
+		"space-2":     {memory: 128 + 64, maxProcessMemory: 128, instances: 1, maxAppTasks: 1, routes: 2, reservedPorts: 1, logRate: 1024},
+	}
+	if len(usages) != len(want) {
+		t.Fatalf("got usages for %d spaces, want %d: %+v", len(usages), len(want), usages)
+	}
+	for spaceGuid, wantUsage := range want {
+		if usages[spaceGuid] != wantUsage {
+			t.Errorf("%s: got %+v, want %+v", spaceGuid, usages[spaceGuid], wantUsage)
+		}
+	}
+}
+
+func TestQuotaDimensions(t *testing.T) {
+	limit := func(value int) *int { return &value }
+	quota := quotaLimits{
+		name:     "small",
+		apps:     resource.AppsQuota{TotalMemoryInMB: limit(10240), PerProcessMemoryInMB: limit(1024), TotalInstances: limit(10), PerAppTasks: nil, LogRateLimitInBytesPerSecond: limit(-1)},
+		services: resource.ServicesQuota{TotalServiceInstances: limit(0), TotalServiceKeys: limit(5)},
+		routes:   resource.RoutesQuota{TotalRoutes: limit(20), TotalReservedPorts: limit(0)},
+	}
+	usage := quotaUsage{memory: 5120, maxProcessMemory: 1024, instances: 12, maxAppTasks: 3, routes: 5, reservedPorts: 0, serviceInstances: 1, serviceKeys: 2, logRate: 2048}
+	tests := []struct {
+		name       string
+		used       string
+		limit      string
+		percentage int
+		headroom   string
+		allocated  bool
+	}{
+		{name: quotaMemory, used: "5120M", limit: "10G", percentage: 50, headroom: "5120M", allocated: true},
+		{name: "process memory", used: "1024M", limit: "1024M", percentage: 100, headroom: "0", allocated: true},
+		{name: "app instances", used: "12", limit: "10", percentage: 120, headroom: "0"},
+		{name: "app tasks", used: "3", limit: unlimited, percentage: 0, headroom: unlimited},
+		{name: "routes", used: "5", limit: "20", percentage: 25, headroom: "15"},
+		{name: "reserved ports", used: "0", limit: "0", percentage: 0, headroom: "0"},
+		{name: "service instances", used: "1", limit: "0", percentage: 100, headroom: "0"},
+		{name: "service keys", used: "2", limit: "5", percentage: 40, headroom: "3"},
+		{name: quotaLogRate, used: "2048", limit: unlimited, percentage: 0, headroom: unlimited, allocated: true},
+	}
+	dimensions := quota.dimensions(usage)
+	if len(dimensions) != len(tests) {
+		t.Fatalf("got %d dimensions, want %d", len(dimensions), len(tests))
+	}
+	for ix, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dimension := dimensions[ix]
+			if dimension.name != tt.name || dimension.allocated != tt.allocated {
+				t.Fatalf("got dimension %s (allocated %t), want %s (allocated %t)", dimension.name, dimension.allocated, tt.name, tt.allocated)
+			}
+			if used := dimension.format(dimension.used); used != tt.used {
+				t.Errorf("got used %s, want %s", used, tt.used)
+			}
+			if formatted := dimension.formatLimit(); formatted != tt.limit {
+				t.Errorf("got limit %s, want %s", formatted, tt.limit)
+			}
+			if percentage := dimension.percentage(); percentage != tt.percentage {
+				t.Errorf("got percentage %d, want %d", percentage, tt.percentage)
+			}
+			if headroom := terminal.Decolorize(dimension.formatHeadroom(0)); headroom != tt.headroom {
+				t.Errorf("got headroom %s, want %s", headroom, tt.headroom)
+			}
+		})
+	}
+}
+
+func TestGetPercentage(t *testing.T) {
+	tests := []struct {
+		name  string
+		used  int
+		limit int
+		want  int
+	}{
+		{name: "half", used: 50, limit: 100, want: 50},
+		{name: "rounded down", used: 2, limit: 3, want: 66},
+		{name: "over the limit", used: 150, limit: 100, want: 150},
+		{name: "nothing used", used: 0, limit: 100, want: 0},
+		{name: "zero limit, nothing used", used: 0, limit: 0, want: 0},
+		{name: "zero limit, used", used: 1, limit: 0, want: 100},
+		{name: "negative limit", used: 1, limit: -1, want: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPercentage(tt.used, tt.limit); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
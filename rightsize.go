@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+const (
+	memoryStepMB = 64  // recommended memory limits are rounded up to this
+	diskStepMB   = 256 // recommended disk limits are rounded up to this
+)
+
+var rightsizeColNames = []string{"app", "type", "#inst", "memory", "peak memory", "rec. memory", "disk", "peak disk", "rec. disk", "memory saving"}
+
+// rightsizeCommand holds the state of one "cf rightsize" invocation, it uses the process stats of "cf aa" and keeps the peak usage of all samples.
+type rightsizeCommand struct {
+	*appsCommand
+	peaks map[string]*usagePeak // keyed by process guid
+}
+
+// usagePeak holds the highest memory and disk usage (in bytes) of all instances of a process over all samples.
+type usagePeak struct {
+	memory int
+	disk   int
+}
+
+/** newRightsizeFlagParser - Create the flag parser for "cf rightsize", also used to generate the shell completion. */
+func newRightsizeFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("rightsize", flags)
+	parser.String(&flags.AppName, "a", "appname", "Filter the output by the given appname (regular expression)")
+	parser.Int(&flags.Samples, "s", "samples", "The number of times the usage of all instances is sampled, default is 5")
+	parser.Duration(&flags.Window, "w", "window", "The time to spread the samples over (like 30s or 10m), default is 1m")
+	parser.Int(&flags.Margin, "m", "margin", "The percentage to add to the peak usage for the recommended limits, default is 25")
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers (and summary) of the output (handy for automated processing), default is false")
+	return parser
+}
+
+/** listRightsize - The main function to produce the response to recommend memory and disk limits, based on the usage sampled over a time window. */
+func listRightsize(cmdCtx *conf.Context, args []string) error {
+	r := &rightsizeCommand{appsCommand: &appsCommand{Context: cmdCtx, appData: make(map[string]*resource.App), processStats: make(map[string]*resource.ProcessStats)}, peaks: make(map[string]*usagePeak)}
+	r.Flags.Samples, r.Flags.Window, r.Flags.Margin = 5, time.Minute, 25
+	if err := r.ParseFlags(newRightsizeFlagParser(&r.Flags), args); err != nil {
+		return err
+	}
+	if r.Flags.Samples < 1 || r.Flags.Window < 0 || r.Flags.Margin < 0 {
+		return conf.UsageError("invalid --samples %d, --window %s or --margin %d, they should be positive", r.Flags.Samples, r.Flags.Window, r.Flags.Margin)
+	}
+	var err error
+	if r.appNameRegex, err = regexp.Compile(r.Flags.AppName); err != nil {
+		return conf.UsageError("invalid appname filter %s: %s", r.Flags.AppName, err)
+	}
+	if r.ShowInfo() {
+		fmt.Printf("Sampling the usage of the apps in org %s / space %s %d times over %s as %s...\n\n", terminal.EntityNameColor(r.CurrentOrg.Name), terminal.EntityNameColor(r.CurrentSpace.Name), r.Flags.Samples, r.Flags.Window, terminal.EntityNameColor(r.CurrentUser))
+	}
+	apps, err := r.CfClient.Applications.ListAll(r.CfCtx, &client.AppListOptions{ListOptions: &client.ListOptions{}, SpaceGUIDs: client.Filter{Values: []string{r.CurrentSpace.Guid}}})
+	if err != nil {
+		return conf.APIError(err, "failed to get apps")
+	}
+	for _, app := range apps {
+		if app.State == "STARTED" && r.appNameRegex.MatchString(app.Name) {
+			r.appData[app.GUID] = app
+		}
+	}
+	processes, err := r.CfClient.Processes.ListAll(r.CfCtx, &client.ProcessListOptions{ListOptions: &client.ListOptions{}, SpaceGUIDs: client.Filter{Values: []string{r.CurrentSpace.Guid}}})
+	if err != nil {
+		return conf.APIError(err, "failed to get processes")
+	}
+	// only the running processes of the started apps have usage
+	for _, process := range processes {
+		if r.appData[process.Relationships.App.Data.GUID] != nil && process.Instances > 0 {
+			r.processes = append(r.processes, process)
+		}
+	}
+	if len(r.processes) == 0 {
+		r.Notice("no started apps found")
+		return nil
+	}
+	sort.Slice(r.processes, func(i, j int) bool {
+		iName, jName := strings.ToLower(r.appData[r.processes[i].Relationships.App.Data.GUID].Name), strings.ToLower(r.appData[r.processes[j].Relationships.App.Data.GUID].Name)
+		if iName != jName {
+			return iName < jName
+		}
+		return r.processes[i].Type < r.processes[j].Type
+	})
+
+	for sample := 1; sample <= r.Flags.Samples; sample++ {
+		if sample > 1 {
+			select {
+			case <-r.CfCtx.Done():
+				return nil // cancelled, Run reports why
+			case <-time.After(r.Flags.Window / time.Duration(max(r.Flags.Samples-1, 1))):
+			}
+		}
+		if r.ShowInfo() {
+			fmt.Printf("sample %d/%d\n", sample, r.Flags.Samples)
+		}
+		r.processStats = make(map[string]*resource.ProcessStats)
+		r.getProcessStats()
+		if r.CfCtx.Err() != nil {
+			return nil // cancelled, Run reports why
+		}
+		r.addSample()
+	}
+	if r.ShowInfo() {
+		fmt.Println()
+	}
+	r.printRecommendations()
+	return nil
+}
+
+/** addSample - Keep the highest memory and disk usage of the running instances from the latest process stats. */
+func (r *rightsizeCommand) addSample() {
+	for _, process := range r.processes {
+		stats := r.processStats[process.GUID]
+		if stats == nil {
+			continue // we failed to get the stats, already reported
+		}
+		peak := r.peaks[process.GUID]
+		if peak == nil {
+			peak = &usagePeak{}
+			r.peaks[process.GUID] = peak
+		}
+		for _, stat := range stats.Stats {
+			if stat.State == "RUNNING" {
+				peak.memory = max(peak.memory, stat.Usage.Memory)
+				peak.disk = max(peak.disk, stat.Usage.Disk)
+			}
+		}
+	}
+}
+
+/** printRecommendations - Print the current limits, the peak usage and the recommended limits per process, with the projected savings. */
+func (r *rightsizeCommand) printRecommendations() {
+	table := r.NewTable(rightsizeColNames)
+	if r.Flags.HideHeaders {
+		table.NoHeaders()
+	}
+	var memory, recommendedMemory, disk, recommendedDisk int // MB, for all instances
+	for _, process := range r.processes {
+		app := r.appData[process.Relationships.App.Data.GUID]
+		peak := r.peaks[process.GUID]
+		if peak == nil || peak.memory == 0 {
+			// no stats, or no running instances in any of the samples
+			unknown := terminal.FailureColor("?")
+			table.Add(app.Name, process.Type, fmt.Sprintf("%5d", process.Instances), fmt.Sprintf("%6s", getFormattedUnit(process.MemoryInMB*1024*1024)), unknown, unknown, fmt.Sprintf("%6s", getFormattedUnit(process.DiskInMB*1024*1024)), unknown, unknown, unknown)
+			continue
+		}
+		recMemory := r.recommendLimit(peak.memory, memoryStepMB)
+		recDisk := r.recommendLimit(peak.disk, diskStepMB)
+		memory += process.MemoryInMB * process.Instances
+		recommendedMemory += recMemory * process.Instances
+		disk += process.DiskInMB * process.Instances
+		recommendedDisk += recDisk * process.Instances
+		table.Add(app.Name, process.Type, fmt.Sprintf("%5d", process.Instances),
+			fmt.Sprintf("%6s", getFormattedUnit(process.MemoryInMB*1024*1024)), r.formatPeak(peak.memory, process.MemoryInMB), formatRecommendation(recMemory, process.MemoryInMB),
+			fmt.Sprintf("%6s", getFormattedUnit(process.DiskInMB*1024*1024)), r.formatPeak(peak.disk, process.DiskInMB), formatRecommendation(recDisk, process.DiskInMB),
+			fmt.Sprintf("%6s", formatSaving((process.MemoryInMB-recMemory)*process.Instances)))
+	}
+	_ = table.PrintTo(os.Stdout)
+	if r.ShowInfo() {
+		fmt.Printf("\n  %s\n", terminal.StoppedColor(fmt.Sprintf("%d processes, Memory: allocated:%s, recommended:%s, saving:%s (%d%%), Disk: allocated:%s, recommended:%s, saving:%s (%d%%)",
+			len(r.processes), getFormattedUnit(memory*1024*1024), getFormattedUnit(recommendedMemory*1024*1024), formatSaving(memory-recommendedMemory), getPercentage(memory-recommendedMemory, memory),
+			getFormattedUnit(disk*1024*1024), getFormattedUnit(recommendedDisk*1024*1024), formatSaving(disk-recommendedDisk), getPercentage(disk-recommendedDisk, disk))))
+		// a failed stats call is only reported once per process, tell in how many samples it failed
+		for _, process := range r.processes {
+			if failures := r.statsFailures[process.GUID]; failures > 0 {
+				fmt.Printf("  %s\n", terminal.FailureColor(fmt.Sprintf("%s (%s): the stats failed in %d of %d samples", r.appData[process.Relationships.App.Data.GUID].Name, process.Type, failures, r.Flags.Samples)))
+			}
+		}
+	}
+}
+
+/** recommendLimit - The recommended limit in MB for the given peak usage (in bytes): the peak plus the --margin percentage, rounded up to the given step. */
+func (r *rightsizeCommand) recommendLimit(peak, stepMB int) int {
+	withMargin := peak / 1024 / 1024 * (100 + r.Flags.Margin) / 100
+	return max((withMargin+stepMB-1)/stepMB, 1) * stepMB
+}
+
+/** formatPeak - The peak usage with the percentage of the limit, colored like the MemUsed column (yellow is over-allocated, red is almost out of it). */
+func (r *rightsizeCommand) formatPeak(peak, limitMB int) string {
+	percentage := getPercentage(peak/1024/1024, limitMB)
+	percentageColored := terminal.SuccessColor(fmt.Sprintf("%2d", percentage))
+	if percentage < r.Settings.Thresholds.UsageLow {
+		percentageColored = terminal.AdvisoryColor(fmt.Sprintf("%2d", percentage))
+	}
+	if percentage > r.Settings.Thresholds.UsageHigh {
+		percentageColored = terminal.FailureColor(fmt.Sprintf("%2d", percentage))
+	}
+	return fmt.Sprintf("%4s (%s%%)", getFormattedUnit(peak), percentageColored)
+}
+
+/** formatRecommendation - The recommended limit, green if it is lower than the current limit, red if it is higher (the app may run out of it), "-" if it is the same. */
+func formatRecommendation(recommendedMB, currentMB int) string {
+	text := fmt.Sprintf("%6s", getFormattedUnit(recommendedMB*1024*1024))
+	switch {
+	case recommendedMB < currentMB:
+		return terminal.SuccessColor(text)
+	case recommendedMB > currentMB:
+		return terminal.FailureColor(text)
+	}
+	return fmt.Sprintf("%6s", "-")
+}
+
+/** formatSaving - The (memory or disk) saving in MB, formatted like the other units, negative if more is needed. */
+func formatSaving(savingMB int) string {
+	if savingMB < 0 {
+		return "-" + getFormattedUnit(-savingMB*1024*1024)
+	}
+	return getFormattedUnit(savingMB * 1024 * 1024)
+}
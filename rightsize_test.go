@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/metskem/panzer-plugin/cfapi/fake"
+)
+
+func TestRecommendLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		peak   int // bytes
+		margin int
+		stepMB int
+		want   int // MB
+	}{
+		{name: "peak is truncated to MB", peak: 100*1024*1024 + 1023*1024, margin: 0, stepMB: 1, want: 100},
+		{name: "margin is added", peak: 100 * 1024 * 1024, margin: 25, stepMB: 1, want: 125},
+		{name: "margin on the truncated peak", peak: 10*1024*1024 + 900*1024, margin: 50, stepMB: 1, want: 15},
+		{name: "rounded up to the memory step", peak: 100 * 1024 * 1024, margin: 25, stepMB: memoryStepMB, want: 128},
+		{name: "a multiple of the step is kept", peak: 128 * 1024 * 1024, margin: 0, stepMB: memoryStepMB, want: 128},
+		{name: "rounded up to the disk step", peak: 300 * 1024 * 1024, margin: 0, stepMB: diskStepMB, want: 512},
+		{name: "at least one step", peak: 1024, margin: 25, stepMB: memoryStepMB, want: 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &rightsizeCommand{appsCommand: newTestAppsCommand(t, &fake.Fake{}, "")}
+			r.Flags.Margin = tt.margin
+			if got := r.recommendLimit(tt.peak, tt.stepMB); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSaving(t *testing.T) {
+	tests := []struct {
+		savingMB int
+		want     string
+	}{
+		{savingMB: 0, want: "0"},
+		{savingMB: 5, want: "5120K"},
+		{savingMB: 512, want: "512M"},
+		{savingMB: 20 * 1024, want: "20G"},
+		{savingMB: -512, want: "-512M"},
+	}
+	for _, tt := range tests {
+		if got := formatSaving(tt.savingMB); got != tt.want {
+			t.Errorf("formatSaving(%d): got %s, want %s", tt.savingMB, got, tt.want)
+		}
+	}
+}
+
+func TestAddSample(t *testing.T) {
+	stats := func(instances ...resource.ProcessStat) *resource.ProcessStats {
+		return &resource.ProcessStats{Stats: instances}
+	}
+	stat := func(state string, memoryMB, diskMB int) resource.ProcessStat {
+		return resource.ProcessStat{State: state, Usage: resource.Usage{Memory: memoryMB * 1024 * 1024, Disk: diskMB * 1024 * 1024}}
+	}
+	f := &fake.Fake{
+		Apps:      []*resource.App{newTestApp("app-1", "app1", "STARTED", nil), newTestApp("app-2", "app2", "STARTED", nil)},
+		Processes: []*resource.Process{newTestProcess("proc-1", "app-1", "web", 2, 512), newTestProcess("proc-2", "app-2", "web", 1, 512)},
+		ProcessStats: map[string]*resource.ProcessStats{
+			"proc-1": stats(stat("RUNNING", 100, 300), stat("RUNNING", 150, 200)),
+			"proc-2": stats(stat("STARTING", 50, 50)),
+		},
+	}
+	r := &rightsizeCommand{appsCommand: newTestAppsCommand(t, f, ""), peaks: make(map[string]*usagePeak)}
+	r.addSample()
+	samples := []struct {
+		stats map[string]*resource.ProcessStats
+		err   error
+	}{
+		{stats: map[string]*resource.ProcessStats{
+			"proc-1": stats(stat("RUNNING", 180, 100), stat("CRASHED", 400, 400)),
+			"proc-2": stats(stat("RUNNING", 200, 100)),
+		}},
+		{err: errors.New("stats unavailable")},
+		{stats: map[string]*resource.ProcessStats{
+			"proc-1": stats(stat("RUNNING", 120, 250), stat("RUNNING", 130, 350)),
+			"proc-2": stats(stat("RUNNING", 190, 90)),
+		}},
+	}
+	for _, sample := range samples {
+		f.ProcessStats, f.Errors = sample.stats, nil
+		if sample.err != nil {
+			f.Errors = map[string]error{"Processes.GetStats": sample.err}
+		}
+		r.processStats = make(map[string]*resource.ProcessStats)
+		r.getProcessStats()
+		r.addSample()
+	}
+
+	// only running instances count, and a failed sample keeps the peaks of the other samples
+	want := map[string]usagePeak{
+		"proc-1": {memory: 180 * 1024 * 1024, disk: 350 * 1024 * 1024},
+		"proc-2": {memory: 200 * 1024 * 1024, disk: 100 * 1024 * 1024},
+	}
+	for processGuid, wantPeak := range want {
+		if peak := r.peaks[processGuid]; peak == nil || *peak != wantPeak {
+			t.Errorf("%s: got peak %+v, want %+v", processGuid, peak, wantPeak)
+		}
+		if failures := r.statsFailures[processGuid]; failures != 1 {
+			t.Errorf("%s: got %d failed samples, want 1", processGuid, failures)
+		}
+	}
+	if err := r.PartialError(); err == nil {
+		t.Error("expected the failed sample to be reported")
+	}
+}
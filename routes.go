@@ -1,70 +1,273 @@
 package main
 
 import (
+	"bufio"
 	"code.cloudfoundry.org/cli/cf/terminal"
 	"code.cloudfoundry.org/cli/plugin"
+	"context"
 	"fmt"
 	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/integrii/flaggy"
 	"github.com/metskem/panzer-plugin/conf"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 )
 
-var colNames = []string{"hostname", "domain", "org", "space", "bound apps"}
+var (
+	colNames    = []string{"hostname", "domain", "org", "space", "bound apps"}
+	tcpColNames = []string{"port", "domain", "router group", "org", "space", "bound apps"}
+)
 
 /** listRoutes - The main function to produce the response to list routes. */
-func listRoutes(cliConnection plugin.CliConnection) {
-	flaggy.DefaultParser.ShowHelpOnUnexpected = false
-	flaggy.DefaultParser.ShowVersionWithVersionFlag = false
-	flaggy.Bool(&conf.FlagSwitchToSpace, "t", "target", "cf target the space where the route is found")
-	flaggy.String(&conf.FlagRoute, "r", "route", "the route to lookup (specify only hostname, without the domain name)")
-	flaggy.Parse()
+func listRoutes(cmdCtx *conf.Context, cliConnection plugin.CliConnection, args []string) error {
+	flags := &cmdCtx.Flags
+	if err := cmdCtx.ParseFlags(newRoutesFlagParser(flags), args); err != nil {
+		return err
+	}
 
-	if conf.FlagRoute == "" {
-		fmt.Println("Please use the -r flag to specify the route name")
-		os.Exit(1)
+	if flags.Route == "" && flags.Port == 0 {
+		return conf.UsageError("Please use the -r flag to specify the route name, or the --port flag to specify the TCP port")
+	}
+	if flags.Route != "" && flags.Port != 0 {
+		return conf.UsageError("Please use either the -r or the --port flag, not both")
+	}
+	if flags.Pick != 0 && !flags.SwitchToSpace {
+		return conf.UsageError("Please use the -p flag together with the -t flag")
 	}
 
-	fmt.Printf("Getting routes for hostname %s as %s...\n\n", terminal.EntityNameColor(conf.FlagRoute), terminal.EntityNameColor(conf.CurrentUser))
-	routeListOptions := client.RouteListOptions{ListOptions: &client.ListOptions{}, Hosts: client.Filter{Values: []string{conf.FlagRoute}}}
-	if routes, err := conf.CfClient.Routes.ListAll(conf.CfCtx, &routeListOptions); err != nil {
-		fmt.Println(terminal.FailureColor(fmt.Sprintf("failed to get routes: %s", err)))
+	routeListOptions := client.RouteListOptions{ListOptions: &client.ListOptions{}}
+	tableColNames := colNames
+	if flags.Port != 0 {
+		if cmdCtx.ShowInfo() {
+			fmt.Printf("Getting TCP routes for port %s as %s...\n\n", terminal.EntityNameColor(strconv.Itoa(flags.Port)), terminal.EntityNameColor(cmdCtx.CurrentUser))
+		}
+		routeListOptions.Ports = client.Filter{Values: []string{strconv.Itoa(flags.Port)}}
+		tableColNames = tcpColNames
+		if flags.Probe {
+			cmdCtx.Notice("Probing is only supported for http routes, ignoring --probe")
+			flags.Probe = false
+		}
 	} else {
-		if len(routes) == 0 {
-			fmt.Printf("no routes found for hostname %s\n", conf.FlagRoute)
+		if cmdCtx.ShowInfo() {
+			fmt.Printf("Getting routes for hostname %s as %s...\n\n", terminal.EntityNameColor(flags.Route), terminal.EntityNameColor(cmdCtx.CurrentUser))
+		}
+		routeListOptions.Hosts = client.Filter{Values: []string{flags.Route}}
+	}
+	if flags.Probe {
+		tableColNames = append(append([]string{}, tableColNames...), probeColNames...)
+	}
+	if flags.SwitchToSpace {
+		// number the org/spaces we can target, for --pick
+		tableColNames = append([]string{"#"}, tableColNames...)
+	}
+	routes, err := cmdCtx.CfClient.Routes.ListAll(cmdCtx.CfCtx, &routeListOptions)
+	if err != nil {
+		return conf.APIError(err, "failed to get routes")
+	}
+	if len(routes) == 0 {
+		if flags.Port != 0 {
+			return conf.NewError(conf.ExitNotFound, nil, "no TCP routes found for port %d", flags.Port)
+		}
+		return conf.NewError(conf.ExitNotFound, nil, "no routes found for hostname %s", flags.Route)
+	}
+	var routerGroupNames map[string]string
+	if flags.Port != 0 {
+		routerGroupNames = getRouterGroupNames(cmdCtx)
+	}
+	table := cmdCtx.NewTable(tableColNames)
+	var targets []routeTarget
+	var rows [][]string
+	internal := make([]bool, len(routes)) // routes on an internal domain are probed with http
+	for ix, route := range routes {
+		if cmdCtx.CfCtx.Err() != nil {
+			return nil // cancelled, Run reports why
+		}
+		var colValues []string
+		domainName, routerGroup := "?", "-"
+		if domain, err := cmdCtx.Resolver.GetDomain(route.Relationships.Domain.Data.GUID); err != nil {
+			cmdCtx.AddFailure(conf.APIError(err, "failed to get domain for route %s", route.URL))
 		} else {
-			table := terminal.NewTable(colNames)
-			var orgName, spaceName string
-			for _, route := range routes {
-				var colValues [5]string
-				colValues[0] = conf.FlagRoute
-				domain, _ := conf.CfClient.Domains.Get(conf.CfCtx, route.Relationships.Domain.Data.GUID)
-				colValues[1] = domain.Name
-				space, _ := conf.CfClient.Spaces.Get(conf.CfCtx, route.Relationships.Space.Data.GUID)
-				org, _ := conf.CfClient.Organizations.Get(conf.CfCtx, space.Relationships.Organization.Data.GUID)
-				colValues[2] = org.Name
-				colValues[3] = space.Name
-				table.Add(colValues[:]...)
-				orgName = colValues[2]
-				spaceName = colValues[3]
-				var destList string
-				for _, dest := range route.Destinations {
-					app, _ := conf.CfClient.Applications.Get(conf.CfCtx, *dest.App.GUID)
-					destList = fmt.Sprintf("%s%s ", destList, app.Name)
-				}
-				colValues[4] = destList
+			domainName, internal[ix] = domain.Name, domain.Internal
+			routerGroup = getRouterGroupName(routerGroupNames, domain.RouterGroup)
+		}
+		if flags.Port != 0 {
+			colValues = append(colValues, strconv.Itoa(*route.Port), domainName, routerGroup)
+		} else {
+			colValues = append(colValues, flags.Route, domainName)
+		}
+		orgName, spaceName, targetNr := "?", "?", "-"
+		if space, err := cmdCtx.Resolver.GetSpace(route.Relationships.Space.Data.GUID); err != nil {
+			cmdCtx.AddFailure(conf.APIError(err, "failed to get space for route %s", route.URL))
+		} else {
+			spaceName = space.Name
+			if org, err := cmdCtx.Resolver.GetOrg(space.Relationships.Organization.Data.GUID); err != nil {
+				cmdCtx.AddFailure(conf.APIError(err, "failed to get org for route %s", route.URL))
+			} else {
+				orgName = org.Name
+				var nr int
+				targets, nr = addTarget(targets, routeTarget{orgName: org.Name, spaceName: space.Name})
+				targetNr = strconv.Itoa(nr)
+			}
+		}
+		colValues = append(colValues, orgName, spaceName)
+		var destList string
+		for _, dest := range route.Destinations {
+			if app, err := cmdCtx.CfClient.Applications.Get(cmdCtx.CfCtx, *dest.App.GUID); err != nil {
+				cmdCtx.AddFailure(conf.APIError(err, "failed to get app %s for route %s", *dest.App.GUID, route.URL))
+			} else {
+				destList = fmt.Sprintf("%s%s ", destList, app.Name)
+			}
+		}
+		colValues = append(colValues, destList)
+		if flags.SwitchToSpace {
+			colValues = append([]string{targetNr}, colValues...)
+		}
+		rows = append(rows, colValues)
+	}
+	if flags.Probe {
+		results := make([]probeResult, len(routes))
+		forEachThrottled(cmdCtx.CfCtx, len(routes), func(ix int) {
+			results[ix] = probeRoute(cmdCtx.CfCtx, nil, routes[ix].URL, internal[ix])
+		})
+		if cmdCtx.CfCtx.Err() != nil {
+			return nil // cancelled, Run reports why
+		}
+		for ix := range rows {
+			rows[ix] = append(rows[ix], getProbeColValues(results[ix])...)
+		}
+	}
+	for _, row := range rows {
+		table.Add(row...)
+	}
+	_ = table.PrintTo(os.Stdout)
+	if flags.SwitchToSpace && len(targets) > 0 {
+		target, err := pickTarget(cmdCtx.CfCtx, targets, flags.Pick)
+		if err != nil {
+			return err
+		}
+		orgName, spaceName := target.orgName, target.spaceName
+		if _, err = cliConnection.CliCommandWithoutTerminalOutput("target", "-o", orgName, "-s", spaceName); err != nil {
+			// You normally would use cliConnection.CliCommand, but that screws up my "NetworkPolicyV1Endpoint" in my cf config.json. So instead issue os command:
+			cmd := exec.Command("cf", "target", "-o", orgName, "-s", spaceName)
+			if err = cmd.Run(); err != nil {
+				return conf.NewError(conf.ExitFailure, err, "failed to set target to org %s and space %s", orgName, spaceName)
+			}
+		}
+	}
+	return nil
+}
+
+/** newRoutesFlagParser - Create the flag parser for "cf lr", also used to generate the shell completion. */
+func newRoutesFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("lr", flags)
+	parser.Bool(&flags.SwitchToSpace, "t", "target", "cf target the space where the route is found")
+	parser.String(&flags.Route, "r", "route", "the route to lookup (specify only hostname, without the domain name)")
+	parser.Int(&flags.Port, "", "port", "the TCP route to lookup (specify the port), instead of a hostname")
+	parser.Bool(&flags.Probe, "", "probe", "probe each found route (concurrently) with a https request, or http if the route does not do https, and show the status code, latency and certificate expiry")
+	parser.Int(&flags.Pick, "p", "pick", "when the route is found in multiple org/spaces, target the Nth one (as numbered in the # column of the output), use with -t")
+	return parser
+}
+
+// routeTarget - An org/space combination where a route was found, a candidate to cf target to.
+type routeTarget struct {
+	orgName   string
+	spaceName string
+}
+
+/** getRouterGroupNames - Get the names of the router groups (like "default-tcp") from the routing API, keyed by guid. A failure is reported, the router group guids are shown instead. */
+func getRouterGroupNames(cmdCtx *conf.Context) map[string]string {
+	names := make(map[string]string)
+	routerGroups, err := cmdCtx.CfClient.RouterGroups.ListAll(cmdCtx.CfCtx)
+	if err != nil {
+		cmdCtx.AddFailure(conf.APIError(err, "failed to get the router groups, showing their guids"))
+		return names
+	}
+	for _, routerGroup := range routerGroups {
+		names[routerGroup.GUID] = routerGroup.Name
+	}
+	return names
+}
+
+/** getRouterGroupName - The name of the router group of a TCP domain, its guid if the name is unknown, "-" for other domains. */
+func getRouterGroupName(routerGroupNames map[string]string, routerGroup *resource.Relationship) string {
+	if routerGroup == nil {
+		return "-"
+	}
+	if name := routerGroupNames[routerGroup.GUID]; name != "" {
+		return name
+	}
+	return routerGroup.GUID
+}
+
+/** addTarget - Add the target to the list of targets, unless it is already in there. Also returns the number of the target (starting at 1), as used by --pick. */
+func addTarget(targets []routeTarget, target routeTarget) ([]routeTarget, int) {
+	for ix, existing := range targets {
+		if existing == target {
+			return targets, ix + 1
+		}
+	}
+	return append(targets, target), len(targets) + 1
+}
+
+/** pickTarget - Choose the org/space to target. If there is more than one, use the --pick flag, or ask the user (on stderr) if we have a terminal. Returns an error if we cannot choose, or if ctx is cancelled while asking. */
+func pickTarget(ctx context.Context, targets []routeTarget, pick int) (routeTarget, error) {
+	if len(targets) == 1 {
+		return targets[0], nil
+	}
+	if pick != 0 {
+		if pick < 1 || pick > len(targets) {
+			return routeTarget{}, conf.UsageError("invalid --pick value %d, should be between 1 and %d", pick, len(targets))
+		}
+		return targets[pick-1], nil
+	}
+	fmt.Fprintf(os.Stderr, "\nThe route was found in %d org/spaces:\n", len(targets))
+	for ix, target := range targets {
+		fmt.Fprintf(os.Stderr, "  %d. %s / %s\n", ix+1, terminal.EntityNameColor(target.orgName), terminal.EntityNameColor(target.spaceName))
+	}
+	if !isTerminal(os.Stdin) {
+		return routeTarget{}, conf.UsageError("not targeting, stdin is not a terminal, use --pick N to choose the org/space")
+	}
+	// read the answers in the background, so that a Ctrl-C (which cancels ctx) stops waiting for them
+	answers := make(chan string)
+	go func() {
+		defer close(answers)
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			answer, err := reader.ReadString('\n')
+			if err == nil || answer != "" {
+				answers <- answer
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		fmt.Fprintf(os.Stderr, "Which one do you want to target (1-%d) ? ", len(targets))
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(os.Stderr)
+			return routeTarget{}, context.Cause(ctx)
+		case answer, ok := <-answers:
+			if !ok {
+				fmt.Fprintln(os.Stderr)
+				return routeTarget{}, conf.UsageError("no choice made, not targeting")
 			}
-			_ = table.PrintTo(os.Stdout)
-			if conf.FlagSwitchToSpace {
-				if _, err = cliConnection.CliCommandWithoutTerminalOutput("target", "-o", orgName, "-s", spaceName); err != nil {
-					// You normally would use cliConnection.CliCommand, but that screws up my "NetworkPolicyV1Endpoint" in my cf config.json. So instead issue os command:
-					cmd := exec.Command("cf", "target", "-o", orgName, "-s", spaceName)
-					if err = cmd.Run(); err != nil {
-						fmt.Printf("failed to set target to org %s and space %s: %s", orgName, spaceName, err)
-					}
-				}
+			if choice, err := strconv.Atoi(strings.TrimSpace(answer)); err == nil && choice >= 1 && choice <= len(targets) {
+				return targets[choice-1], nil
 			}
 		}
 	}
 }
+
+/** isTerminal - Return true if the given file is a terminal (and not a pipe or a regular file). */
+func isTerminal(file *os.File) bool {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return fileInfo.Mode()&os.ModeCharDevice != 0
+}
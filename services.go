@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+const (
+	ServicesColsEnvVar = "CF_SS_COLS"
+	// guidsChunkSize is the max number of guids we filter on in one request (like for the bindings), to keep the request urls short
+	guidsChunkSize = 50
+)
+
+// servicesCommand holds the state of one "cf ss" invocation: the requested columns and the binding counts per service instance.
+type servicesCommand struct {
+	*conf.Context
+	colNames       []string
+	appCounts      map[string]int // bound apps, keyed by service instance guid
+	keyCounts      map[string]int // service keys, keyed by service instance guid
+	bindingsFailed bool
+	spaceNames     map[string]string // keyed by service instance guid
+	orgNames       map[string]string // keyed by service instance guid
+}
+
+// serviceDetails holds the plan, offering and broker names of a (managed) service instance, "-" for user-provided ones, "?" if the lookup failed.
+type serviceDetails struct {
+	plan     string
+	offering string
+	broker   string
+}
+
+const (
+	colSvcName          = "Name"
+	colSvcOrg           = "Org"
+	colSvcSpace         = "Space"
+	colSvcType          = "Type"
+	colSvcOffering      = "Offering"
+	colSvcPlan          = "Plan"
+	colSvcBroker        = "Broker"
+	colSvcLastOperation = "LastOperation"
+	colSvcApps          = "Apps"
+	colSvcKeys          = "Keys"
+	colSvcTags          = "Tags"
+	colSvcCreated       = "Created"
+	colSvcUpdated       = "Updated"
+	colSvcGuid          = "Guid"
+)
+
+var DefaultServiceColumns = []string{colSvcName, colSvcOffering, colSvcPlan, colSvcType, colSvcLastOperation, colSvcApps, colSvcKeys, colSvcUpdated}
+var ValidServiceColumns = []string{colSvcName, colSvcOrg, colSvcSpace, colSvcType, colSvcOffering, colSvcPlan, colSvcBroker, colSvcLastOperation, colSvcApps, colSvcKeys, colSvcTags, colSvcCreated, colSvcUpdated, colSvcGuid}
+
+/** listServices - The main function to produce the response to list the service instances. */
+func listServices(cmdCtx *conf.Context, args []string) error {
+	s := &servicesCommand{Context: cmdCtx, appCounts: make(map[string]int), keyCounts: make(map[string]int), spaceNames: make(map[string]string), orgNames: make(map[string]string)}
+	s.Flags.Scope = conf.ScopeSpace
+	if err := s.ParseFlags(newServicesFlagParser(&s.Flags), args); err != nil {
+		return err
+	}
+	orgGuids, spaceGuids, err := s.ScopeFilters()
+	if err != nil {
+		return err
+	}
+	if s.colNames, err = s.getRequestedColNames(); err != nil {
+		return err
+	}
+	if s.ShowInfo() {
+		fmt.Printf("Getting service instances for %s as %s...\n\n", s.ScopeDescription(), terminal.EntityNameColor(s.CurrentUser))
+	}
+	instances, err := s.CfClient.ServiceInstances.ListAll(s.CfCtx, &client.ServiceInstanceListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return conf.APIError(err, "failed to get service instances")
+	}
+	if len(instances) == 0 {
+		s.Notice("no service instances found")
+		return nil
+	}
+	if conf.HasColumn(s.colNames, colSvcSpace) || conf.HasColumn(s.colNames, colSvcOrg) {
+		for _, instance := range instances {
+			s.resolveLocation(instance)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		iGuid, jGuid := instances[i].GUID, instances[j].GUID
+		if s.orgNames[iGuid] != s.orgNames[jGuid] {
+			return s.orgNames[iGuid] < s.orgNames[jGuid]
+		}
+		if s.spaceNames[iGuid] != s.spaceNames[jGuid] {
+			return s.spaceNames[iGuid] < s.spaceNames[jGuid]
+		}
+		return strings.ToLower(instances[i].Name) < strings.ToLower(instances[j].Name)
+	})
+	if conf.HasColumn(s.colNames, colSvcApps) || conf.HasColumn(s.colNames, colSvcKeys) {
+		s.getBindingCounts(instances)
+	}
+	if s.CfCtx.Err() != nil {
+		return nil // cancelled, Run reports why
+	}
+
+	table := s.NewTable(s.colNames)
+	if s.Flags.HideHeaders {
+		table.NoHeaders()
+	}
+	managed, failed := 0, 0
+	for _, instance := range instances {
+		if s.CfCtx.Err() != nil {
+			return nil // cancelled, Run reports why
+		}
+		var details serviceDetails
+		if conf.HasColumn(s.colNames, colSvcPlan) || conf.HasColumn(s.colNames, colSvcOffering) || conf.HasColumn(s.colNames, colSvcBroker) {
+			details = getServiceDetails(s.Context, instance)
+		}
+		var colValues []string
+		for _, colName := range s.colNames {
+			colValues = append(colValues, s.getColValue(instance, colName, details))
+		}
+		table.Add(colValues...)
+		if instance.Type == "managed" {
+			managed++
+		}
+		if instance.LastOperation.State == "failed" {
+			failed++
+		}
+	}
+	_ = table.PrintTo(os.Stdout)
+	if s.ShowInfo() {
+		summary := fmt.Sprintf("%d service instances (%d managed, %d user-provided)", len(instances), managed, len(instances)-managed)
+		if failed > 0 {
+			fmt.Printf("\n  %s, %s\n", terminal.StoppedColor(summary), terminal.FailureColor(fmt.Sprintf("%d failed", failed)))
+		} else {
+			fmt.Printf("\n  %s\n", terminal.StoppedColor(summary))
+		}
+	}
+	return nil
+}
+
+/** newServicesFlagParser - Create the flag parser for "cf ss", also used to generate the shell completion. */
+func newServicesFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("ss", flags)
+	parser.String(&flags.Columns, "c", "columns", "The columns to show (comma separated, case insensitive), instead of CF_SS_COLS, use +Col,-Col to add/remove columns to/from the default columns, or ALL")
+	parser.String(&flags.Scope, "", "scope", "Which service instances to show: space (the targeted space, default), org (all spaces of the targeted org) or all")
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers (and summary) of the output (handy for automated processing), default is false")
+	return parser
+}
+
+/** getRequestedColNames - Find out what the desired columns are, from the --columns flag or the envvar CF_SS_COLS, or use the default set of columns. The Space column is added if we look beyond the current space. */
+func (s *servicesCommand) getRequestedColNames() ([]string, error) {
+	colNames := DefaultServiceColumns
+	requestedColumns, source := s.Flags.Columns, "--columns"
+	if requestedColumns == "" {
+		requestedColumns, source = os.Getenv(ServicesColsEnvVar), ServicesColsEnvVar+" envvar"
+	}
+	if requestedColumns != "" {
+		var err error
+		if colNames, err = conf.SelectColumns(requestedColumns, source, DefaultServiceColumns, ValidServiceColumns); err != nil {
+			return nil, err
+		}
+	}
+	if s.Flags.Scope != conf.ScopeSpace && !conf.HasColumn(colNames, colSvcSpace) {
+		colNames = append([]string{colNames[0], colSvcSpace}, colNames[1:]...)
+		if s.Flags.Scope == conf.ScopeAll && !conf.HasColumn(colNames, colSvcOrg) {
+			colNames = append([]string{colNames[0], colSvcOrg}, colNames[1:]...)
+		}
+	}
+	return colNames, nil
+}
+
+/** getColValue - Get the value of the given column for the given service instance. */
+func (s *servicesCommand) getColValue(instance *resource.ServiceInstance, colName string, details serviceDetails) string {
+	switch colName {
+	case colSvcName:
+		return instance.Name
+	case colSvcOrg:
+		return s.orgNames[instance.GUID]
+	case colSvcSpace:
+		return s.spaceNames[instance.GUID]
+	case colSvcType:
+		return instance.Type
+	case colSvcOffering:
+		return details.offering
+	case colSvcPlan:
+		return details.plan
+	case colSvcBroker:
+		return details.broker
+	case colSvcLastOperation:
+		return getLastOperation(instance.LastOperation)
+	case colSvcApps:
+		return s.getBindingCount(s.appCounts, instance.GUID)
+	case colSvcKeys:
+		return s.getBindingCount(s.keyCounts, instance.GUID)
+	case colSvcTags:
+		return strings.Join(instance.Tags, ",")
+	case colSvcCreated:
+		return instance.CreatedAt.Format(time.RFC3339)
+	case colSvcUpdated:
+		return instance.UpdatedAt.Format(time.RFC3339)
+	case colSvcGuid:
+		return instance.GUID
+	}
+	return ""
+}
+
+/** getServiceDetails - Get the plan, offering and broker name of the service instance (cached, these are shared by many instances). */
+func getServiceDetails(cmdCtx *conf.Context, instance *resource.ServiceInstance) serviceDetails {
+	if instance.Relationships.ServicePlan == nil || instance.Relationships.ServicePlan.Data == nil {
+		return serviceDetails{plan: "-", offering: "-", broker: "-"} // user-provided
+	}
+	details := serviceDetails{plan: "?", offering: "?", broker: "?"}
+	plan, err := cmdCtx.Resolver.GetServicePlan(instance.Relationships.ServicePlan.Data.GUID)
+	if err != nil {
+		cmdCtx.AddFailure(conf.APIError(err, "failed to get service plan for service instance %s", instance.Name))
+		return details
+	}
+	details.plan = plan.Name
+	offering, err := cmdCtx.Resolver.GetServiceOffering(plan.Relationships.ServiceOffering.Data.GUID)
+	if err != nil {
+		cmdCtx.AddFailure(conf.APIError(err, "failed to get service offering for service instance %s", instance.Name))
+		return details
+	}
+	details.offering = offering.Name
+	broker, err := cmdCtx.Resolver.GetServiceBroker(offering.Relationships.ServiceBroker.Data.GUID)
+	if err != nil {
+		cmdCtx.AddFailure(conf.APIError(err, "failed to get service broker for service instance %s", instance.Name))
+		return details
+	}
+	details.broker = broker.Name
+	return details
+}
+
+/** getBindingCounts - Count the app bindings and service keys of the service instances. */
+func (s *servicesCommand) getBindingCounts(instances []*resource.ServiceInstance) {
+	var guids []string
+	for _, instance := range instances {
+		guids = append(guids, instance.GUID)
+	}
+	bindings, err := listCredentialBindings(s.Context, guids, func(opts *client.ServiceCredentialBindingListOptions, guids []string) {
+		opts.ServiceInstanceGUIDs = client.Filter{Values: guids}
+	})
+	if err != nil {
+		s.AddFailure(conf.APIError(err, "failed to get service bindings"))
+		s.bindingsFailed = true
+		return
+	}
+	for _, binding := range bindings {
+		if binding.Relationships.ServiceInstance == nil || binding.Relationships.ServiceInstance.Data == nil {
+			continue
+		}
+		if binding.Type == "key" {
+			s.keyCounts[binding.Relationships.ServiceInstance.Data.GUID]++
+		} else {
+			s.appCounts[binding.Relationships.ServiceInstance.Data.GUID]++
+		}
+	}
+}
+
+func (s *servicesCommand) getBindingCount(counts map[string]int, guid string) string {
+	if s.bindingsFailed {
+		return terminal.FailureColor("?")
+	}
+	return fmt.Sprintf("%4d", counts[guid])
+}
+
+/** resolveLocation - Look up the space and org name of the service instance, for the Space and Org columns (and the sorting). */
+func (s *servicesCommand) resolveLocation(instance *resource.ServiceInstance) {
+	s.spaceNames[instance.GUID], s.orgNames[instance.GUID] = "?", "?"
+	if instance.Relationships.Space == nil || instance.Relationships.Space.Data == nil {
+		return
+	}
+	space, err := s.Resolver.GetSpace(instance.Relationships.Space.Data.GUID)
+	if err != nil {
+		s.AddFailure(conf.APIError(err, "failed to get space for service instance %s", instance.Name))
+		return
+	}
+	s.spaceNames[instance.GUID] = space.Name
+	if !conf.HasColumn(s.colNames, colSvcOrg) {
+		return
+	}
+	org, err := s.Resolver.GetOrg(space.Relationships.Organization.Data.GUID)
+	if err != nil {
+		s.AddFailure(conf.APIError(err, "failed to get org for service instance %s", instance.Name))
+		return
+	}
+	s.orgNames[instance.GUID] = org.Name
+}
+
+/** getLastOperation - Format the last operation like "create succeeded", failed is red and in progress is yellow. */
+func getLastOperation(lastOperation resource.LastOperation) string {
+	if lastOperation.Type == "" && lastOperation.State == "" {
+		return "-"
+	}
+	text := strings.TrimSpace(lastOperation.Type + " " + lastOperation.State)
+	switch lastOperation.State {
+	case "failed":
+		return terminal.FailureColor(text)
+	case "in progress", "initial":
+		return terminal.AdvisoryColor(text)
+	}
+	return terminal.SuccessColor(text)
+}
+
+/** listCredentialBindings - Get the service credential bindings for the given guids, in chunks to keep the request urls short. setFilter sets a chunk of guids as the filter in the list options. */
+func listCredentialBindings(cmdCtx *conf.Context, guids []string, setFilter func(opts *client.ServiceCredentialBindingListOptions, guids []string)) ([]*resource.ServiceCredentialBinding, error) {
+	return listInChunks(guids, func(chunk []string) ([]*resource.ServiceCredentialBinding, error) {
+		opts := &client.ServiceCredentialBindingListOptions{ListOptions: &client.ListOptions{}}
+		setFilter(opts, chunk)
+		return cmdCtx.CfClient.ServiceCredentialBindings.ListAll(cmdCtx.CfCtx, opts)
+	})
+}
+
+/** listServiceInstancesByGuid - Get the service instances with the given guids (in chunks), keyed by guid. Instances shared from a space we can't see are not returned. */
+func listServiceInstancesByGuid(cmdCtx *conf.Context, guids []string) (map[string]*resource.ServiceInstance, error) {
+	instances, err := listInChunks(guids, func(chunk []string) ([]*resource.ServiceInstance, error) {
+		return cmdCtx.CfClient.ServiceInstances.ListAll(cmdCtx.CfCtx, &client.ServiceInstanceListOptions{ListOptions: &client.ListOptions{}, GUIDs: client.Filter{Values: chunk}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	instancesByGuid := make(map[string]*resource.ServiceInstance)
+	for _, instance := range instances {
+		instancesByGuid[instance.GUID] = instance
+	}
+	return instancesByGuid, nil
+}
+
+/** listInChunks - Call list for chunks of (at most guidsChunkSize) guids, and return all results. */
+func listInChunks[T any](guids []string, list func(chunk []string) ([]*T, error)) ([]*T, error) {
+	var all []*T
+	for start := 0; start < len(guids); start += guidsChunkSize {
+		chunk, err := list(guids[start:min(start+guidsChunkSize, len(guids))])
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, chunk...)
+	}
+	return all, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/conf"
+	"github.com/metskem/panzer-plugin/event"
+)
+
+var staleColNames = []string{"app", "state", "why", "memory", "last event", "event time", "actor"}
+
+// staleApp is an app that looks forgotten, with the reasons why and the memory it reserves (if started).
+type staleApp struct {
+	app       *resource.App
+	reasons   []string
+	memory    int // MB, of all instances of all processes
+	instances int
+}
+
+/** newStaleFlagParser - Create the flag parser for "cf stale-apps", also used to generate the shell completion. */
+func newStaleFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("stale-apps", flags)
+	parser.Int(&flags.Days, "d", "days", "Apps that are stopped for longer than this number of days, or have a droplet older than this, are stale, default is 90")
+	parser.String(&flags.AppName, "a", "appname", "Filter the output by the given appname (regular expression)")
+	parser.String(&flags.Scope, "", "scope", "Which apps to check: space (the targeted space, default), org (all spaces of the targeted org) or all")
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers (and summary) of the output (handy for automated processing), default is false")
+	return parser
+}
+
+/** listStaleApps - The main function to produce the response to list the apps that are stopped for long, have no instances or have an old droplet. */
+func listStaleApps(cmdCtx *conf.Context, args []string) error {
+	cmdCtx.Flags.Scope, cmdCtx.Flags.Days = conf.ScopeSpace, 90
+	if err := cmdCtx.ParseFlags(newStaleFlagParser(&cmdCtx.Flags), args); err != nil {
+		return err
+	}
+	if cmdCtx.Flags.Days < 0 {
+		return conf.UsageError("invalid --days %d, should be positive", cmdCtx.Flags.Days)
+	}
+	orgGuids, spaceGuids, err := cmdCtx.ScopeFilters()
+	if err != nil {
+		return err
+	}
+	appNameRegex, err := regexp.Compile(cmdCtx.Flags.AppName)
+	if err != nil {
+		return conf.UsageError("invalid appname filter %s: %s", cmdCtx.Flags.AppName, err)
+	}
+	if cmdCtx.ShowInfo() {
+		fmt.Printf("Getting apps stopped or staged more than %d days ago, or without instances, for %s as %s...\n\n", cmdCtx.Flags.Days, cmdCtx.ScopeDescription(), terminal.EntityNameColor(cmdCtx.CurrentUser))
+	}
+	unfilteredApps, err := cmdCtx.CfClient.Applications.ListAll(cmdCtx.CfCtx, &client.AppListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return conf.APIError(err, "failed to get apps")
+	}
+	var apps []*resource.App
+	for _, app := range unfilteredApps {
+		if appNameRegex.MatchString(app.Name) {
+			apps = append(apps, app)
+		}
+	}
+	if len(apps) == 0 {
+		cmdCtx.Notice("no apps found")
+		return nil
+	}
+	processes, err := cmdCtx.CfClient.Processes.ListAll(cmdCtx.CfCtx, &client.ProcessListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return conf.APIError(err, "failed to get processes")
+	}
+	droplets, err := getCurrentDroplets(cmdCtx, getAppGuids(apps))
+	if err != nil {
+		return conf.APIError(err, "failed to get droplets")
+	}
+
+	staleApps := getStaleApps(apps, processes, droplets, cmdCtx.Flags.Days)
+	if len(staleApps) == 0 {
+		cmdCtx.Notice("no stale apps found (of %d apps)", len(apps))
+		return nil
+	}
+	colNames := staleColNames
+	spaceNames, orgNames := make(map[string]string), make(map[string]string) // keyed by app guid
+	if cmdCtx.Flags.Scope != conf.ScopeSpace {
+		colNames = append([]string{"space"}, colNames...)
+		if cmdCtx.Flags.Scope == conf.ScopeAll {
+			colNames = append([]string{"org"}, colNames...)
+		}
+		for _, stale := range staleApps {
+			spaceNames[stale.app.GUID], orgNames[stale.app.GUID] = getAppLocation(cmdCtx, stale.app, cmdCtx.Flags.Scope == conf.ScopeAll)
+		}
+	}
+	sort.Slice(staleApps, func(i, j int) bool {
+		iGuid, jGuid := staleApps[i].app.GUID, staleApps[j].app.GUID
+		if orgNames[iGuid] != orgNames[jGuid] {
+			return orgNames[iGuid] < orgNames[jGuid]
+		}
+		if spaceNames[iGuid] != spaceNames[jGuid] {
+			return spaceNames[iGuid] < spaceNames[jGuid]
+		}
+		return strings.ToLower(staleApps[i].app.Name) < strings.ToLower(staleApps[j].app.Name)
+	})
+
+	table := cmdCtx.NewTable(colNames)
+	if cmdCtx.Flags.HideHeaders {
+		table.NoHeaders()
+	}
+	var memory, startedMemory int
+	for _, stale := range staleApps {
+		if cmdCtx.CfCtx.Err() != nil {
+			return nil // cancelled, Run reports why
+		}
+		lastEvent, eventTime, actor := "-", "-", "-"
+		if auditEvent, err := event.GetLastEvent(cmdCtx, stale.app.GUID); err != nil {
+			cmdCtx.AddFailure(conf.APIError(err, "failed to get the last audit event of app %s", stale.app.Name))
+			lastEvent = terminal.FailureColor("?")
+		} else if auditEvent != nil {
+			lastEvent, eventTime, actor = auditEvent.Type, auditEvent.CreatedAt.Format(time.RFC3339), event.FormatActor(auditEvent)
+		}
+		state := terminal.SuccessColor(strings.ToLower(stale.app.State))
+		if stale.app.State == "STOPPED" {
+			state = terminal.StoppedColor(strings.ToLower(stale.app.State))
+		} else {
+			startedMemory += stale.memory
+		}
+		memory += stale.memory
+		var colValues []string
+		if cmdCtx.Flags.Scope == conf.ScopeAll {
+			colValues = append(colValues, orgNames[stale.app.GUID])
+		}
+		if cmdCtx.Flags.Scope != conf.ScopeSpace {
+			colValues = append(colValues, spaceNames[stale.app.GUID])
+		}
+		colValues = append(colValues, stale.app.Name, state, strings.Join(stale.reasons, ", "), fmt.Sprintf("%6s", getFormattedUnit(stale.memory*1024*1024)), lastEvent, eventTime, actor)
+		table.Add(colValues...)
+	}
+	_ = table.PrintTo(os.Stdout)
+	if cmdCtx.ShowInfo() {
+		fmt.Printf("\n  %s\n", terminal.StoppedColor(fmt.Sprintf("%d of %d apps are stale, Memory: %s, of which %s is started (and counts against the quota)", len(staleApps), len(apps), getFormattedUnit(memory*1024*1024), getFormattedUnit(startedMemory*1024*1024))))
+	}
+	return nil
+}
+
+/** getStaleApps - Find the apps that are stopped for more than the given days (since their last update), have no instances, or have a droplet older than the given days. */
+func getStaleApps(apps []*resource.App, processes []*resource.Process, droplets map[string]*resource.Droplet, days int) []*staleApp {
+	var processesFound = make(map[string]bool) // keyed by app guid
+	staleApps := make(map[string]*staleApp)
+	for _, app := range apps {
+		staleApps[app.GUID] = &staleApp{app: app}
+	}
+	for _, process := range processes {
+		if stale := staleApps[process.Relationships.App.Data.GUID]; stale != nil && process.Type != "task" {
+			processesFound[stale.app.GUID] = true
+			stale.memory += process.MemoryInMB * process.Instances
+			stale.instances += process.Instances
+		}
+	}
+	maxAge := time.Duration(days) * 24 * time.Hour
+	var found []*staleApp
+	for _, app := range apps {
+		stale := staleApps[app.GUID]
+		if app.State == "STOPPED" && time.Since(app.UpdatedAt) > maxAge {
+			stale.reasons = append(stale.reasons, fmt.Sprintf("stopped %dd", int(time.Since(app.UpdatedAt).Hours()/24)))
+		}
+		if processesFound[app.GUID] && stale.instances == 0 {
+			stale.reasons = append(stale.reasons, "0 instances")
+		}
+		if droplet := droplets[app.GUID]; droplet != nil && time.Since(droplet.CreatedAt) > maxAge {
+			stale.reasons = append(stale.reasons, fmt.Sprintf("droplet %dd", int(time.Since(droplet.CreatedAt).Hours()/24)))
+		}
+		if len(stale.reasons) > 0 {
+			found = append(found, stale)
+		}
+	}
+	return found
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/metskem/panzer-plugin/cfapi/fake"
+)
+
+func TestGetStaleApps(t *testing.T) {
+	daysAgo := func(days int) time.Time { return time.Now().Add(-time.Duration(days)*24*time.Hour - time.Hour) }
+	staleApp := func(guid, state string, updatedDaysAgo int) *resource.App {
+		app := newTestApp(guid, guid, state, nil)
+		app.UpdatedAt = daysAgo(updatedDaysAgo)
+		return app
+	}
+	f := &fake.Fake{
+		Apps: []*resource.App{
+			staleApp("fresh", "STARTED", 1),
+			staleApp("stopped-recently", "STOPPED", 10),
+			staleApp("stopped-long", "STOPPED", 120),
+			staleApp("scaled-to-zero", "STARTED", 1),
+			staleApp("old-droplet", "STARTED", 1),
+			staleApp("everything", "STOPPED", 200),
+			staleApp("not-staged", "STOPPED", 1),
+			staleApp("only-tasks", "STARTED", 1),
+		},
+		Processes: []*resource.Process{
+			newTestProcess("p-fresh", "fresh", "web", 2, 512),
+			newTestProcess("p-stopped-recently", "stopped-recently", "web", 1, 256),
+			newTestProcess("p-stopped-long", "stopped-long", "web", 2, 1024),
+			newTestProcess("p-scaled-to-zero", "scaled-to-zero", "web", 0, 1024),
+			newTestProcess("p-old-droplet", "old-droplet", "web", 1, 512),
+			newTestProcess("p-old-droplet-worker", "old-droplet", "worker", 2, 256),
+			newTestProcess("p-everything", "everything", "web", 0, 512),
+			newTestProcess("p-only-tasks", "only-tasks", "task", 0, 512),
+		},
+		Droplets: []*resource.Droplet{
+			{Resource: resource.Resource{GUID: "d-fresh", CreatedAt: daysAgo(5)}},
+			{Resource: resource.Resource{GUID: "d-old", CreatedAt: daysAgo(100)}},
+			{Resource: resource.Resource{GUID: "d-older", CreatedAt: daysAgo(300)}},
+		},
+		CurrentDroplets: map[string]string{"fresh": "d-fresh", "stopped-recently": "d-fresh", "stopped-long": "d-fresh", "scaled-to-zero": "d-fresh", "old-droplet": "d-old", "everything": "d-older", "only-tasks": "d-fresh"},
+	}
+	cmdCtx := newTestContext(t, f)
+	droplets, err := getCurrentDroplets(cmdCtx, getAppGuids(f.Apps))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if droplets["not-staged"] != nil {
+		t.Errorf("got a droplet for an app that is not staged")
+	}
+
+	tests := []struct {
+		name      string
+		days      int
+		want      string // app guid: reasons (memory, instances), sorted like the apps
+		wantCount int
+	}{
+		{name: "90 days", days: 90, wantCount: 4, want: "stopped-long: stopped 120d (2048, 2)|scaled-to-zero: 0 instances (0, 0)|old-droplet: droplet 100d (1024, 3)|everything: stopped 200d,0 instances,droplet 300d (0, 0)"},
+		{name: "365 days", days: 365, wantCount: 2, want: "scaled-to-zero: 0 instances (0, 0)|everything: 0 instances (0, 0)"},
+		{name: "0 days", days: 0, wantCount: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			staleApps := getStaleApps(f.Apps, f.Processes, droplets, tt.days)
+			if len(staleApps) != tt.wantCount {
+				t.Errorf("got %d stale apps, want %d", len(staleApps), tt.wantCount)
+			}
+			if tt.want == "" {
+				return
+			}
+			var got []string
+			for _, stale := range staleApps {
+				got = append(got, stale.app.GUID+": "+strings.Join(stale.reasons, ",")+" ("+strconv.Itoa(stale.memory)+", "+strconv.Itoa(stale.instances)+")")
+			}
+			if strings.Join(got, "|") != tt.want {
+				t.Errorf("got %s, want %s", strings.Join(got, "|"), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/integrii/flaggy"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+const (
+	taskFailed    = "FAILED"
+	taskSucceeded = "SUCCEEDED"
+	taskRunning   = "RUNNING"
+	// tasksColumnWindow is how far back the Tasks column of "cf aa" looks for finished tasks
+	tasksColumnWindow = 24 * time.Hour
+)
+
+// taskStates are the states of a task, the active ones (that are always shown) first.
+var taskStates = []string{"PENDING", taskRunning, "CANCELING", taskSucceeded, taskFailed}
+
+var taskColNames = []string{"app", "id", "name", "command", "state", "memory", "disk", "started", "duration", "failure reason"}
+
+/** newTasksFlagParser - Create the flag parser for "cf tt", also used to generate the shell completion. */
+func newTasksFlagParser(flags *conf.Flags) *flaggy.Parser {
+	parser := conf.NewFlagParser("tt", flags)
+	parser.String(&flags.AppName, "a", "appname", "Filter the output by the given appname (regular expression)")
+	parser.String(&flags.States, "s", "state", "Only show the tasks with the given state(s) (comma separated, case insensitive), like failed or running,pending, default is all states")
+	parser.Duration(&flags.Window, "w", "window", "Show the finished tasks that started within this time (like 1h or 7d as 168h), default is 24h, the active tasks are always shown")
+	parser.String(&flags.Scope, "", "scope", "Which tasks to show: space (the targeted space, default), org (all spaces of the targeted org) or all")
+	parser.Bool(&flags.HideHeaders, "q", "hide-headers", "Hide the headers (and summary) of the output (handy for automated processing), default is false")
+	return parser
+}
+
+/** listTasks - The main function to produce the response to list the running and the recent tasks of the apps. */
+func listTasks(cmdCtx *conf.Context, args []string) error {
+	cmdCtx.Flags.Scope, cmdCtx.Flags.Window = conf.ScopeSpace, tasksColumnWindow
+	if err := cmdCtx.ParseFlags(newTasksFlagParser(&cmdCtx.Flags), args); err != nil {
+		return err
+	}
+	if cmdCtx.Flags.Window < 0 {
+		return conf.UsageError("invalid --window %s, should be positive", cmdCtx.Flags.Window)
+	}
+	states := taskStates
+	if cmdCtx.Flags.States != "" {
+		states = nil
+		for _, state := range strings.Split(strings.ToUpper(cmdCtx.Flags.States), ",") {
+			if !slices.Contains(taskStates, strings.TrimSpace(state)) {
+				return conf.UsageError("invalid --state %s, should be one or more of %s", strings.ToLower(strings.TrimSpace(state)), strings.ToLower(strings.Join(taskStates, ",")))
+			}
+			states = append(states, strings.TrimSpace(state))
+		}
+	}
+	orgGuids, spaceGuids, err := cmdCtx.ScopeFilters()
+	if err != nil {
+		return err
+	}
+	appNameRegex, err := regexp.Compile(cmdCtx.Flags.AppName)
+	if err != nil {
+		return conf.UsageError("invalid appname filter %s: %s", cmdCtx.Flags.AppName, err)
+	}
+	if cmdCtx.ShowInfo() {
+		fmt.Printf("Getting the active tasks and the tasks of the last %s for %s as %s...\n\n", cmdCtx.Flags.Window, cmdCtx.ScopeDescription(), terminal.EntityNameColor(cmdCtx.CurrentUser))
+	}
+	unfilteredApps, err := cmdCtx.CfClient.Applications.ListAll(cmdCtx.CfCtx, &client.AppListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids})
+	if err != nil {
+		return conf.APIError(err, "failed to get apps")
+	}
+	appData := make(map[string]*resource.App)
+	for _, app := range unfilteredApps {
+		if appNameRegex.MatchString(app.Name) {
+			appData[app.GUID] = app
+		}
+	}
+	unfilteredTasks, err := getTasks(cmdCtx, orgGuids, spaceGuids, states, cmdCtx.Flags.Window)
+	if err != nil {
+		return conf.APIError(err, "failed to get tasks")
+	}
+	var tasks []*resource.Task
+	for _, task := range unfilteredTasks {
+		if appData[task.Relationships.App.Data.GUID] != nil {
+			tasks = append(tasks, task)
+		}
+	}
+	if len(tasks) == 0 {
+		cmdCtx.Notice("no tasks found")
+		return nil
+	}
+
+	colNames := taskColNames
+	spaceNames, orgNames := make(map[string]string), make(map[string]string) // keyed by app guid
+	if cmdCtx.Flags.Scope != conf.ScopeSpace {
+		colNames = append([]string{"space"}, colNames...)
+		if cmdCtx.Flags.Scope == conf.ScopeAll {
+			colNames = append([]string{"org"}, colNames...)
+		}
+		for _, task := range tasks {
+			appGuid := task.Relationships.App.Data.GUID
+			if _, found := spaceNames[appGuid]; !found {
+				spaceNames[appGuid], orgNames[appGuid] = getAppLocation(cmdCtx, appData[appGuid], cmdCtx.Flags.Scope == conf.ScopeAll)
+			}
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		iGuid, jGuid := tasks[i].Relationships.App.Data.GUID, tasks[j].Relationships.App.Data.GUID
+		if orgNames[iGuid] != orgNames[jGuid] {
+			return orgNames[iGuid] < orgNames[jGuid]
+		}
+		if spaceNames[iGuid] != spaceNames[jGuid] {
+			return spaceNames[iGuid] < spaceNames[jGuid]
+		}
+		if iGuid != jGuid {
+			return strings.ToLower(appData[iGuid].Name) < strings.ToLower(appData[jGuid].Name)
+		}
+		return tasks[i].SequenceID < tasks[j].SequenceID
+	})
+
+	table := cmdCtx.NewTable(colNames)
+	if cmdCtx.Flags.HideHeaders {
+		table.NoHeaders()
+	}
+	stateCounts := make(map[string]int)
+	for _, task := range tasks {
+		appGuid := task.Relationships.App.Data.GUID
+		stateCounts[task.State]++
+		command := task.Command
+		if command == "" {
+			command = "-" // only shown to space developers
+		}
+		failureReason := "-"
+		if task.Result.FailureReason != nil && *task.Result.FailureReason != "" {
+			failureReason = terminal.FailureColor(*task.Result.FailureReason)
+		}
+		var colValues []string
+		if cmdCtx.Flags.Scope == conf.ScopeAll {
+			colValues = append(colValues, orgNames[appGuid])
+		}
+		if cmdCtx.Flags.Scope != conf.ScopeSpace {
+			colValues = append(colValues, spaceNames[appGuid])
+		}
+		colValues = append(colValues, appData[appGuid].Name, fmt.Sprintf("%3d", task.SequenceID), task.Name, command, colorTaskState(task.State),
+			fmt.Sprintf("%6s", getFormattedUnit(task.MemoryInMB*1024*1024)), fmt.Sprintf("%6s", getFormattedUnit(task.DiskInMB*1024*1024)),
+			task.CreatedAt.Format(time.RFC3339), fmt.Sprintf("%12s", getFormattedElapsedTime(getTaskDuration(task))), failureReason)
+		table.Add(colValues...)
+	}
+	_ = table.PrintTo(os.Stdout)
+	if cmdCtx.ShowInfo() {
+		fmt.Printf("\n  %s\n", terminal.StoppedColor(fmt.Sprintf("%d tasks: ", len(tasks)))+formatTaskCounts(stateCounts))
+	}
+	return nil
+}
+
+/** getTasks - Get the tasks with the given states: the active ones regardless of their age, the finished (succeeded or failed) ones only if they started within the window. */
+func getTasks(cmdCtx *conf.Context, orgGuids, spaceGuids client.Filter, states []string, window time.Duration) ([]*resource.Task, error) {
+	var activeStates, finishedStates []string
+	for _, state := range states {
+		if state == taskSucceeded || state == taskFailed {
+			finishedStates = append(finishedStates, state)
+		} else {
+			activeStates = append(activeStates, state)
+		}
+	}
+	var tasks []*resource.Task
+	if len(activeStates) > 0 {
+		activeTasks, err := cmdCtx.CfClient.Tasks.ListAll(cmdCtx.CfCtx, &client.TaskListOptions{ListOptions: &client.ListOptions{}, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids, States: client.Filter{Values: activeStates}})
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, activeTasks...)
+	}
+	if len(finishedStates) > 0 {
+		listOptions := &client.ListOptions{}
+		listOptions.CreatedAts.After(time.Now().Add(-window))
+		finishedTasks, err := cmdCtx.CfClient.Tasks.ListAll(cmdCtx.CfCtx, &client.TaskListOptions{ListOptions: listOptions, OrganizationGUIDs: orgGuids, SpaceGUIDs: spaceGuids, States: client.Filter{Values: finishedStates}})
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, finishedTasks...)
+	}
+	return tasks, nil
+}
+
+/** getTaskDuration - How long the task ran (in seconds), or is running until now. */
+func getTaskDuration(task *resource.Task) int {
+	if task.State == taskSucceeded || task.State == taskFailed {
+		return int(task.UpdatedAt.Sub(task.CreatedAt).Seconds())
+	}
+	return int(time.Since(task.CreatedAt).Seconds())
+}
+
+/** colorTaskState - The task state in lowercase, failed in red, succeeded in green. */
+func colorTaskState(state string) string {
+	switch state {
+	case taskFailed:
+		return terminal.FailureColor(strings.ToLower(state))
+	case taskSucceeded:
+		return terminal.SuccessColor(strings.ToLower(state))
+	case taskRunning:
+		return terminal.EntityNameColor(strings.ToLower(state))
+	}
+	return strings.ToLower(state)
+}
+
+/** formatTaskCounts - The number of tasks per state, like "2 running, 1 failed" (failed in red), "-" if there are none. */
+func formatTaskCounts(stateCounts map[string]int) string {
+	var counts []string
+	for _, state := range taskStates {
+		if stateCounts[state] == 0 {
+			continue
+		}
+		count := fmt.Sprintf("%d %s", stateCounts[state], strings.ToLower(state))
+		if state == taskFailed {
+			count = terminal.FailureColor(count)
+		}
+		counts = append(counts, count)
+	}
+	if len(counts) == 0 {
+		return "-"
+	}
+	return strings.Join(counts, ", ")
+}
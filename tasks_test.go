@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/cli/cf/terminal"
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/metskem/panzer-plugin/cfapi"
+	"github.com/metskem/panzer-plugin/cfapi/fake"
+	"github.com/metskem/panzer-plugin/conf"
+)
+
+// recordingTasks keeps the query of each ListAll call of the wrapped TasksAPI.
+type recordingTasks struct {
+	cfapi.TasksAPI
+	queries []url.Values
+}
+
+func (r *recordingTasks) ListAll(ctx context.Context, opts *client.TaskListOptions) ([]*resource.Task, error) {
+	query, err := opts.ToQueryString()
+	if err != nil {
+		return nil, err
+	}
+	r.queries = append(r.queries, query)
+	return r.TasksAPI.ListAll(ctx, opts)
+}
+
+/** newTestTask - A task of the app in the given state, created the given time ago and updated (finished) a minute later. */
+func newTestTask(guid, appGuid, state string, age time.Duration) *resource.Task {
+	task := &resource.Task{Name: guid, State: state, MemoryInMB: 256, DiskInMB: 512, Resource: resource.Resource{GUID: guid, CreatedAt: time.Now().Add(-age), UpdatedAt: time.Now().Add(-age + time.Minute)}}
+	task.Relationships.App.Data = &resource.Relationship{GUID: appGuid}
+	return task
+}
+
+func TestGetTasks(t *testing.T) {
+	f := newTestSpaceFake(1)
+	f.Tasks = []*resource.Task{
+		newTestTask("running-old", "app-000", taskRunning, 48*time.Hour),
+		newTestTask("pending-new", "app-000", "PENDING", time.Hour),
+		newTestTask("succeeded-new", "app-000", taskSucceeded, time.Hour),
+		newTestTask("failed-new", "app-000", taskFailed, 2*time.Hour),
+		newTestTask("failed-old", "app-000", taskFailed, 48*time.Hour),
+	}
+	tests := []struct {
+		name        string
+		states      []string
+		window      time.Duration
+		want        string
+		wantQueries []string // the created_ats[gt] filter of each call, empty if it has none
+	}{
+		{name: "all states", states: taskStates, window: 24 * time.Hour, want: "running-old,pending-new,succeeded-new,failed-new", wantQueries: []string{"", "created_ats[gt]"}},
+		{name: "active states ignore the window", states: []string{taskRunning, "PENDING"}, window: time.Minute, want: "running-old,pending-new", wantQueries: []string{""}},
+		{name: "finished states use the window", states: []string{taskFailed}, window: 24 * time.Hour, want: "failed-new", wantQueries: []string{"created_ats[gt]"}},
+		{name: "a longer window", states: []string{taskFailed}, window: 72 * time.Hour, want: "failed-new,failed-old", wantQueries: []string{"created_ats[gt]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmdCtx := newTestContext(t, f)
+			recorder := &recordingTasks{TasksAPI: cmdCtx.CfClient.Tasks}
+			cmdCtx.CfClient.Tasks = recorder
+			tasks, err := getTasks(cmdCtx, client.Filter{}, client.Filter{Values: []string{testSpaceGuid}}, tt.states, tt.window)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var guids []string
+			for _, task := range tasks {
+				guids = append(guids, task.GUID)
+			}
+			if got := strings.Join(guids, ","); got != tt.want {
+				t.Errorf("got tasks %s, want %s", got, tt.want)
+			}
+			var queries []string
+			for _, query := range recorder.queries {
+				createdAtFilter := ""
+				if query.Has("created_ats[gt]") {
+					createdAtFilter = "created_ats[gt]"
+				}
+				queries = append(queries, createdAtFilter)
+			}
+			if strings.Join(queries, ",") != strings.Join(tt.wantQueries, ",") {
+				t.Errorf("got created_at filters %q, want %q", queries, tt.wantQueries)
+			}
+		})
+	}
+}
+
+func TestGetTaskDuration(t *testing.T) {
+	finished := newTestTask("task-1", "app-000", taskSucceeded, 2*time.Hour)
+	finished.UpdatedAt = finished.CreatedAt.Add(90 * time.Second)
+	if got := getTaskDuration(finished); got != 90 {
+		t.Errorf("finished task: got %d seconds, want 90", got)
+	}
+	running := newTestTask("task-2", "app-000", taskRunning, 2*time.Hour)
+	if got := getTaskDuration(running); got < 7200 || got > 7210 {
+		t.Errorf("running task: got %d seconds, want about 7200 (until now)", got)
+	}
+}
+
+func TestFormatTaskCounts(t *testing.T) {
+	tests := []struct {
+		name        string
+		stateCounts map[string]int
+		want        string
+	}{
+		{name: "no tasks", stateCounts: map[string]int{}, want: "-"},
+		{name: "in the order of the states", stateCounts: map[string]int{taskFailed: 1, taskRunning: 2, taskSucceeded: 3}, want: "2 running, 3 succeeded, 1 failed"},
+		{name: "zero counts are left out", stateCounts: map[string]int{"PENDING": 1, taskFailed: 0}, want: "1 pending"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := terminal.Decolorize(formatTaskCounts(tt.stateCounts)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListTasksInvalidFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "unknown state", args: []string{"--state", "done"}, wantErr: "invalid --state done, should be one or more of pending,running,canceling,succeeded,failed"},
+		{name: "unknown state in a list", args: []string{"--state", "Failed, finished"}, wantErr: "invalid --state finished, should be one or more of pending,running,canceling,succeeded,failed"},
+		{name: "negative window", args: []string{"--window", "-1h"}, wantErr: "invalid --window -1h0m0s, should be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := listTasks(newTestContext(t, &fake.Fake{}), tt.args)
+			if err == nil || err.Error() != tt.wantErr || conf.ExitCode(err) != conf.ExitFailure {
+				t.Errorf("got error %v, want usage error %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/** forEachThrottled - Call fn for the indexes 0 to count-1 concurrently, and wait for all calls to end. Each new call waits 25ms for every call that is still running, and no new calls are started once ctx is cancelled. */
+func forEachThrottled(ctx context.Context, count int, fn func(ix int)) {
+	var running int32
+	var waitGroup sync.WaitGroup
+	for ix := 0; ix < count; ix++ {
+		concurrency := atomic.AddInt32(&running, 1)
+		// throttle a bit:
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Millisecond * 25 * time.Duration(concurrency)):
+		}
+		if ctx.Err() != nil {
+			atomic.AddInt32(&running, -1)
+			break // cancelled, don't start any new calls
+		}
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			defer atomic.AddInt32(&running, -1)
+			fn(ix)
+		}()
+	}
+	waitGroup.Wait()
+}
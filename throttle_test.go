@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEachThrottled(t *testing.T) {
+	var mutex sync.Mutex
+	called := map[int]int{}
+	forEachThrottled(context.Background(), 5, func(ix int) {
+		mutex.Lock()
+		called[ix]++
+		mutex.Unlock()
+	})
+	for ix := 0; ix < 5; ix++ {
+		if called[ix] != 1 {
+			t.Errorf("index %d: got %d calls, want 1", ix, called[ix])
+		}
+	}
+}
+
+func TestForEachThrottledCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	var calls int
+	go func() {
+		<-started
+		cancel()
+	}()
+	start := time.Now()
+	forEachThrottled(ctx, 100, func(ix int) {
+		calls++ // only the first call starts, it cancels ctx while the second call is throttled
+		if ix == 0 {
+			close(started)
+		}
+		time.Sleep(time.Second)
+	})
+	// without cancelling, the 100 calls take about 100 * 25ms to start
+	if calls != 1 || time.Since(start) > 1500*time.Millisecond {
+		t.Errorf("got %d calls in %s, want 1 call, and no wait for the throttle after cancelling", calls, time.Since(start))
+	}
+}